@@ -0,0 +1,78 @@
+package main
+
+// -align lines up the columns of the records printed with -p through a
+// tabwriter. Each print field starts a column, and so do the offset and
+// count. The tabwriter buffers alignBlock lines at a time so output still
+// streams, the columns are aligned within a block.
+
+import (
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Lines aligned together by -align.
+var alignBlock = 1000
+
+// Writer of the records printed with -p with -align, nil without it.
+var alignWriter *tabwriter.Writer
+
+// Lines written to alignWriter.
+var alignLines int
+
+// Ends the -offset-delta and -record-hash prefixes, a tab with -align.
+var prefixEnd = " "
+
+// startAlign sets the formats of the records for -align, printed to w.
+func startAlign(w io.Writer) {
+	offsetFmt = alignCell(offsetFmt)
+	countFmt = alignCell(countFmt)
+	prefixEnd = "\t"
+	opt.printFmt = alignPrintFmt(opt.printFmt)
+	alignWriter = tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	alignLines = 0
+}
+
+// alignCell returns the prefix format f ending a column instead of a space.
+func alignCell(f string) string {
+	return strings.TrimRight(f, " ") + "\t"
+}
+
+// alignPrintFmt returns printFmt with a column for each print field. A
+// column starts at the spaces of the text between two fields, like "b=%x"
+// in "a=%d, b=%x", or at the field if there are none. The spaces are
+// dropped, the padding of the columns separates them.
+func alignPrintFmt(printFmt string) string {
+	var b strings.Builder
+	written, end := 0, 0
+	for i, v := range findPrintFields(printFmt) {
+		if i > 0 {
+			b.WriteString(printFmt[written:end])
+			sep, label := printFmt[end:v[0]], ""
+			if j := strings.IndexByte(sep, ' '); j >= 0 {
+				sep, label = sep[:j], strings.TrimLeft(sep[j:], " ")
+			}
+			b.WriteString(sep + "\t" + label)
+			written = v[0]
+		}
+		end = v[1]
+	}
+	b.WriteString(printFmt[written:])
+	return b.String()
+}
+
+// recordOutput returns the writer of the records printed with -p.
+func recordOutput() io.Writer {
+	if alignWriter != nil {
+		return alignWriter
+	}
+	return output
+}
+
+// alignLineEnd counts a line written to alignWriter, a block is printed
+// when it's complete.
+func alignLineEnd() {
+	if alignLines++; alignLines%alignBlock == 0 {
+		alignWriter.Flush()
+	}
+}
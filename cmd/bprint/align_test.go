@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestAlignPrintFmt(t *testing.T) {
+	for _, c := range []struct {
+		printFmt, want string
+	}{
+		{"%d %d\n", "%d\t%d\n"},
+		{"a=%d, b=%x |%s\n", "a=%d,\tb=%x\t|%s\n"},
+		{"%d%% %d", "%d%%\t%d"},
+		{"%d", "%d"},
+		{"%d-%d", "%d-\t%d"},
+	} {
+		if res := alignPrintFmt(c.printFmt); res != c.want {
+			t.Errorf("aligned print format of %q wrong, got %q", c.printFmt, res)
+		}
+	}
+}
+
+func TestAlign(t *testing.T) {
+	defer func(o, c string) {
+		offsetFmt, countFmt, prefixEnd, alignWriter = o, c, " ", nil
+		opt.printOffset = false
+	}(offsetFmt, countFmt)
+	opt.printOffset = true
+
+	buf := new(bytes.Buffer)
+	output = buf
+	defer func() { output = os.Stdout }()
+	opt.printFmt = "%d, %d\n"
+	startAlign(buf)
+	recordCnt, offSet = 0, 0
+	formatField, recordSize, _ := parseBinaryFmt("SS")
+	dumpRecords(bytes.NewReader([]byte{1, 0, 0xff, 0, 0x10, 0x27, 5, 0}), formatField, recordSize)
+	want := "0000000 1,     255\n" +
+		"0000004 10000, 5\n" +
+		"0000008 \n"
+	if res := buf.String(); res != want {
+		t.Errorf("aligned records wrong, got\n%s", res)
+	}
+
+	opt.ascii = true
+	defer func() { opt.ascii = false }()
+	buf.Reset()
+	offsetFmt, opt.printFmt = "%07x ", "%d, %d\n"
+	startAlign(buf)
+	recordCnt, offSet = 0, 0
+	dumpRecords(bytes.NewReader([]byte{'a', 0, 'b', 0, 0x10, 0x27, 5, 0}), formatField, recordSize)
+	want = "0000000 97,    98 |a.b.|\n" +
+		"0000004 10000, 5  |.'..|\n" +
+		"0000008 \n"
+	if res := buf.String(); res != want {
+		t.Errorf("aligned records with -a wrong, got\n%s", res)
+	}
+}
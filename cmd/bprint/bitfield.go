@@ -0,0 +1,174 @@
+package main
+
+// C style bitfields given with -cbitfields split one integer field into
+// several labeled fields, e.g. "a:3,b:5" over a byte is
+//
+//	struct { unsigned a:3; unsigned b:5; }
+//
+// Bitfields are laid out the way GCC does on little-endian targets: the
+// first one takes the least significant bits of the storage unit, which is
+// the whole integer field.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gastaoss/bprint"
+)
+
+type cBitfields struct {
+	field  int
+	names  []string
+	widths []uint
+}
+
+var bitfields *cBitfields
+
+// parseCBitfields parses a bitfield list like "a:3,b:5", optionally
+// prefixed with the index of the field to split like "2=a:3,b:5". The field
+// defaults to 0.
+func parseCBitfields(s string, formatField []bprint.FieldType) *cBitfields {
+	bf := &cBitfields{}
+	if idx := strings.Index(s, "="); idx >= 0 {
+		var err error
+		if bf.field, err = strconv.Atoi(s[:idx]); err != nil {
+			panic(specError{fmt.Errorf("Invalid bitfield field index '%s'", s[:idx])})
+		}
+		s = s[idx+1:]
+	}
+	if bf.field < 0 || bf.field >= len(formatField) {
+		panic(specError{fmt.Errorf("Bitfield field %d out of range, record has %d fields", bf.field, len(formatField))})
+	}
+	t := formatField[bf.field]
+	if !t.IsInt() {
+		panic(specError{fmt.Errorf("Bitfields need an integer field, field %d is %s", bf.field, t.String())})
+	}
+
+	var total uint
+	for _, v := range strings.Split(s, ",") {
+		idx := strings.Index(v, ":")
+		width, err := strconv.Atoi(v[idx+1:])
+		if idx <= 0 || err != nil || width <= 0 {
+			panic(specError{fmt.Errorf("Invalid bitfield '%s', should be like name:3", v)})
+		}
+		bf.names = append(bf.names, v[:idx])
+		bf.widths = append(bf.widths, uint(width))
+		total += uint(width)
+	}
+	if size := uint(t.Size()) * 8; total > size {
+		panic(specError{fmt.Errorf("Bitfields take %d bits, more than the %d bits of field %d", total, size, bf.field)})
+	}
+	return bf
+}
+
+// expandTypes returns the types of the fields after splitting.
+func (bf *cBitfields) expandTypes(formatField []bprint.FieldType) []bprint.FieldType {
+	res := make([]bprint.FieldType, 0, len(formatField)+len(bf.names)-1)
+	res = append(res, formatField[:bf.field]...)
+	for range bf.names {
+		res = append(res, bprint.U64)
+	}
+	return append(res, formatField[bf.field+1:]...)
+}
+
+// expandNames returns the names of the fields after splitting fieldCnt
+// fields named by names, with "" for fields without a name.
+func (bf *cBitfields) expandNames(names []string, fieldCnt int) []string {
+	res := make([]string, fieldCnt+len(bf.names)-1)
+	for i := 0; i < bf.field && i < len(names); i++ {
+		res[i] = names[i]
+	}
+	copy(res[bf.field:], bf.names)
+	if len(names) > bf.field+1 {
+		copy(res[bf.field+len(bf.names):], names[bf.field+1:])
+	}
+	return res
+}
+
+// expand splits the bitfield field in data into the values of the
+// bitfields. data is returned unchanged if it doesn't have the field.
+func (bf *cBitfields) expand(data []interface{}) []interface{} {
+	if len(data) <= bf.field {
+		return data
+	}
+	v := uint64(toInt64(data[bf.field]))
+	res := make([]interface{}, 0, len(data)+len(bf.names)-1)
+	res = append(res, data[:bf.field]...)
+	for _, w := range bf.widths {
+		res = append(res, v&(1<<w-1))
+		v >>= w
+	}
+	return append(res, data[bf.field+1:]...)
+}
+
+// Bit ranges of the fields split in the binary format like "C{0-2,3,4-7}",
+// by field index. Each range becomes a uint64 field, before -cbitfields
+// splits a field.
+var specBits map[int][]bprint.BitRange
+
+// splitBitTypes returns the types of the fields after splitting the bit
+// ranges.
+func splitBitTypes(formatField []bprint.FieldType) []bprint.FieldType {
+	res := make([]bprint.FieldType, 0, len(formatField))
+	for i, t := range formatField {
+		if ranges := specBits[i]; ranges != nil {
+			for range ranges {
+				res = append(res, bprint.U64)
+			}
+		} else {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// splitBitNames returns the names of fieldCnt fields after splitting the bit
+// ranges, a range of a field named flags is named like flags.0-2.
+func splitBitNames(names []string, fieldCnt int) []string {
+	if names == nil {
+		return nil
+	}
+	res := make([]string, 0, fieldCnt)
+	for i := 0; i < fieldCnt; i++ {
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+		ranges := specBits[i]
+		if ranges == nil {
+			res = append(res, name)
+			continue
+		}
+		for _, r := range ranges {
+			switch {
+			case name == "":
+				res = append(res, "")
+			case r.Lo == r.Hi:
+				res = append(res, fmt.Sprintf("%s.%d", name, r.Lo))
+			default:
+				res = append(res, fmt.Sprintf("%s.%d-%d", name, r.Lo, r.Hi))
+			}
+		}
+	}
+	return res
+}
+
+// splitBits splits the fields with bit ranges in data into the values of
+// the ranges.
+func splitBits(data []interface{}) []interface{} {
+	res := make([]interface{}, 0, len(data))
+	for i, v := range data {
+		ranges := specBits[i]
+		if ranges == nil {
+			res = append(res, v)
+			continue
+		}
+		// Sign extended bits of a negative value are cut by the ranges
+		u := uint64(toInt64(v))
+		for _, r := range ranges {
+			res = append(res, r.Extract(u))
+		}
+	}
+	return res
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gastaoss/bprint"
+)
+
+func TestCBitfields(t *testing.T) {
+	defer func() { bitfields, fieldNames = nil, nil }()
+	formatField, _, _ := parseBinaryFmt("CS")
+	bitfields = parseCBitfields("a:3,b:5", formatField)
+
+	// a is the low 3 bits 0b101, b the high 5 bits 0b10110
+	res := dumpString("CS", "%d %d %d", []byte{0xb5, 0x01, 0x02})
+	if res != "5 22 513\n" {
+		t.Error("bitfields decoded wrong, got", res)
+	}
+
+	fieldNames = bitfields.expandNames(nil, len(formatField))
+	if fieldName(0) != "a" || fieldName(1) != "b" || fieldName(2) != "f2" {
+		t.Error("bitfield names wrong, got", fieldNames)
+	}
+	types := bitfields.expandTypes(formatField)
+	if len(types) != 3 || types[2] != bprint.U16 {
+		t.Error("bitfield types wrong, got", types)
+	}
+
+	bitfields = parseCBitfields("1=x:4,y:4,z:8", formatField)
+	res = dumpString("CS", "%d %x %x %x", []byte{7, 0x21, 0x43})
+	if res != "7 1 2 43\n" {
+		t.Error("bitfields of field 1 decoded wrong, got", res)
+	}
+
+	for _, s := range []string{"a:9", "2=a:1", "a", "a:0", "k=a:1"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("bitfields", s, "should be rejected")
+				}
+			}()
+			parseCBitfields(s, []bprint.FieldType{bprint.U8, bprint.RGB})
+		}()
+	}
+}
+
+func TestSpecBits(t *testing.T) {
+	defer func() { specBits, fieldNames = nil, nil }()
+	tree, _ := bprint.ParseSpecTree("C{0-2,3,4-7} >S{0-7,15}")
+	specBits = bprint.SpecBits(tree)
+
+	// 0xb5 is 0b1011_0101, the big-endian uint16 is 0x8102
+	res := dumpString("C >S", "%d %d %x %d %d", []byte{0xb5, 0x81, 0x02})
+	if res != "5 0 b 2 1\n" {
+		t.Error("bit ranges decoded wrong, got", res)
+	}
+	formatField, _, _ := parseBinaryFmt("CS")
+	if types := splitBitTypes(formatField); len(types) != 5 || types[4] != bprint.U64 {
+		t.Error("bit range types wrong, got", types)
+	}
+	fieldNames = splitBitNames([]string{"flags", ""}, 2)
+	want := []string{"flags.0-2", "flags.3", "flags.4-7", "", ""}
+	if !reflect.DeepEqual(fieldNames, want) {
+		t.Error("bit range names wrong, got", fieldNames)
+	}
+}
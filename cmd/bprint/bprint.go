@@ -0,0 +1,3414 @@
+package main
+
+// The binary format string uses the same syntax as Ruby's Array.unpack
+//
+// c: signed 8-bit integer
+// s: signed 16-bit integer
+// m: signed 24-bit integer
+// l: signed 32-bit integer
+// q: signed 64-bit integer
+//
+// Use upper case letter for unsigned integer.
+//
+// f: 32-bit float
+// d: 64-bit float
+//
+// k: 3 byte RGB color
+// K: 4 byte RGBA color
+// g: 16 byte Microsoft GUID
+// z: string terminated by NUL (or -str-term)
+//
+// x: skip a byte, not a field
+// a: fixed length string, the number following it is the length
+//
+// <, >: the following fields are little or big-endian
+//
+// Numbers following the letter means how many times the previous string
+// should be repeated.
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/big"
+	"math/bits"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/gastaoss/bprint"
+)
+
+const version = "0.2.1"
+
+func printVersion() {
+	fmt.Println("bprint version", version)
+	os.Exit(0)
+}
+
+var byteOrder binary.ByteOrder = binary.LittleEndian
+
+// Number of records read by -guess-endian to guess the byte order.
+const guessSampleCnt = 100
+
+// byteOrderScore sums the bit length of the multi-byte integer fields in the
+// records in sample, decoded using order. The lower the score, the more
+// reasonable the values are.
+func byteOrderScore(sample []byte, formatField []bprint.FieldType, order binary.ByteOrder) (score int) {
+	defer func(o binary.ByteOrder) { byteOrder = o }(byteOrder)
+	byteOrder = order
+
+	r := bytes.NewReader(sample)
+	fields := bprint.DataFields(formatField)
+	data := make([]interface{}, len(fields))
+	for {
+		n, err := readData(r, formatField, data)
+		for i := 0; i < n; i++ {
+			if fields[i].IsInt() && fields[i].Size() > 1 {
+				score += toBigInt(data[i]).BitLen()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// guessByteOrder sets byteOrder to the one giving smaller values for the
+// first records in binReader. The returned reader still has the sampled
+// records.
+func guessByteOrder(binReader io.Reader, formatField []bprint.FieldType, recordSize int) io.Reader {
+	sample := make([]byte, guessSampleCnt*recordSize)
+	n, err := io.ReadFull(binReader, sample)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		panic(fmt.Sprintf("While reading data: %v", err))
+	}
+	sample = sample[:n]
+
+	little := byteOrderScore(sample, formatField, binary.LittleEndian)
+	big := byteOrderScore(sample, formatField, binary.BigEndian)
+	name := "little"
+	byteOrder = binary.LittleEndian
+	if big < little {
+		name = "big"
+		byteOrder = binary.BigEndian
+	}
+	fmt.Fprintf(diagOutput, "Guessed %s-endian byte order (little-endian score %d, big-endian score %d)\n",
+		name, little, big)
+	return io.MultiReader(bytes.NewReader(sample), binReader)
+}
+
+// nativeByteOrder is the byte order of the host, found by looking at the
+// bytes of a uint16.
+var nativeByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if (*[2]byte)(unsafe.Pointer(&x))[0] == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// selectByteOrder sets byteOrder according to the byte order options.
+func selectByteOrder() {
+	if opt.littleEndian && opt.bigEndian {
+		panic(specError{errors.New("Options -le and -be conflict, only one byte order can be used")})
+	}
+	if opt.nativeEndian && (opt.littleEndian || opt.bigEndian) {
+		panic(specError{errors.New("Option -N conflicts with -le, -be and -B, only one byte order can be used")})
+	}
+	if opt.guessEndian && (opt.littleEndian || opt.bigEndian || opt.nativeEndian) {
+		panic(specError{errors.New("Option -guess-endian conflicts with -le, -be, -B and -N, the byte order is either given or guessed")})
+	}
+	if opt.bigEndian {
+		byteOrder = binary.BigEndian
+	} else if opt.nativeEndian {
+		byteOrder = nativeByteOrder
+	} else {
+		byteOrder = binary.LittleEndian
+	}
+}
+
+// Go type of the decoded values, as printed by %T in Go.
+var goTypeNames = [...]string{
+	bprint.I8:  "int8",
+	bprint.I16: "int16",
+	bprint.I24: "int32",
+	bprint.I32: "int32",
+	bprint.I64: "int64",
+
+	bprint.U8:  "uint8",
+	bprint.U16: "uint16",
+	bprint.U24: "uint32",
+	bprint.U32: "uint32",
+	bprint.U64: "uint64",
+
+	bprint.F32: "float32",
+	bprint.F64: "float64",
+	bprint.F16: "float32",
+
+	bprint.I128: "*big.Int",
+	bprint.U128: "*big.Int",
+
+	bprint.RGB:  "main.rgbColor",
+	bprint.RGBA: "main.rgbColor",
+
+	bprint.GUID: "bprint.UUID",
+
+	bprint.STRZ: "string",
+
+	bprint.ULEB: "uint64",
+	bprint.SLEB: "int64",
+
+	bprint.SKIP: "",
+	bprint.BACK: "",
+
+	bprint.ALIGN:     "",
+	bprint.ALIGNTAIL: "",
+
+	bprint.STR:     "string",
+	bprint.STRTAIL: "",
+
+	bprint.LITTLE: "",
+	bprint.BIG:    "",
+}
+
+// Byte terminating z strings.
+var strTerm byte = 0
+
+// specError is a mistake in the binary or print format, main exits with
+// exitSpecError for it.
+type specError struct {
+	error
+}
+
+// Error starts the message with a capital like the other errors of main, the
+// errors of package bprint are lowercase.
+func (e specError) Error() string {
+	s := e.error.Error()
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// reportedError is panicked for an error which was already reported on
+// stderr, main exits with exitError without another message.
+type reportedError struct{}
+
+// Exit codes of main.
+const (
+	exitError     = 1
+	exitSpecError = 2
+)
+
+func parseBinaryFmt(binFmt string) (formatField []bprint.FieldType, recSize int, err error) {
+	return bprint.ParseSpec(binFmt)
+}
+
+// forceSign changes the integer fields in place to unsigned with -u or to
+// signed with -i.
+func forceSign(fields []bprint.FieldType) {
+	for i, v := range fields {
+		if opt.forceUnsigned {
+			fields[i] = v.Unsigned()
+		} else if opt.forceSigned {
+			fields[i] = v.Signed()
+		}
+	}
+}
+
+// readData reads the fields in formatField into data, n is the number of
+// fields read. Skipped bytes don't take a place in data.
+func readData(binReader io.Reader, formatField []bprint.FieldType, data []interface{}) (n int, err error) {
+	c := &dataDecoder
+	if c.dec == nil || c.r != binReader || !sameFields(c.fields, formatField) ||
+		c.order != byteOrder || c.dec.StrTerm != strTerm {
+		c.dec = bprint.NewDecoder(binReader, formatField, byteOrder)
+		c.dec.StrTerm = strTerm
+		c.r, c.fields, c.order = binReader, formatField, byteOrder
+	}
+	n, err = c.dec.DecodeInto(data)
+	mainColors(data)
+	return
+}
+
+// rgbColor is the value of RGB and RGBA fields, printed as a hex color like
+// #ff0000. It keeps the type name colors had before decoding moved to
+// package bprint, which -types shows.
+type rgbColor bprint.Color
+
+func (c rgbColor) String() string {
+	return bprint.Color(c).String()
+}
+
+// mainColors replaces the bprint.Color values in data, also in lists, with
+// rgbColor.
+func mainColors(data []interface{}) {
+	for i, v := range data {
+		switch v := v.(type) {
+		case bprint.Color:
+			data[i] = rgbColor(v)
+		case []interface{}:
+			mainColors(v)
+		}
+	}
+}
+
+// Decoder of readData, reused while the reader, fields and byte order are
+// the same, as for the records of a file.
+var dataDecoder struct {
+	dec    *bprint.Decoder
+	r      io.Reader
+	fields []bprint.FieldType
+	order  binary.ByteOrder
+}
+
+// sameFields reports whether a and b are the same slice.
+func sameFields(a, b []bprint.FieldType) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// readRecord reads one record with readData, then transforms the values as
+// requested by options.
+func readRecord(binReader io.Reader, formatField []bprint.FieldType, data []interface{}) (n int, err error) {
+	n, err = readData(binReader, formatField, data)
+	if err == nil && array != nil {
+		data[n], err = array.read(binReader, data)
+		if err == nil {
+			n++
+		}
+	}
+	for _, i := range swapFields {
+		if i < n {
+			data[i] = swapBytes(data[i])
+		}
+	}
+	if opt.zigzag {
+		for i := 0; i < n; i++ {
+			data[i] = zigzagDecode(data[i])
+		}
+	}
+	for _, w := range signWidths {
+		if w.field < n {
+			data[w.field] = signExtend(data[w.field], w.width)
+		}
+	}
+	for _, i := range fixedStrings {
+		if i < n {
+			data[i] = decodeString(data[i].(string))
+		}
+	}
+	return
+}
+
+// Index of the fixed length string fields, their padding is trimmed as
+// given by -string-trim and they're decoded as given by -enc.
+var fixedStrings []int
+
+// Index of fields to byte swap after reading, given by -swap-fields.
+var swapFields []int
+
+// parseFieldList parses a list of 0-based field index like "1,3,5-7".
+func parseFieldList(s string, fieldCnt int) (fields []int) {
+	for _, v := range strings.Split(s, ",") {
+		bounds := strings.SplitN(v, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		last := first
+		if err == nil && len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+		}
+		if err != nil || first < 0 || last < first {
+			panic(specError{fmt.Errorf("Invalid field list '%s', should be like 1,3,5-7", v)})
+		}
+		if last >= fieldCnt {
+			panic(specError{fmt.Errorf("Field %d out of range, record has %d fields", last, fieldCnt)})
+		}
+		for i := first; i <= last; i++ {
+			fields = append(fields, i)
+		}
+	}
+	return
+}
+
+// swapBytes reverses the byte order of an integer value.
+func swapBytes(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int16:
+		return int16(bits.ReverseBytes16(uint16(v)))
+	case int32:
+		return int32(bits.ReverseBytes32(uint32(v)))
+	case int64:
+		return int64(bits.ReverseBytes64(uint64(v)))
+	case uint16:
+		return bits.ReverseBytes16(v)
+	case uint32:
+		return bits.ReverseBytes32(v)
+	case uint64:
+		return bits.ReverseBytes64(v)
+	}
+	return v
+}
+
+// varArray is a list of elements following the fields of a record, the
+// number of elements is the value of a field.
+type varArray struct {
+	countField int
+	element    []bprint.FieldType
+	// Type of the list, the same as the element repeated to the end
+	listType bprint.FieldType
+}
+
+var array *varArray
+
+// parseVarArray parses an array spec like "1:S" meaning field 1 is the count
+// of uint16 elements.
+func parseVarArray(s string, formatField []bprint.FieldType) *varArray {
+	idx := strings.Index(s, ":")
+	if idx <= 0 {
+		panic(specError{fmt.Errorf("Invalid array '%s', should be like 1:S", s)})
+	}
+	a := &varArray{}
+	var err error
+	if a.countField, err = strconv.Atoi(s[:idx]); err != nil ||
+		a.countField < 0 || a.countField >= len(formatField) {
+		panic(specError{fmt.Errorf("Invalid array count field '%s', record has %d fields", s[:idx], len(formatField))})
+	}
+	if !formatField[a.countField].IsInt() {
+		panic(specError{fmt.Errorf("Array count field %d is not an integer", a.countField)})
+	}
+	if a.element, _, err = parseBinaryFmt(s[idx+1:]); err != nil {
+		panic(specError{err})
+	}
+	forceSign(a.element)
+	if len(bprint.DataFields(a.element)) == 0 {
+		panic(specError{fmt.Errorf("Invalid array '%s', element format is empty", s)})
+	}
+	list, _, err := parseBinaryFmt("(" + s[idx+1:] + ")*")
+	if err != nil {
+		panic(specError{err})
+	}
+	a.listType = list[0]
+	return a
+}
+
+// Elements of a -array list allocated before they're read.
+const maxArrayPrealloc = 1024
+
+// read reads the elements of the array for a record with field values in
+// data. Element with one field is stored as the value, otherwise as a list.
+func (a *varArray) read(binReader io.Reader, data []interface{}) (list []interface{}, err error) {
+	cnt := toInt64(data[a.countField])
+	if cnt < 0 {
+		return nil, fmt.Errorf("negative array count %d", cnt)
+	}
+	// The count comes from the input, a wrong one can't allocate much more
+	// than the elements read
+	prealloc := cnt
+	if prealloc > maxArrayPrealloc {
+		prealloc = maxArrayPrealloc
+	}
+	list = make([]interface{}, 0, prealloc)
+	for i := int64(0); i < cnt; i++ {
+		elem := make([]interface{}, len(a.element))
+		var n int
+		n, err = readData(binReader, a.element, elem)
+		if err != nil {
+			if err == io.EOF && n == 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+		// Skipped bytes leave no field
+		elem = elem[:n]
+		if len(elem) == 1 {
+			list = append(list, elem[0])
+		} else {
+			list = append(list, elem)
+		}
+	}
+	return
+}
+
+// zigzagDecode reverses zigzag encoding of an unsigned value, which maps
+// signed values to unsigned as 0, -1, 1, -2, 2 ... => 0, 1, 2, 3, 4 ...
+// Other values are returned unchanged.
+func zigzagDecode(v interface{}) interface{} {
+	switch v := v.(type) {
+	case uint8:
+		return int8(v>>1) ^ -int8(v&1)
+	case uint16:
+		return int16(v>>1) ^ -int16(v&1)
+	case uint32:
+		return int32(v>>1) ^ -int32(v&1)
+	case uint64:
+		return int64(v>>1) ^ -int64(v&1)
+	}
+	return v
+}
+
+// signWidth is the bit width of a signed value stored in an integer field,
+// given by -bitwidth.
+type signWidth struct {
+	field int
+	width uint
+}
+
+var signWidths []signWidth
+
+// parseSignWidths parses a bit width list like "0:12,3:24".
+func parseSignWidths(s string, formatField []bprint.FieldType) (widths []signWidth) {
+	for _, v := range strings.Split(s, ",") {
+		parts := strings.Split(v, ":")
+		if len(parts) != 2 {
+			panic(specError{fmt.Errorf("Invalid bit width '%s', should be like 0:12", v)})
+		}
+		field, err1 := strconv.Atoi(parts[0])
+		width, err2 := strconv.Atoi(parts[1])
+		if err1 != nil || err2 != nil || width <= 0 {
+			panic(specError{fmt.Errorf("Invalid bit width '%s', should be like 0:12", v)})
+		}
+		if field < 0 || field >= len(formatField) || !formatField[field].IsInt() {
+			panic(specError{fmt.Errorf("Bit width field %d is not an integer field", field)})
+		}
+		if size := formatField[field].Size() * 8; width > size {
+			panic(specError{fmt.Errorf("Bit width %d is more than the %d bits of field %d", width, size, field)})
+		}
+		widths = append(widths, signWidth{field, uint(width)})
+	}
+	return
+}
+
+// signExtend sign extends the low width bits of an integer value into a
+// signed value of the same size.
+func signExtend(v interface{}, width uint) interface{} {
+	shift := 64 - width
+	x := int64(uint64(toInt64(v))<<shift) >> shift
+	switch v.(type) {
+	case int8, uint8:
+		return int8(x)
+	case int16, uint16:
+		return int16(x)
+	case int32, uint32:
+		return int32(x)
+	}
+	return x
+}
+
+var (
+	recordCnt  int
+	recordSize int
+	offSet     int
+	// Bytes consumed by the record being printed
+	recordBytes int
+	// Hash of the bytes of the record being printed, with -record-hash
+	recordHash uint32
+	// Bytes of the record being printed
+	recordRaw []byte
+)
+
+// Hash functions of -record-hash.
+var recordHashers = map[string]func([]byte) uint32{
+	"fnv": func(b []byte) uint32 {
+		h := fnv.New32a()
+		h.Write(b)
+		return h.Sum32()
+	},
+	"crc32": crc32.ChecksumIEEE,
+}
+
+var recordHasher func([]byte) uint32
+
+// Format of the offsets printed by -o, set by -O.
+var offsetFmt = "%07x "
+
+var offsetVerbs = map[string]byte{
+	"hex": 'x',
+	"dec": 'd',
+	"oct": 'o',
+}
+
+// Record count printed by -c, with a width given by -cw.
+var countFmt = "%d: "
+
+// parseOffsetFmt parses an offset format like hex, dec or oct:10 with an
+// optional width. Without a width, offsets are wide enough for size, and
+// at least 7 digits.
+func parseOffsetFmt(s string, size int64) string {
+	invalid := func() {
+		panic(specError{fmt.Errorf("Invalid offset format '%s', should be hex, dec or oct with an optional width like hex:10", s)})
+	}
+	mode, width := s, 0
+	if idx := strings.Index(s, ":"); idx >= 0 {
+		mode = s[:idx]
+		var err error
+		if width, err = strconv.Atoi(s[idx+1:]); err != nil || width <= 0 {
+			invalid()
+		}
+	}
+	verb, ok := offsetVerbs[mode]
+	if !ok {
+		invalid()
+	}
+	if width == 0 {
+		width = 7
+		if n := len(fmt.Sprintf("%"+string(verb), size)); n > width {
+			width = n
+		}
+	}
+	return fmt.Sprintf("%%0%d%c ", width, verb)
+}
+
+// inputSize returns the total size of the files in paths, URLs and files
+// which can't be stat'ed don't count.
+func inputSize(paths []string) (size int64) {
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+	}
+	return
+}
+
+// All record output goes to output.
+var output io.Writer = os.Stdout
+
+// flushOutput writes out buffered output.
+func flushOutput() {
+	if alignWriter != nil {
+		alignWriter.Flush()
+	}
+	if w, ok := output.(*bufio.Writer); ok {
+		w.Flush()
+	}
+}
+
+// Warnings and notes go to diagOutput, so they are not mixed into output.
+var diagOutput io.Writer = os.Stderr
+
+// Only records for which recordFilter is true are printed, if set.
+var recordFilter expr
+
+// Set by -empty-fmt with an empty -p, no field is printed.
+var emptyPrintFmt bool
+
+// Terminates each record printed with -p, a NUL byte with -0.
+var recordEnd = "\n"
+
+// Records already printed on the current line with -g.
+var groupPos int
+
+// endGroup terminates the line of records, also when a last line of grouped
+// records is cut short at EOF.
+func endGroup() {
+	if groupPos != 0 {
+		io.WriteString(recordOutput(), recordEnd)
+		groupPos = 0
+	}
+}
+
+func printData(printFmt string, data []interface{}) {
+	w := recordOutput()
+	var line *bytes.Buffer
+	if opt.linePad > 0 || opt.prefix != "" || opt.suffix != "" || opt.ascii || opt.group > 1 {
+		line = new(bytes.Buffer)
+		w = line
+	}
+	if opt.printOffset && groupPos == 0 {
+		fmt.Fprintf(w, offsetFmt, offSet)
+	}
+	if opt.printRecordCnt && groupPos == 0 {
+		fmt.Fprintf(w, countFmt, recordCnt)
+	}
+	if opt.offsetDelta {
+		fmt.Fprintf(w, "+%d"+prefixEnd, recordBytes)
+	}
+	if recordHasher != nil {
+		fmt.Fprintf(w, "%08x"+prefixEnd, recordHash)
+	}
+	for i, conv := range fieldConv {
+		if conv != nil && i < len(data) {
+			data[i] = conv(data[i])
+		}
+	}
+	if emptyPrintFmt {
+		data = nil
+	} else {
+		printFmt = shortPrintFmt(printFmt, len(data))
+	}
+	escapeStrings(printFmt, data)
+	fmt.Fprintf(w, printFmt, data...)
+	if line != nil {
+		l := strings.TrimSuffix(line.String(), recordEnd)
+		if groupPos == 0 {
+			l = opt.prefix + l
+		} else {
+			l = " " + l
+		}
+		if opt.ascii && alignWriter != nil {
+			// A column of its own, after the widest record
+			l += "\t|" + asciiSidebar(recordRaw) + "|"
+		} else if opt.ascii {
+			l += "  |" + asciiSidebar(recordRaw) + "|"
+		}
+		l += opt.suffix
+		if opt.linePad > 0 {
+			l = padLines(l, opt.linePad)
+		}
+		io.WriteString(recordOutput(), l)
+		if groupPos++; groupPos >= opt.group {
+			endGroup()
+		}
+	}
+	if alignWriter != nil && groupPos == 0 {
+		alignLineEnd()
+	}
+}
+
+// asciiSidebar returns the bytes in raw as printable ASCII, other bytes are
+// shown as '.' like hexdump -C.
+func asciiSidebar(raw []byte) string {
+	b := make([]byte, len(raw))
+	for i, c := range raw {
+		if c < ' ' || c > '~' {
+			c = '.'
+		}
+		b[i] = c
+	}
+	return string(b)
+}
+
+// padLines pads each line in s with spaces to width characters. Longer lines
+// are truncated, or are an error with -line-long error.
+func padLines(s string, width int) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		// The escapes of -color take no room on the line
+		n, end := colorWidth(l, width)
+		if n > width {
+			if opt.lineLong == "error" {
+				panic(fmt.Sprintf("Record %d at offset %d: line of %d characters is longer than -line-pad %d",
+					recordCnt, offSet, n, width))
+			}
+			lines[i] = l[:end]
+			if strings.Contains(lines[i], "\x1b[") {
+				lines[i] += colorReset
+			}
+		} else {
+			lines[i] = l + strings.Repeat(" ", width-n)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// rawRecorder keeps a copy of the bytes read through it, so the raw bytes
+// of a record are available after decoding.
+type rawRecorder struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (rr *rawRecorder) Read(p []byte) (n int, err error) {
+	n, err = rr.r.Read(p)
+	rr.buf = append(rr.buf, p[:n]...)
+	return
+}
+
+func (rr *rawRecorder) reset() {
+	rr.buf = rr.buf[:0]
+}
+
+// Approximate limit of the memory retained by buffering modes like -table,
+// given by -max-mem. 0 for no limit.
+var maxMem int64
+
+// Approximate memory retained so far by buffering modes.
+var memUsed int64
+
+// retainMem accounts for n more bytes retained by the buffering mode, and
+// aborts if this goes beyond maxMem.
+func retainMem(n int, mode string) {
+	memUsed += int64(n)
+	if maxMem > 0 && memUsed > maxMem {
+		panic(fmt.Sprintf("%s needs more than the %d bytes allowed by -max-mem, output aborted", mode, maxMem))
+	}
+}
+
+// parseByteSize parses a size in bytes with an optional K, M or G suffix for
+// powers of 1024.
+func parseByteSize(s string) int64 {
+	mult := int64(1)
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'k', 'K':
+			mult = 1 << 10
+		case 'm', 'M':
+			mult = 1 << 20
+		case 'g', 'G':
+			mult = 1 << 30
+		}
+		if mult != 1 {
+			s = s[:n-1]
+		}
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || v < 0 {
+		panic(specError{fmt.Errorf("Invalid size '%s', should be like 4096, 64K or 1G", s)})
+	}
+	return v * mult
+}
+
+// chunkSplitter splits the input into chunks separated by sep, each decoded
+// as one record, given by -input-sep.
+type chunkSplitter struct {
+	r   *bufio.Reader
+	sep []byte
+	// Length of the separator after the last chunk, 0 at EOF
+	sepLen int
+}
+
+var inputSep []byte
+
+// next returns the next chunk. ok is false at EOF.
+func (cs *chunkSplitter) next() (chunk []byte, ok bool) {
+	cs.sepLen = 0
+	for {
+		b, err := cs.r.ReadByte()
+		if err != nil {
+			if err != io.EOF {
+				panic(fmt.Sprintf("While reading data: %v", err))
+			}
+			return chunk, len(chunk) != 0
+		}
+		chunk = append(chunk, b)
+		if bytes.HasSuffix(chunk, cs.sep) {
+			cs.sepLen = len(cs.sep)
+			return chunk[:len(chunk)-len(cs.sep)], true
+		}
+	}
+}
+
+// parseInputSep parses a separator with Go string escapes like "\n---\n".
+func parseInputSep(s string) []byte {
+	sep, err := strconv.Unquote(`"` + s + `"`)
+	if err != nil || sep == "" {
+		panic(specError{fmt.Errorf("Invalid input separator '%s'", s)})
+	}
+	return []byte(sep)
+}
+
+// Separator of the fields of the default print format and of "N#" repeats
+// without one, given by -d.
+var fieldSep = " "
+
+// parseFieldSep parses a separator with Go string escapes like "\t" for -d.
+// It's inserted in the print format, so '%' is escaped.
+func parseFieldSep(s string) string {
+	sep, err := strconv.Unquote(`"` + s + `"`)
+	if err != nil {
+		panic(specError{fmt.Errorf("Invalid field separator '%s'", s)})
+	}
+	return strings.ReplaceAll(sep, "%", "%%")
+}
+
+// sentinel reports whether a record ends the data, given by -until. The
+// sentinel record is not printed.
+var sentinel func(fields []interface{}, raw []byte) bool
+
+// parseSentinel parses -until, either all-zero for a record of zero bytes or
+// an expression like "f0 == 0".
+func parseSentinel(s string, fields []bprint.FieldType) func([]interface{}, []byte) bool {
+	if s == "all-zero" {
+		return func(_ []interface{}, raw []byte) bool {
+			return len(bytes.Trim(raw, "\x00")) == 0
+		}
+	}
+	e := parseExpr(s, fields)
+	return func(fields []interface{}, _ []byte) bool {
+		return isTrue(e(fields))
+	}
+}
+
+// Size records are padded to with -recsize, 0 for no padding.
+var paddedSize int
+
+// skipPadding reads the bytes following the record in rec up to paddedSize.
+// With -warn-padding, nonzero padding bytes are reported as they usually are
+// a spec error or real data in reserved space.
+func skipPadding(rec *rawRecorder) {
+	if len(rec.buf) >= paddedSize {
+		return
+	}
+	pad := make([]byte, paddedSize-len(rec.buf))
+	n, _ := io.ReadFull(rec, pad)
+	pad = pad[:n]
+	if opt.warnPadding && len(bytes.Trim(pad, "\x00")) != 0 {
+		fmt.Fprintf(diagOutput, "Record %d at offset %d: nonzero padding % x\n", recordCnt, offSet, pad)
+	}
+}
+
+// printTrailing prints the bytes at EOF not forming a whole record, which
+// are often a trailer or checksum.
+func printTrailing(raw []byte) {
+	fmt.Fprintf(output, "Trailing %d bytes at offset %d: % x\n", len(raw), offSet, raw)
+}
+
+// printGoBytes prints the raw bytes of a record as one line of the elements
+// in a Go []byte literal.
+func printGoBytes(raw []byte) {
+	if len(raw) == 0 {
+		return
+	}
+	output.Write([]byte{'\t'})
+	for i, b := range raw {
+		if i > 0 {
+			output.Write([]byte{' '})
+		}
+		fmt.Fprintf(output, "0x%02x,", b)
+	}
+	output.Write([]byte{'\n'})
+}
+
+// Names of the fields, "" for fields without a name.
+var fieldNames []string
+
+func fieldName(i int) string {
+	if i < len(fieldNames) && fieldNames[i] != "" {
+		return fieldNames[i]
+	}
+	return fmt.Sprintf("f%d", i)
+}
+
+// checkFieldNames rejects a name given to several of the cnt fields, the
+// names are the keys of -j objects and the columns of -H.
+func checkFieldNames(cnt int) {
+	seen := make(map[string]int, cnt)
+	for i := 0; i < cnt; i++ {
+		name := fieldName(i)
+		if j, ok := seen[name]; ok {
+			panic(specError{fmt.Errorf("Fields %d and %d have the same name '%s'", j, i, name)})
+		}
+		seen[name] = i
+	}
+}
+
+// Print format of each field for the modes formatting fields one by one, like
+// -pretty.
+var prettySpecs []string
+
+// cellNames returns the names of the offset, count and fieldCnt fields of a
+// record, in the order of the values of recordCells.
+func cellNames(fieldCnt int) (names []string) {
+	if opt.printOffset {
+		names = append(names, "offset")
+	}
+	if opt.printRecordCnt {
+		names = append(names, "record")
+	}
+	if opt.offsetDelta {
+		names = append(names, "bytes")
+	}
+	if recordHasher != nil {
+		names = append(names, "hash")
+	}
+	for i := 0; i < fieldCnt; i++ {
+		names = append(names, fieldName(i))
+	}
+	return
+}
+
+// recordCells returns the names and formatted values of the offset, count
+// and fields of a record, for -pretty, -table, -columnar and -tsv.
+func recordCells(data []interface{}) (names, values []string) {
+	if opt.printOffset {
+		values = append(values, strings.TrimSuffix(fmt.Sprintf(offsetFmt, offSet), " "))
+	}
+	if opt.printRecordCnt {
+		values = append(values, strconv.Itoa(recordCnt))
+	}
+	if opt.offsetDelta {
+		values = append(values, strconv.Itoa(recordBytes))
+	}
+	if recordHasher != nil {
+		values = append(values, fmt.Sprintf("%08x", recordHash))
+	}
+	for i, v := range data {
+		if i < len(fieldConv) && fieldConv[i] != nil {
+			v = fieldConv[i](v)
+		}
+		values = append(values, fmt.Sprintf(prettySpecs[i], v))
+	}
+	return cellNames(len(data)), values
+}
+
+// printPretty prints a record as a block of name and value lines with the
+// colons aligned, followed by an empty line.
+func printPretty(data []interface{}) {
+	names, values := recordCells(data)
+	width := 0
+	for _, name := range names {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	for i, name := range names {
+		fmt.Fprintf(output, "%-*s : %s\n", width, name, values[i])
+	}
+	fmt.Fprintln(output)
+}
+
+func printRecord(data []interface{}, raw []byte) {
+	data = selectValues(data)
+	if recordChecksum != nil {
+		data = appendChecksum(data, raw)
+	}
+	recordBytes, recordRaw = len(raw), raw
+	if recordHasher != nil {
+		recordHash = recordHasher(raw)
+	}
+	if opt.countOnly {
+		// Counted by dumpRecords
+	} else if opt.stats {
+		accumulateStats(data)
+	} else if opt.goBytes {
+		printGoBytes(raw)
+	} else if opt.pretty {
+		printPretty(data)
+	} else if opt.jsonOutput {
+		printJSON(data)
+	} else if opt.table {
+		addTableRow(data)
+	} else if histogram != nil {
+		histogram.add(data)
+	} else if opt.columnar {
+		addColumnValues(data)
+	} else if opt.tsv {
+		printTSV(data)
+	} else if opt.goLiteral || opt.cLiteral {
+		printLiteral(data)
+	} else if opt.csv {
+		printCSV(data)
+	} else if recordTmpl != nil {
+		printTemplate(data)
+	} else {
+		if rawFields != nil {
+			data = addRawBytes(data, raw)
+		} else if offsetFields != nil {
+			data = addFieldOffsets(data, raw)
+		}
+		printData(opt.printFmt, data)
+	}
+}
+
+// printFmtOutput reports whether records are printed with the print format,
+// not in another output mode.
+func printFmtOutput() bool {
+	return !opt.countOnly && !opt.stats && !opt.goBytes && !opt.pretty && !opt.jsonOutput && !opt.table &&
+		histogram == nil && !opt.columnar && !opt.tsv && !opt.csv && recordTmpl == nil &&
+		!opt.goLiteral && !opt.cLiteral
+}
+
+// valueRange is an inclusive range of values for a field.
+type valueRange struct {
+	field    int
+	min, max *big.Rat
+}
+
+var rangeChecks []valueRange
+
+// parseBound parses a bound of -range-check or -hist-buckets, an integer
+// like 100 or 0x64, or a decimal like -0.5 for float fields.
+func parseBound(s string) (*big.Rat, bool) {
+	if i, ok := new(big.Int).SetString(s, 0); ok {
+		return new(big.Rat).SetInt(i), true
+	}
+	return new(big.Rat).SetString(s)
+}
+
+// parseRangeChecks parses a list like "0:0..100,2:-5..5" of field index and
+// inclusive value range.
+func parseRangeChecks(s string, fields []bprint.FieldType) (ranges []valueRange) {
+	for _, v := range strings.Split(s, ",") {
+		var r valueRange
+		var err error
+		var ok1, ok2 bool
+		idx := strings.Index(v, ":")
+		bounds := strings.SplitN(v[idx+1:], "..", 2)
+		if idx > 0 && len(bounds) == 2 {
+			r.field, err = strconv.Atoi(v[:idx])
+			r.min, ok1 = parseBound(bounds[0])
+			r.max, ok2 = parseBound(bounds[1])
+		}
+		if err != nil || !ok1 || !ok2 {
+			panic(specError{fmt.Errorf("Invalid range check '%s', should be like 0:0..100", v)})
+		}
+		if r.field < 0 || r.field >= len(fields) {
+			panic(specError{fmt.Errorf("Range check field %d out of range, record has %d fields", r.field, len(fields))})
+		}
+		switch t := fields[r.field]; t {
+		case bprint.F32, bprint.F64, bprint.F16:
+		default:
+			if !exprType(t) {
+				panic(specError{fmt.Errorf("Range check field %d is %s, should be a number", r.field, t.String())})
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return
+}
+
+// checkRanges warns about field values in data outside of rangeChecks. A
+// float NaN or infinity is out of any range.
+func checkRanges(data []interface{}) {
+	for _, r := range rangeChecks {
+		v := toBigRat(data[r.field])
+		if v == nil || v.Cmp(r.min) < 0 || v.Cmp(r.max) > 0 {
+			fmt.Fprintf(diagOutput, "Record %d at offset %d: field %d value %v out of range %s..%s\n",
+				recordCnt, offSet, r.field, data[r.field], ratString(r.min), ratString(r.max))
+		}
+	}
+}
+
+// ascendingCheck verifies that a field never decreases from one record to
+// the next, given by -ascending. Only the first violation is reported.
+type ascendingCheck struct {
+	field int
+	// Equal values are also a violation
+	strict     bool
+	prev       *big.Int
+	prevOffset int
+	reported   bool
+}
+
+var ascending *ascendingCheck
+
+func (a *ascendingCheck) check(data []interface{}) {
+	v := toBigInt(data[a.field])
+	if a.prev != nil && !a.reported {
+		if c := v.Cmp(a.prev); c < 0 || (a.strict && c == 0) {
+			fmt.Fprintf(diagOutput, "Record %d at offset %d: field %d value %v not ascending after %v at offset %d\n",
+				recordCnt, offSet, a.field, v, a.prev, a.prevOffset)
+			a.reported = true
+		}
+	}
+	a.prev, a.prevOffset = v, offSet
+}
+
+// changeCheck keeps the value of a field in the previous record, to print
+// records only when it changes with -on-change.
+type changeCheck struct {
+	field int
+	prev  string
+	seen  bool
+}
+
+var onChange *changeCheck
+
+// changed reports whether the field in data differs from the previous
+// record. The first record is always a change.
+func (c *changeCheck) changed(data []interface{}) bool {
+	v := fmt.Sprint(data[c.field])
+	changed := !c.seen || v != c.prev
+	c.prev, c.seen = v, true
+	return changed
+}
+
+// resetChecks forgets the previous record of -ascending and -on-change, for
+// the first record of a file decoded on its own.
+func resetChecks() {
+	if ascending != nil {
+		ascending.prev, ascending.reported = nil, false
+	}
+	if onChange != nil {
+		onChange.seen = false
+	}
+}
+
+// recordRange is an inclusive range of 1-based record index.
+type recordRange struct {
+	first, last int
+}
+
+// Records to print given by -records, all records if empty.
+var recordSelect []recordRange
+
+// parseRecordRanges parses a record list like "1,3,5-8". A range like "5-"
+// has no end.
+func parseRecordRanges(s string) (ranges []recordRange) {
+	for _, v := range strings.Split(s, ",") {
+		var r recordRange
+		var err error
+		bounds := strings.SplitN(v, "-", 2)
+		r.first, err = strconv.Atoi(bounds[0])
+		r.last = r.first
+		if err == nil && len(bounds) == 2 {
+			if bounds[1] == "" {
+				r.last = math.MaxInt
+			} else {
+				r.last, err = strconv.Atoi(bounds[1])
+			}
+		}
+		if err != nil || r.first < 1 || r.last < r.first {
+			panic(specError{fmt.Errorf("Invalid record range '%s', should be like 3 or 5-8", v)})
+		}
+		ranges = append(ranges, r)
+	}
+	return
+}
+
+func selectedRecord(idx int) bool {
+	if opt.every > 1 && idx%opt.every != 0 {
+		return false
+	}
+	if recordSelect == nil {
+		return true
+	}
+	for _, r := range recordSelect {
+		if r.first <= idx && idx <= r.last {
+			return true
+		}
+	}
+	return false
+}
+
+// lastSelectedRecord returns the index of the last record to print.
+func lastSelectedRecord() (last int) {
+	if recordSelect == nil {
+		return math.MaxInt
+	}
+	for _, r := range recordSelect {
+		if r.last > last {
+			last = r.last
+		}
+	}
+	return
+}
+
+// With -resync, each record starts with syncWord. Bytes before it are
+// dropped.
+var syncWord []byte
+
+// resync drops bytes in r until syncWord is found, so the next record starts
+// at the sync word.
+func resync(r *bufio.Reader) {
+	skipped := 0
+	for {
+		b, err := r.Peek(len(syncWord))
+		if err != nil || bytes.Equal(b, syncWord) {
+			break
+		}
+		r.Discard(1)
+		skipped++
+	}
+	if skipped != 0 {
+		fmt.Fprintf(diagOutput, "Dropped %d bytes at offset %d to resync\n", skipped, offSet)
+		offSet += skipped
+	}
+}
+
+// parseHexBytes parses hex digits like 0xaa55 into bytes in the order
+// written.
+func parseHexBytes(s string) []byte {
+	b, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"))
+	if err != nil || len(b) == 0 {
+		panic(specError{fmt.Errorf("Invalid hex bytes '%s'", s)})
+	}
+	return b
+}
+
+// expandFields returns the printed fields from the decoded fields in data,
+// with bitfields split, byte fields grouped into strings, masks and scales
+// applied.
+func expandFields(data []interface{}) []interface{} {
+	if specBits != nil {
+		data = splitBits(data)
+	}
+	if bitfields != nil {
+		data = bitfields.expand(data)
+	}
+	if charString != nil {
+		data = charString.group(data)
+	}
+	applyMasks(fieldMasks, data)
+	applyScales(fieldScales, data)
+	return data
+}
+
+// Records cut short by the end of input, reported when met. bprint exits
+// with exitError if there are any.
+var truncatedCnt int
+
+// Inputs which ended with a read error other than EOF.
+var readErrorCnt int
+
+// checkReadErrors makes main exit with an error after inputs which couldn't
+// be read to the end.
+func checkReadErrors() {
+	if readErrorCnt > 0 {
+		panic(fmt.Sprintf("Inputs not read to the end after read errors: %d", readErrorCnt))
+	}
+}
+
+// checkTruncated fails if a record was truncated at EOF. A single one was
+// reported already, only the count of several is.
+func checkTruncated() {
+	if truncatedCnt > 1 {
+		panic(fmt.Sprintf("Records truncated at EOF: %d", truncatedCnt))
+	} else if truncatedCnt > 0 {
+		panic(reportedError{})
+	}
+}
+
+// Records skipped after a read error by -k. bprint exits with exitError if
+// there are any.
+var skippedCnt int
+
+// skipBadRecord reads past the rest of the record in rec after the read
+// error err, the record is recordSize bytes or -rec-size. It returns false
+// if nothing can be read past the error, so reading would never end.
+func skipBadRecord(rec *rawRecorder, recordSize int, err error) bool {
+	if paddedSize > 0 {
+		recordSize = paddedSize
+	}
+	// A read error in the rest of the record is part of the same bad record
+	io.CopyN(io.Discard, rec, int64(recordSize-len(rec.buf)))
+	if len(rec.buf) == 0 {
+		return false
+	}
+	fmt.Fprintf(diagOutput, "Record %d at offset %d: %v, skipped\n", recordCnt+1, offSet, err)
+	offSet += len(rec.buf)
+	rec.reset()
+	skippedCnt++
+	return true
+}
+
+// fixedRecord reports whether the records of formatField all have the same
+// size.
+func fixedRecord(formatField []bprint.FieldType) bool {
+	for _, v := range formatField {
+		switch v {
+		case bprint.STRZ, bprint.ULEB, bprint.SLEB, bprint.ALIGN:
+			return false
+		}
+		if v.IsArray() {
+			return false
+		}
+	}
+	return true
+}
+
+// checkSkipped fails if a record was skipped by -k.
+func checkSkipped() {
+	if skippedCnt > 0 {
+		panic(fmt.Sprintf("Records skipped after read errors: %d", skippedCnt))
+	}
+}
+
+// checkNoMoreData reports data left in r after the records expected by -r.
+func checkNoMoreData(r io.Reader) {
+	var b [1]byte
+	if n, _ := io.ReadFull(r, b[:]); n > 0 {
+		panic(fmt.Sprintf("Input has more than the %d records expected by -r", opt.expectRecords))
+	}
+}
+
+// dumpRecords reads records until the end of binReader, printing each one
+// using opt.printFmt.
+func dumpRecords(binReader io.Reader, formatField []bprint.FieldType, recordSize int) {
+	startOutput()
+	if _, partial := decodeInput(binReader, formatField, recordSize); !partial {
+		printEndOffset()
+	}
+	endOutput()
+}
+
+// Records printed and bytes of the records read, of all inputs, for -n and
+// -count-only.
+var (
+	printedCnt   int
+	countedBytes int
+)
+
+// startOutput prints what comes before the records of all inputs, like the
+// header line.
+func startOutput() {
+	printedCnt, countedBytes = 0, 0
+	if opt.outBOM {
+		output.Write(utf8BOM)
+	}
+	if opt.goBytes {
+		fmt.Fprintln(output, "[]byte{")
+	}
+	if opt.pretty || opt.table || opt.columnar || opt.tsv || opt.goLiteral || opt.cLiteral {
+		prettySpecs = nil
+		for _, v := range findPrintFields(opt.printFmt) {
+			prettySpecs = append(prettySpecs, opt.printFmt[v[0]:v[1]])
+		}
+	}
+	if opt.tsv && opt.header {
+		printTSVHeader(len(prettySpecs))
+	}
+	if opt.csv && (opt.header || opt.nameHeader) {
+		printCSVHeader(len(findPrintFields(opt.printFmt)))
+	}
+	if opt.nameHeader && printFmtOutput() {
+		fmt.Fprintln(output, strings.Join(cellNames(len(findPrintFields(opt.printFmt))), " "))
+	}
+}
+
+// decodeInput decodes and prints the records of an input. stopped reports
+// reading stopped before its end by -until, -records or -n, so the inputs
+// after it aren't read either. partial reports the offset at the end of the
+// input mustn't be printed: it ended with a partial record or trailing bytes
+// which were printed, or reading stopped after the last record selected by
+// -records, which isn't the end.
+func decodeInput(binReader io.Reader, formatField []bprint.FieldType, recordSize int) (stopped, partial bool) {
+	var syncReader *bufio.Reader
+	if syncWord != nil {
+		var ok bool
+		if syncReader, ok = binReader.(*bufio.Reader); !ok {
+			syncReader = bufio.NewReader(binReader)
+		}
+		binReader = syncReader
+	}
+	var chunks *chunkSplitter
+	if inputSep != nil {
+		chunks = &chunkSplitter{r: bufio.NewReader(binReader), sep: inputSep}
+	}
+	rec := &rawRecorder{r: binReader}
+	dataLen := len(bprint.DataFields(formatField))
+	if array != nil {
+		dataLen++
+	}
+	data := make([]interface{}, dataLen, dataLen)
+	// The last field is repeated to the end of input by '*'
+	dataFields := bprint.DataFields(formatField)
+	toEnd := array == nil && len(dataFields) > 0 && dataFields[len(dataFields)-1].IsArray()
+	n := 0
+	// Records before this input, -r counts the records of each input
+	firstCnt := recordCnt
+	firstOffset := offSet
+	var err error
+	for {
+		if syncReader != nil {
+			resync(syncReader)
+		}
+		var chunk []byte
+		if chunks != nil {
+			var ok bool
+			if chunk, ok = chunks.next(); !ok {
+				n, err = 0, io.EOF
+				break
+			}
+			rec.r = bytes.NewReader(chunk)
+		}
+		n, err = readRecord(rec, formatField, data)
+		if err == io.ErrUnexpectedEOF && n == dataLen && toEnd {
+			// The record is whole, only the last element of its list is cut
+			fmt.Fprintf(diagOutput, "Record %d at offset %d: partial list element at EOF dropped\n",
+				recordCnt+1, offSet)
+			err = nil
+		}
+		if err != nil {
+			if chunks != nil && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+				// Only this chunk is short, the next one is another record
+				if len(chunk) == 0 {
+					fmt.Fprintf(diagOutput, "Empty chunk at offset %d skipped\n", offSet)
+				} else {
+					// Printed like a partial record at EOF
+					printPartialRecord(rec, data, n, formatField)
+					endGroup()
+					fmt.Fprintf(diagOutput, "Record %d at offset %d: truncated at end of chunk after %d of %d fields\n",
+						recordCnt+1, offSet, n, dataLen)
+					truncatedCnt++
+				}
+				offSet += len(chunk) + chunks.sepLen
+				rec.reset()
+				n, err = 0, nil
+				continue
+			}
+			if opt.keepGoing && err != io.EOF && err != io.ErrUnexpectedEOF {
+				if skipBadRecord(rec, recordSize, err) {
+					continue
+				}
+			}
+			break
+		}
+		if chunks != nil {
+			// The rest of the chunk belongs to the record
+			io.Copy(io.Discard, rec)
+		}
+		fields := expandFields(data)
+		if sentinel != nil && sentinel(fields, rec.buf) {
+			rec.reset()
+			n, err, stopped = 0, io.EOF, true
+			break
+		}
+		if len(rec.buf) == 0 {
+			// Nothing would ever be consumed, reading again loops forever
+			panic(fmt.Sprintf("Record at offset %d consumed no data, binary format has no size", offSet))
+		}
+		if len(rec.buf) < opt.minBytes {
+			// A runt frame, usually garbage between synced records
+			fmt.Fprintf(diagOutput, "Dropped %d byte record at offset %d, shorter than %d bytes\n",
+				len(rec.buf), offSet, opt.minBytes)
+			offSet += len(rec.buf)
+			rec.reset()
+			continue
+		}
+		recordCnt++
+		if paddedSize > 0 {
+			skipPadding(rec)
+		}
+		checkRanges(fields)
+		if ascending != nil {
+			ascending.check(fields)
+		}
+		changed := onChange == nil || onChange.changed(fields)
+		if changed && selectedRecord(recordCnt) && (recordFilter == nil || isTrue(recordFilter(fields))) {
+			printRecord(fields, rec.buf)
+			printedCnt++
+		}
+		if opt.flushEvery > 0 && recordCnt%opt.flushEvery == 0 {
+			flushOutput()
+		}
+		offSet += len(rec.buf)
+		if chunks != nil {
+			offSet += chunks.sepLen
+		}
+		if progress != nil {
+			progress.update(len(rec.buf))
+		}
+		rec.reset()
+		if opt.expectRecords >= 0 && recordCnt-firstCnt == opt.expectRecords {
+			checkNoMoreData(rec.r)
+			n, err = 0, io.EOF
+			break
+		}
+		if lastSelectedRecord() <= recordCnt {
+			// No more record to print, nor the offset of the rest
+			n, err, stopped, partial = 0, io.EOF, true, true
+			break
+		}
+		if opt.limit > 0 && printedCnt >= opt.limit {
+			n, err, stopped = 0, io.EOF, true
+			break
+		}
+	}
+	// Not enough data for the final line, print out what have been read
+	if printPartialRecord(rec, data, n, formatField) {
+		partial = true
+	}
+	endGroup()
+	if opt.expectRecords >= 0 && recordCnt-firstCnt < opt.expectRecords && !stopped {
+		panic(fmt.Sprintf("Input has %d complete records, %d expected by -r", recordCnt-firstCnt, opt.expectRecords))
+	}
+	// Whole records only, a partial record at EOF is reported as usual
+	countedBytes += offSet - firstOffset
+	flushOutput()
+	if err != io.EOF {
+		if err == io.ErrUnexpectedEOF {
+			fmt.Fprintf(diagOutput, "Record %d at offset %d: truncated at EOF after %d of %d fields\n",
+				recordCnt+1, offSet, n, dataLen)
+			if opt.byteLimit == "" {
+				// A record cut by -L is expected
+				truncatedCnt++
+			}
+		} else {
+			fmt.Fprintln(diagOutput, "While reading data:", err)
+			readErrorCnt++
+		}
+	}
+	return
+}
+
+// printPartialRecord prints a record cut after n fields by the end of input
+// or of its chunk, its bytes are in rec. A partial record can't be tested
+// against the filter, so it's only printed without one. It returns false if
+// the record has no byte.
+func printPartialRecord(rec *rawRecorder, data []interface{}, n int, formatField []bprint.FieldType) bool {
+	switch {
+	case opt.dumpTrailing && len(rec.buf) != 0:
+		endGroup()
+		printTrailing(rec.buf)
+	case n != 0 || (opt.goBytes && len(rec.buf) != 0):
+		if recordFilter == nil && selectedRecord(recordCnt+1) {
+			read := n
+			if opt.padShort {
+				read = padRecord(data, n, formatField)
+			}
+			fields := expandFields(data[:read])
+			printRecord(fields, rec.buf)
+		}
+	case len(rec.buf) != 0:
+		// Not a byte of a field, only the offset of the record is printed
+		printEndOffset()
+	default:
+		return false
+	}
+	return true
+}
+
+// printEndOffset prints the offset after the records read, at the end of
+// input.
+func printEndOffset() {
+	if opt.printOffset && printFmtOutput() {
+		endGroup()
+		fmt.Fprintf(recordOutput(), offsetFmt+recordEnd, offSet)
+	}
+}
+
+// endOutput prints what comes after the records of all inputs, like the
+// -stats table.
+func endOutput() {
+	if opt.goBytes {
+		fmt.Fprintln(output, "}")
+	}
+	if opt.countOnly {
+		fmt.Fprintf(output, "%d records, %d bytes\n", printedCnt, countedBytes)
+	}
+	if opt.stats {
+		printStats(output)
+	}
+	if opt.table {
+		printTable(output)
+	}
+	if histogram != nil {
+		histogram.print(output)
+	}
+	if opt.columnar {
+		printColumns(output)
+	}
+	flushOutput()
+}
+
+func openFile(path string) (reader io.Reader, ioReader io.ReadCloser) {
+	if path == "" {
+		ioReader = os.Stdin
+	} else if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		ioReader = openURL(path)
+	} else if strings.HasPrefix(path, "tcp://") || strings.HasPrefix(path, "unix://") {
+		ioReader = openSocket(path)
+	} else {
+		var err error
+		ioReader, err = os.Open(path)
+		if err != nil {
+			panic(fmt.Sprint("While opening file: ", err))
+		}
+	}
+	reader = ioReader
+	if opt.follow {
+		reader = followFile(reader, ioReader)
+	}
+	if opt.retry > 0 {
+		reader = bufio.NewReader(&retryReader{r: reader, retries: opt.retry})
+	} else {
+		reader = bufio.NewReader(reader)
+	}
+	if opt.gunzip {
+		reader = gunzip(reader.(*bufio.Reader))
+	}
+	if opt.hexInput {
+		reader = bufio.NewReader(newHexReader(reader))
+	} else if opt.decodeDump {
+		reader = bufio.NewReader(newDumpReader(reader))
+	}
+	return
+}
+
+// gunzip returns a reader of the decompressed input if r starts with the
+// gzip magic, otherwise r itself.
+func gunzip(r *bufio.Reader) io.Reader {
+	if magic, _ := r.Peek(2); !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return r
+	}
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		panic(fmt.Sprintf("While reading gzip input: %v", err))
+	}
+	return bufio.NewReader(zr)
+}
+
+// parseByteCount parses the byte count of option name like -s, in decimal
+// or 0x prefixed hex.
+func parseByteCount(name, s string) int64 {
+	n, err := strconv.ParseInt(s, 0, 64)
+	if err != nil || n < 0 {
+		panic(specError{fmt.Errorf("Invalid %s '%s', should be a byte count like 512 or 0x200", name, s)})
+	}
+	return n
+}
+
+// skipHeader discards the first n bytes of binReader, reading from f. f is
+// seeked if it's a regular file, otherwise like for stdin and pipes the
+// bytes are read and discarded. offSet starts after the skipped bytes, an
+// input shorter than n is an error.
+func skipHeader(binReader io.Reader, f io.ReadCloser, n int64) {
+	offSet = int(n)
+	// Hex text and gzip input can't be seeked, n is a count of decoded bytes
+	if file, ok := f.(*os.File); ok && !opt.hexInput && !opt.decodeDump && !opt.gunzip {
+		// binReader has nothing buffered yet. Seeking a pipe fails, and
+		// seeking past the end doesn't, so the size is checked
+		if fi, err := file.Stat(); err == nil && fi.Mode().IsRegular() {
+			if _, err := file.Seek(n, io.SeekStart); err == nil {
+				if fi.Size() < n {
+					panic(fmt.Sprintf("While skipping %d bytes: input has only %d bytes", n, fi.Size()))
+				}
+				return
+			}
+		}
+	}
+	skipped, err := io.CopyN(io.Discard, binReader, n)
+	if err == io.EOF {
+		panic(fmt.Sprintf("While skipping %d bytes: input has only %d bytes", n, skipped))
+	} else if err != nil {
+		panic(fmt.Sprintf("While skipping %d bytes: %v", n, err))
+	}
+}
+
+// Wait before the first retry of a failed read, doubled for each retry.
+var retryBackoff = 10 * time.Millisecond
+
+// retryReader retries reads from r which fail with an error other than EOF,
+// for streams which may return temporary errors.
+type retryReader struct {
+	r       io.Reader
+	retries int
+}
+
+func (rr *retryReader) Read(p []byte) (n int, err error) {
+	for i := 0; ; i++ {
+		n, err = rr.r.Read(p)
+		if err == nil || err == io.EOF || i == rr.retries {
+			return
+		}
+		if n > 0 {
+			// Return what's read, the next read will meet the error again
+			// if it's not transient
+			return n, nil
+		}
+		time.Sleep(retryBackoff << uint(i))
+	}
+}
+
+// openURL returns the body of a http(s) GET request to url.
+func openURL(url string) io.ReadCloser {
+	client := &http.Client{Timeout: opt.timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		panic(fmt.Sprintf("While opening URL: %v", err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		panic(fmt.Sprintf("While opening URL %s: %s", url, resp.Status))
+	}
+	return resp.Body
+}
+
+// openSocket returns a connection to a socket url like tcp://host:port or
+// unix:///path/sock, read until the peer closes it. -timeout limits the time
+// to connect, not the reads of a live stream.
+func openSocket(url string) io.ReadCloser {
+	network, addr, _ := strings.Cut(url, "://")
+	conn, err := net.DialTimeout(network, addr, opt.timeout)
+	if err != nil {
+		panic(fmt.Sprintf("While connecting to %s: %v", url, err))
+	}
+	return conn
+}
+
+// Characters used by -viz for byte values from low to high.
+var vizShades = []rune(" ░▒▓█")
+
+const vizLineBytes = 64
+
+func vizChar(b byte) rune {
+	return vizShades[int(b)*len(vizShades)/256]
+}
+
+// vizDump prints each byte in r as a shade character, as a quick visual of
+// the data. vizLineBytes bytes are printed on a line.
+func vizDump(r io.Reader, w io.Writer) {
+	buf := make([]byte, vizLineBytes)
+	offset := offSet
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if opt.printOffset {
+				fmt.Fprintf(w, offsetFmt, offset)
+			}
+			line := make([]rune, n)
+			for i, b := range buf[:n] {
+				line[i] = vizChar(b)
+			}
+			fmt.Fprintln(w, string(line))
+			offset += n
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return
+		} else if err != nil {
+			panic(fmt.Sprintf("While reading data: %v", err))
+		}
+	}
+}
+
+// printRecordSize reports the size and field count of a record given by the
+// binary format.
+func printRecordSize(w io.Writer, recordSize, fieldCnt int) {
+	fmt.Fprintf(w, "Record size %d bytes, %d fields\n", recordSize, fieldCnt)
+}
+
+// printCheck reports the formats checked by -check, the print format is
+// shown expanded, after repeats and the defaults for empty verbs.
+func printCheck(w io.Writer, recordSize, fieldCnt int, printFmt string) {
+	printRecordSize(w, recordSize, fieldCnt)
+	fmt.Fprintf(w, "Print format: %s\n", printFmt)
+}
+
+// printFieldTypes reports the Go type of the decoded value of each field,
+// which decides how print verbs format it.
+func printFieldTypes(w io.Writer, fields []bprint.FieldType) {
+	names := make([]string, len(fields))
+	for i, v := range fields {
+		if v.IsArray() {
+			names[i] = "[]interface {}"
+		} else {
+			names[i] = goTypeNames[v]
+		}
+	}
+	fmt.Fprintf(w, "Field types: %s\n", strings.Join(names, " "))
+}
+
+// printSpecTree prints the fields and groups of the binary format with their
+// offset and size, the fields of a group are indented below it. Offsets
+// after a z string are from its minimum size.
+func printSpecTree(w io.Writer, nodes []bprint.SpecNode) {
+	printSpecNodes(w, nodes, "", "f", 0)
+}
+
+func printSpecNodes(w io.Writer, nodes []bprint.SpecNode, indent, prefix string, offset int) {
+	field, group := 0, 0
+	for _, n := range nodes {
+		repeat := ""
+		if n.Repeat == bprint.RepeatToEnd {
+			repeat = " x*"
+		} else if n.Repeat > 1 {
+			repeat = fmt.Sprintf(" x%d", n.Repeat)
+		}
+		if n.Group != nil {
+			name := n.Name
+			if name == "" {
+				name = fmt.Sprintf("g%d", group)
+			}
+			fmt.Fprintf(w, "%s%s group%s offset %d size %d\n", indent, name, repeat, offset, n.Size())
+			printSpecNodes(w, n.Group, indent+"  ", "", offset)
+			field += specNodeCnt(n)
+			group++
+		} else {
+			// Skipped bytes and byte order markers are not a field
+			name := "-"
+			if n.Type.IsData() {
+				if n.Name != "" {
+					name = n.Name
+				} else {
+					name = fmt.Sprintf("%s%d", prefix, field)
+					if n.Repeat > 1 {
+						name += fmt.Sprintf("-%s%d", prefix, field+n.Repeat-1)
+					}
+				}
+				field += specNodeCnt(n)
+			}
+			typ := n.Type.String()
+			if n.Type == bprint.STR {
+				typ += fmt.Sprintf(":%d", n.Len)
+			}
+			if n.Bits != nil {
+				ranges := make([]string, len(n.Bits))
+				for i, r := range n.Bits {
+					ranges[i] = fmt.Sprintf("%d-%d", r.Lo, r.Hi)
+				}
+				typ += " bits " + strings.Join(ranges, ",")
+			}
+			fmt.Fprintf(w, "%s%s %s%s offset %d size %d\n", indent, name, typ, repeat, offset, n.Size())
+		}
+		offset += n.Size()
+	}
+}
+
+// specFieldCnt returns the number of values decoded for nodes.
+func specFieldCnt(nodes []bprint.SpecNode) (cnt int) {
+	for _, n := range nodes {
+		if n.Group != nil || n.Type.IsData() {
+			cnt += specNodeCnt(n)
+		}
+	}
+	return
+}
+
+// specNodeCnt returns the number of values decoded for a group or data
+// field node, a node repeated to the end is a single list.
+func specNodeCnt(n bprint.SpecNode) int {
+	if n.Repeat == bprint.RepeatToEnd {
+		return 1
+	}
+	if n.Group != nil {
+		return n.Repeat * specFieldCnt(n.Group)
+	}
+	return n.Repeat
+}
+
+// offsetString returns the offset off printed with the offset format, without
+// the separator after it.
+func offsetString(off int) string {
+	return strings.TrimRight(fmt.Sprintf(offsetFmt, off), " \t")
+}
+
+// printExplain prints each field of the record with its name, type, size
+// and offset in the record for -explain. Offsets are in the format of -o,
+// and unknown after a variable size field.
+func printExplain(w io.Writer, formatField []bprint.FieldType, names []string) {
+	off, field := 0, 0
+	fixed := true
+	for i, t := range formatField {
+		switch t {
+		case bprint.SKIP:
+			off++
+			continue
+		case bprint.BACK:
+			off--
+			continue
+		case bprint.STRTAIL, bprint.ALIGN, bprint.ALIGNTAIL, bprint.LITTLE, bprint.BIG:
+			// The offset after ALIGN is already unknown
+			continue
+		}
+		name := fmt.Sprintf("f%d", field)
+		if field < len(names) && names[field] != "" {
+			name = names[field]
+		}
+		offStr := "?"
+		if fixed {
+			offStr = offsetString(off)
+		}
+		if t.IsArray() {
+			var elem []string
+			for _, v := range bprint.DataFields(formatField[i+1:]) {
+				elem = append(elem, v.String())
+			}
+			fmt.Fprintf(w, "%s array of %s size var offset %s\n", name, strings.Join(elem, " "), offStr)
+			break
+		}
+		size := t.Size()
+		for j := i + 1; t == bprint.STR && j < len(formatField) && formatField[j] == bprint.STRTAIL; j++ {
+			size++
+		}
+		if size == 0 {
+			fmt.Fprintf(w, "%s %s size var offset %s\n", name, t.String(), offStr)
+			fixed = false
+		} else {
+			fmt.Fprintf(w, "%s %s size %d offset %s\n", name, t.String(), size, offStr)
+			off += size
+		}
+		field++
+	}
+}
+
+// autoCountFields repeats the single field in formatField, with its string
+// bytes or skipped bytes, to cover the whole file at path, so the file is
+// decoded as one record.
+func autoCountFields(formatField []bprint.FieldType, recordSize int, path string) ([]bprint.FieldType, int) {
+	if n := len(bprint.DataFields(formatField)); n != 1 {
+		panic(specError{fmt.Errorf("-auto-count needs a binary format with one field, got %d", n)})
+	}
+	if path == "" {
+		panic(specError{errors.New("-auto-count needs a file to get the size from")})
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		panic(fmt.Sprintf("While getting file size: %v", err))
+	}
+	if recordSize == 0 {
+		panic(specError{errors.New("-auto-count needs a fixed size field")})
+	}
+	cnt := int(info.Size()) / recordSize
+	if cnt == 0 {
+		panic(fmt.Sprintf("File %s is smaller than one %d byte field", path, recordSize))
+	}
+	fields := make([]bprint.FieldType, 0, cnt*len(formatField))
+	for i := 0; i < cnt; i++ {
+		fields = append(fields, formatField...)
+	}
+	return fields, cnt * recordSize
+}
+
+// inferRepeat sets the count of the last node of tree, repeated to the end
+// with '*', so that the file at path, after skip bytes and up to limit bytes
+// if limit isn't negative, has cnt records of the same size, for -r with a
+// binary format like "S C*".
+func inferRepeat(tree []bprint.SpecNode, cnt int, path string, skip, limit int64) ([]bprint.FieldType, []string, int) {
+	if path == "" || path == "-" {
+		panic(specError{fmt.Errorf("-r %d with a format repeated to the end needs a file to get the size from", cnt)})
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		panic(fmt.Sprintf("While getting file size: %v", err))
+	}
+	last := len(tree) - 1
+	head, _, headSize, _ := bprint.FlattenSpec(tree[:last])
+	elem := tree[last]
+	elem.Repeat = 1
+	elemField, _, elemSize, _ := bprint.FlattenSpec([]bprint.SpecNode{elem})
+	for _, v := range append(head, elemField...) {
+		switch v {
+		case bprint.STRZ, bprint.ULEB, bprint.SLEB, bprint.ALIGN, bprint.BACK:
+			panic(specError{fmt.Errorf("-r can't infer the record size of a format with the variable size %s", v)})
+		}
+	}
+	size := info.Size() - skip
+	if size < 0 {
+		size = 0
+	}
+	if limit >= 0 && size > limit {
+		size = limit
+	}
+	if size%int64(cnt) != 0 {
+		panic(fmt.Sprintf("File %s with %d bytes to decode can't be split into %d records of the same size", path, size, cnt))
+	}
+	recordSize := int(size) / cnt
+	if recordSize < headSize || elemSize == 0 || (recordSize-headSize)%elemSize != 0 {
+		panic(fmt.Sprintf("Record size %d inferred from -r doesn't fit the format, it should be %d bytes plus a multiple of %d", recordSize, headSize, elemSize))
+	}
+	nodes := append([]bprint.SpecNode{}, tree...)
+	nodes[last].Repeat = (recordSize - headSize) / elemSize
+	fields, names, _, err := bprint.FlattenSpec(nodes)
+	if err != nil {
+		panic(specError{err})
+	}
+	return fields, names, recordSize
+}
+
+// regularFile reports whether path is a regular file, which has a size.
+func regularFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// openOutput opens the output file at path, appending to it instead of
+// truncating if appendMode is set.
+func openOutput(path string, appendMode bool) *os.File {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendMode {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		panic(fmt.Sprintf("While opening output file: %v", err))
+	}
+	return f
+}
+
+func repeatWithSep(rep, sep string, cnt int) string {
+	printFmt := strings.Repeat(rep+sep, cnt)
+	return printFmt[:len(printFmt)-len(sep)]
+}
+
+// defaultPrintSpec returns the print field used for t when there's no print
+// format.
+func defaultPrintSpec(t bprint.FieldType) string {
+	if t.IsArray() {
+		return "%v"
+	}
+	switch t {
+	case bprint.RGB, bprint.RGBA, bprint.GUID, bprint.STRZ, bprint.STR:
+		return "%s"
+	case bprint.F32, bprint.F64, bprint.F16:
+		return "%g"
+	case bprint.I128, bprint.U128:
+		return "%032x"
+	}
+	return "%02x"
+}
+
+// terminalBinaryFmt returns the default binary format for a terminal of
+// width columns, with as many bytes per record as fit on a line, by 4 bytes
+// from 4. It's the default C16 if the width isn't known.
+func terminalBinaryFmt(width int) string {
+	if width <= 0 {
+		return defautlBinaryFmt
+	}
+	// A byte is 2 hex digits and a separator, and a character with -a
+	byteWidth, lineWidth := 2+len(fieldSep), width+len(fieldSep)
+	if opt.printOffset {
+		lineWidth -= len(fmt.Sprintf(offsetFmt, 0))
+	}
+	if opt.printRecordCnt {
+		lineWidth -= len(fmt.Sprintf(countFmt, 0))
+	}
+	if opt.ascii {
+		byteWidth++
+		lineWidth -= len("  ||")
+	}
+	n := lineWidth / byteWidth / 4 * 4
+	if n < 4 {
+		n = 4
+	}
+	return fmt.Sprintf("C%d", n)
+}
+
+func generatePrintFmt(formatField []bprint.FieldType, sep string) string {
+	spec := make([]string, len(formatField))
+	for i, v := range formatField {
+		spec[i] = defaultPrintSpec(v)
+		if i < len(hexDigits) && hexDigits[i] > 0 {
+			// All the digits of the field with -hexbytes
+			spec[i] = fmt.Sprintf("%%0%dx", hexDigits[i])
+		}
+	}
+	return strings.Join(spec, sep)
+}
+
+// Escapes of the separator of N# repeats, for separators hard to type.
+var repeatSepEscaper = strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n")
+
+func processPrintFmt(printFmt string) (string, error) {
+	// Format like "%02d[sep]8#", "%d" will be repeated 8 times, with
+	// seperator inserted. The # is used to mark the end of separator and repeat count,
+	// it's not necessary, only to make it easier to see where is the end of the field.
+	// The separator defaults to a space or -d, "" stands for no separator.
+	// \t, \n and \\ in the separator are a tab, a newline and a backslash.
+	printFieldPat, err := regexp.Compile("(%[^" + printVerbs + "%]*[" + printVerbs + "])([^\\d]*)(\\d+)#")
+	if err != nil {
+		return "", err
+	}
+	mat := printFieldPat.FindAllStringSubmatchIndex(printFmt, -1)
+	if mat == nil {
+		return printFmt, nil
+	}
+
+	buf := new(bytes.Buffer)
+	prevIdx := 0
+	for _, v := range mat {
+		buf.WriteString(printFmt[prevIdx:v[0]])
+		prevIdx = v[1]
+		if v[0] > 0 && printFmt[v[0]-1] == '%' {
+			// Do not parse field following %%
+			buf.WriteString(printFmt[v[0]:v[1]])
+			continue
+		}
+
+		field := printFmt[v[2]:v[3]]
+		sep := printFmt[v[4]:v[5]]
+		cntStr := printFmt[v[6]:v[7]]
+		if sep == "" {
+			sep = fieldSep
+		} else if sep == `""` {
+			// Explicitly empty, like %02x""8#
+			sep = ""
+		} else {
+			sep = repeatSepEscaper.Replace(sep)
+		}
+		cnt, err := strconv.Atoi(cntStr)
+		if err != nil {
+			return "", fmt.Errorf("Invalid repeat count '%s' in print format", cntStr)
+		}
+
+		buf.WriteString(repeatWithSep(field, sep, cnt))
+	}
+	buf.WriteString(printFmt[prevIdx:])
+
+	return buf.String(), nil
+}
+
+func countPrintFmtField(printFmt string) (int, error) {
+	fieldStr := "%[^" + printVerbs + "%]*[" + printVerbs + "]"
+	// fieldStr must have a non-% preceeding or start from the beginning of line
+	printFieldPat, err := regexp.Compile("([^%]{1}" + fieldStr + "|^" + fieldStr + ")")
+	if err != nil {
+		return 0, err
+	}
+
+	return len(printFieldPat.FindAllStringIndex(printFmt, -1)), nil
+}
+
+// Verbs recognized in print format fields. %T prints an integer field as a
+// timestamp, the others are the same as in fmt.
+const printVerbs = "bcdoxXTsvqeEfFgG"
+
+// Print verbs which make sense for float binary fields.
+const floatVerbs = "eEfFgGv"
+
+// Print verbs which make sense for integer binary fields.
+const intVerbs = "bcdoxXT"
+
+func validVerbs(t bprint.FieldType) string {
+	if t.IsArray() {
+		return "v"
+	}
+	switch t {
+	case bprint.RGB, bprint.RGBA, bprint.GUID:
+		return "sv"
+	case bprint.STRZ, bprint.STR:
+		return "sqv"
+	case bprint.F32, bprint.F64, bprint.F16:
+		return floatVerbs
+	case bprint.I128, bprint.U128:
+		// Formatted by big.Int, which has no %c and isn't a timestamp
+		return "bdoxXsv"
+	}
+	return intVerbs
+}
+
+// findPrintFields returns the start and end index of each field in a print
+// format. Any verb is recognized here, %% is skipped.
+func findPrintFields(printFmt string) (fields [][]int) {
+	verbPat, err := regexp.Compile("%[-+# 0]*[0-9]*(\\.[0-9]*)?[a-zA-Z%]")
+	if err != nil {
+		panic(err)
+	}
+	for _, v := range verbPat.FindAllStringIndex(printFmt, -1) {
+		if printFmt[v[1]-1] != '%' {
+			fields = append(fields, v)
+		}
+	}
+	return
+}
+
+// The print fields of the last print format given to printFmtFields.
+var printFmtCache struct {
+	printFmt string
+	fields   [][]int
+}
+
+// printFmtFields returns findPrintFields(printFmt), without parsing the
+// print format again for each record.
+func printFmtFields(printFmt string) [][]int {
+	if printFmtCache.fields == nil || printFmtCache.printFmt != printFmt {
+		printFmtCache.printFmt, printFmtCache.fields = printFmt, findPrintFields(printFmt)
+	}
+	return printFmtCache.fields
+}
+
+// escapeStrings escapes with printableString the string values of data
+// printed with %s by printFmt.
+func escapeStrings(printFmt string, data []interface{}) {
+	for i, v := range printFmtFields(printFmt) {
+		if i >= len(data) {
+			return
+		}
+		if s, ok := data[i].(string); ok && printFmt[v[1]-1] == 's' {
+			data[i] = printableString(s)
+		}
+	}
+}
+
+// shortPrintFmt returns printFmt with only its first cnt print fields, for a
+// partial record at EOF. The text after the last print field, like the
+// record end, is kept.
+func shortPrintFmt(printFmt string, cnt int) string {
+	fields := printFmtFields(printFmt)
+	if cnt >= len(fields) {
+		return printFmt
+	}
+	end := fields[0][0]
+	if cnt > 0 {
+		end = fields[cnt-1][1]
+	}
+	return printFmt[:end] + printFmt[fields[len(fields)-1][1]:]
+}
+
+// checkPrintFmtVerbs makes sure each print field uses a verb suitable for the
+// type of the binary field it prints, so something like %f on an integer
+// field is reported instead of printing garbage.
+func checkPrintFmtVerbs(formatField []bprint.FieldType, printFmt string) {
+	for i, v := range findPrintFields(printFmt) {
+		if i >= len(formatField) {
+			// Field count mismatch is reported by the caller
+			return
+		}
+		if timeFields[i] != nil {
+			// Printed as a timestamp string whatever the verb
+			continue
+		}
+		verb := printFmt[v[1]-1 : v[1]]
+		if !strings.Contains(validVerbs(formatField[i]), verb) {
+			panic(specError{fmt.Errorf("Print field %d '%s' can't be used for %s binary field",
+				i, printFmt[v[0]:v[1]], formatField[i].String())})
+		}
+	}
+}
+
+// Conversion applied to the value of a print field before printing, indexed
+// by field.
+var fieldConv []func(v interface{}) interface{}
+
+// Unit printed after the value of a field, given by -unit.
+var fieldUnits map[int]string
+
+// parseFieldUnits parses a list of field units like "1:°C,2:kPa".
+func parseFieldUnits(s string, fieldCnt int) map[int]string {
+	units := make(map[int]string)
+	for _, v := range strings.Split(s, ",") {
+		idx := strings.Index(v, ":")
+		if idx <= 0 {
+			panic(specError{fmt.Errorf("Invalid unit '%s', should be like 1:kPa", v)})
+		}
+		field, err := strconv.Atoi(v[:idx])
+		if err != nil {
+			panic(specError{fmt.Errorf("Invalid unit '%s', should be like 1:kPa", v)})
+		}
+		if field < 0 || field >= fieldCnt {
+			panic(specError{fmt.Errorf("Unit field %d out of range, record has %d fields", field, fieldCnt)})
+		}
+		units[field] = v[idx+1:]
+	}
+	return units
+}
+
+// Hex digits of the print fields with -hexbytes, 0 for fields which aren't
+// integers, nil without it.
+var hexDigits []int
+
+// hexFieldDigits returns the hex digits of the print fields of types
+// printField, decoded from fields. An integer takes 2 digits a byte, a bit
+// range or a bitfield the digits of its bits.
+func hexFieldDigits(fields, printField []bprint.FieldType) []int {
+	var res []int
+	for i, t := range fields {
+		ranges := specBits[i]
+		for _, r := range ranges {
+			res = append(res, int(r.Hi-r.Lo+4)/4)
+		}
+		if ranges == nil {
+			res = append(res, 2*t.Size())
+		}
+	}
+	if bitfields != nil {
+		split := append([]int{}, res[:bitfields.field]...)
+		for _, w := range bitfields.widths {
+			split = append(split, int(w+3)/4)
+		}
+		res = append(split, res[bitfields.field+1:]...)
+	}
+	if charString != nil {
+		res = append(append(res[:charString.field:charString.field], 0), res[charString.field+charString.cnt:]...)
+	}
+	if array != nil {
+		res = append(res, 0)
+	}
+	if selectedFields != nil {
+		selected := make([]int, len(selectedFields))
+		for j, i := range selectedFields {
+			selected[j] = res[i]
+		}
+		res = selected
+	}
+	if recordChecksum != nil {
+		res = append(res, 8)
+	}
+	for i, t := range printField {
+		if !t.IsInt() && t != bprint.I128 && t != bprint.U128 {
+			res[i] = 0
+		}
+	}
+	return res
+}
+
+// hexConv returns a conversion of an integer of the given hex digits to the
+// unsigned integer of the same bits, like 255 for an int8 -1, for -hexbytes.
+// The print field of the user still formats it.
+func hexConv(digits int) func(v interface{}) interface{} {
+	return func(v interface{}) interface{} {
+		if b, ok := v.(*big.Int); ok {
+			if b.Sign() >= 0 {
+				return b
+			}
+			return new(big.Int).Add(b, new(big.Int).Lsh(big.NewInt(1), uint(4*digits)))
+		}
+		if !isNumber(v) {
+			return v
+		}
+		u := uint64(toInt64(v))
+		if digits < 16 {
+			u &= 1<<(4*digits) - 1
+		}
+		return u
+	}
+}
+
+// convertPrintFields sets up fieldConv for verbs which fmt doesn't
+// understand and for fields with labels, printed as timestamps or with
+// -hexbytes, replacing them with %s in the returned print format.
+func convertPrintFields(printFmt string) string {
+	fields := findPrintFields(printFmt)
+	fieldConv = make([]func(v interface{}) interface{}, len(fields))
+	var buf strings.Builder
+	prev := 0
+	for i, v := range fields {
+		spec := printFmt[v[0]:v[1]]
+		if labels := enumLabels[i]; labels != nil {
+			fieldConv[i] = labelConv(labels, spec)
+			spec = "%s"
+		} else if unit := timeFields[i]; unit != nil {
+			fieldConv[i] = func(v interface{}) interface{} {
+				return unit(toInt64(v)).UTC().Format(opt.timeFormat)
+			}
+			spec = "%s"
+		} else if i < len(hexDigits) && hexDigits[i] > 0 {
+			fieldConv[i] = hexConv(hexDigits[i])
+		} else if spec[len(spec)-1] == 'T' {
+			fieldConv[i] = formatTimestamp
+			spec = spec[:len(spec)-1] + "s"
+		}
+		buf.WriteString(printFmt[prev:v[0]])
+		buf.WriteString(spec)
+		buf.WriteString(strings.ReplaceAll(fieldUnits[i], "%", "%%"))
+		prev = v[1]
+	}
+	buf.WriteString(printFmt[prev:])
+	return buf.String()
+}
+
+// toFloat64 converts a float or integer value to float64.
+func toFloat64(v interface{}) float64 {
+	switch v := v.(type) {
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	case uint64:
+		return float64(v)
+	}
+	return float64(toInt64(v))
+}
+
+func toInt64(v interface{}) int64 {
+	switch v := v.(type) {
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	}
+	panic(fmt.Sprintf("Value %v of type %T is not an integer", v, v))
+}
+
+const (
+	// Seconds from 1904-01-01, the classic Mac OS epoch, to the Unix epoch
+	macEpochOffset = 2082844800
+	// 100 ns intervals from 1601-01-01, the Windows FILETIME epoch, to the
+	// Unix epoch
+	filetimeEpochOffset = 116444736000000000
+)
+
+// epochs converts an integer timestamp to time for each -epoch.
+var epochs = map[string]func(int64) time.Time{
+	"unix": func(v int64) time.Time {
+		return time.Unix(v, 0)
+	},
+	"mac": func(v int64) time.Time {
+		return time.Unix(v-macEpochOffset, 0)
+	},
+	"filetime": func(v int64) time.Time {
+		v -= filetimeEpochOffset
+		return time.Unix(v/1e7, v%1e7*100)
+	},
+	// MS-DOS date in the high 16 bits and time in the low 16 bits, local
+	// time without time zone, taken as UTC.
+	"dos": func(v int64) time.Time {
+		date, tm := int(v>>16&0xffff), int(v&0xffff)
+		return time.Date(date>>9+1980, time.Month(date>>5&0xf), date&0x1f,
+			tm>>11, tm>>5&0x3f, tm&0x1f*2, 0, time.UTC)
+	},
+}
+
+// Converts timestamps for %T, selected with -epoch.
+var epochTime = epochs["unix"]
+
+// Units of Unix timestamps of -t fields.
+var timeUnits = map[string]func(int64) time.Time{
+	"s": func(v int64) time.Time {
+		return time.Unix(v, 0)
+	},
+	"ms": time.UnixMilli,
+	"us": time.UnixMicro,
+}
+
+// Fields printed as Unix timestamps by -t, with their unit.
+var timeFields map[int]func(int64) time.Time
+
+// parseTimeFields parses a list of timestamp fields like "0" or "0:ms,3:us",
+// the unit is seconds by default.
+func parseTimeFields(s string, fields []bprint.FieldType) map[int]func(int64) time.Time {
+	res := make(map[int]func(int64) time.Time)
+	for _, v := range strings.Split(s, ",") {
+		fieldStr, unit := v, "s"
+		if idx := strings.Index(v, ":"); idx >= 0 {
+			fieldStr, unit = v[:idx], v[idx+1:]
+		}
+		field, err := strconv.Atoi(fieldStr)
+		if err != nil || timeUnits[unit] == nil {
+			panic(specError{fmt.Errorf("Invalid timestamp field '%s', should be like 0 or 0:ms, with unit s, ms or us", v)})
+		}
+		if field < 0 || field >= len(fields) {
+			panic(specError{fmt.Errorf("Timestamp field %d out of range, record has %d fields", field, len(fields))})
+		}
+		if !fields[field].IsInt() {
+			panic(specError{fmt.Errorf("Timestamp field %d is %s, should be an integer", field, fields[field].String())})
+		}
+		res[field] = timeUnits[unit]
+	}
+	return res
+}
+
+// formatTimestamp formats an integer as a timestamp from the -epoch, in UTC.
+func formatTimestamp(v interface{}) interface{} {
+	return epochTime(toInt64(v)).UTC().Format(opt.timeFormat)
+}
+
+// readSpecFile reads the binary format given as -e @file, it can span
+// several lines with '#' comments.
+func readSpecFile(path string) string {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		panic(fmt.Sprintf("While reading binary format: %v", err))
+	}
+	return string(buf)
+}
+
+// isSpecFile reports whether the binary format is a file given as @file,
+// "@16" is an offset in the record instead.
+func isSpecFile(spec string) bool {
+	return len(spec) > 1 && spec[0] == '@' && (spec[1] < '0' || spec[1] > '9')
+}
+
+// Bytes of the binary format line read from the input with -self, offsets
+// of records start after it.
+var specLineSize int
+
+// readSpecLine reads the binary format from the first line of r for -self,
+// and returns it with the size of the line.
+func readSpecLine(r *bufio.Reader) (string, int) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF {
+		panic("Input has no binary format line for -self, it should end with a newline")
+	} else if err != nil {
+		panic(fmt.Sprintf("While reading binary format line: %v", err))
+	}
+	return strings.TrimRight(line, "\r\n"), len(line)
+}
+
+// envFormats sets the binary and print format from BPRINT_FMT and
+// BPRINT_PFMT when they're not given by flags or files.
+func envFormats() {
+	if opt.binaryFmt == "" && !flagSet("e") {
+		opt.binaryFmt = os.Getenv("BPRINT_FMT")
+	}
+	if opt.printFmt == "" && !flagSet("p") {
+		opt.printFmt = os.Getenv("BPRINT_PFMT")
+	}
+}
+
+func readOptionFromFile() {
+	f, err := os.Open(opt.formatFile)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	buf := bufio.NewReader(f)
+	const fmtCnt = 2
+	line := [fmtCnt]string{}
+	for i := 0; i < fmtCnt; i++ {
+		line[i], err = buf.ReadString('\n')
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			panic(fmt.Sprintf("Error reading from format file %v", err))
+		}
+	}
+	if opt.binaryFmt == "" {
+		opt.binaryFmt = strings.TrimRight(line[0], "\n")
+	}
+	if opt.printFmt == "" {
+		opt.printFmt = strings.TrimRight(line[1], "\n")
+	}
+}
+
+const (
+	defautlBinaryFmt = "C16"
+)
+
+var opt struct {
+	printRecordCnt bool
+	printOffset    bool
+	printVersion   bool
+	littleEndian   bool
+	bigEndian      bool
+	binaryFmt      string
+	printFmt       string
+	formatFile     string
+	filter         string
+	goBytes        bool
+	timeFormat     string
+	timeout        time.Duration
+	outBOM         bool
+	retry          int
+	schemaFile     string
+	emitSchema     string
+	zigzag         bool
+	autoCount      bool
+	pretty         bool
+	resync         string
+	rangeCheck     string
+	outFile        string
+	appendOut      bool
+	cBitfields     string
+	stats          bool
+	flushEvery     int
+	flush          bool
+	guessEndian    bool
+	array          string
+	printSize      bool
+	viz            bool
+	records        string
+	strTerm        string
+	swapFields     string
+	offsetDelta    bool
+	jsonOutput     bool
+	goLiteral      bool
+	cLiteral       bool
+	jsonStrNums    bool
+	recSize        int
+	warnPadding    bool
+	table          bool
+	maxMem         string
+	epoch          string
+	dumpTrailing   bool
+	types          bool
+	explain        bool
+	raw            bool
+	fieldOffsets   bool
+	align          bool
+	keepGoing      bool
+	enumFiles      stringList
+	enums          stringList
+	histBuckets    string
+	inferRecsize   bool
+	columnar       bool
+	recordHash     string
+	checksum       string
+	until          string
+	linePad        int
+	lineLong       string
+	ascending      int
+	strictAsc      bool
+	asString       string
+	stringTrim     string
+	tsv            bool
+	header         bool
+	mask           string
+	bitWidth       string
+	inputSep       string
+	minBytes       int
+	tmpl           string
+	tmplFile       string
+	jobs           int
+	onChange       int
+	nativeEndian   bool
+	prefix         string
+	suffix         string
+	emptyFmt       bool
+	ascii          bool
+	specTree       bool
+	limit          int
+	every          int
+	unit           string
+	skip           string
+	nameHeader     bool
+	perFile        bool
+	banner         bool
+	hexInput       bool
+	decodeDump     bool
+	hexBytes       bool
+	byteLimit      string
+	offsetFmt      string
+	gunzip         bool
+	csv            bool
+	scale          string
+	fixedPoint     string
+	timeField      string
+	pack           bool
+	forceUnsigned  bool
+	forceSigned    bool
+	check          bool
+	nulEnd         bool
+	expectRecords  int
+	color          colorMode
+	fieldSelect    string
+	countOnly      bool
+	progress       bool
+	group          int
+	countWidth     int
+	selfSpec       bool
+	encoding       string
+	padShort       bool
+	fieldSep       string
+	follow         bool
+}
+
+func init() {
+	flag.StringVar(&opt.binaryFmt, "e", "",
+		"binary format string. c,s,l,q for signed 8,16,32,64-bit int. Upper case for unsigned int. @file reads it from a file")
+	flag.StringVar(&opt.printFmt, "p", "",
+		"printf style format string, size is implicit from binary format string, default to %02x for each field")
+	flag.StringVar(&opt.formatFile, "f", "",
+		"read binary and print format from file. 1st line for binary format, 2nd line for print format (optional)\n\t "+
+			"command line option overrides option in file")
+	flag.BoolVar(&opt.printVersion, "version", false,
+		"print version information")
+	flag.StringVar(&opt.fieldSep, "d", " ",
+		"separator of the fields of the default print format and of N# repeats without one, with Go escapes like \\t")
+	flag.BoolVar(&opt.printRecordCnt, "c", false,
+		"print record count")
+	flag.IntVar(&opt.countWidth, "cw", 0,
+		"zero-pad the -c record count to this many digits, so it's aligned")
+	flag.BoolVar(&opt.printOffset, "o", false,
+		"print offset")
+	flag.StringVar(&opt.filter, "filter", "",
+		"only print records for which the expression is true, e.g. \"f0 > 100 && f2 == 0xff\"")
+	flag.StringVar(&opt.filter, "where", "",
+		"same as -filter")
+	flag.BoolVar(&opt.goBytes, "go-bytes", false,
+		"print the bytes consumed by records as a Go []byte literal, one line per record")
+	flag.StringVar(&opt.timeFormat, "time-format", time.RFC3339,
+		"Go time layout used for %T print fields and -t fields")
+	flag.DurationVar(&opt.timeout, "timeout", 0,
+		"timeout when reading from a http:// or https:// URL or connecting to a tcp:// or unix:// socket, 0 means no timeout")
+	flag.BoolVar(&opt.outBOM, "out-bom", false,
+		"write a UTF-8 BOM at the start of output, for tools like Excel")
+	flag.IntVar(&opt.retry, "retry", 0,
+		"retry a failed read up to this many times before giving up, for sockets and pipes")
+	flag.BoolVar(&opt.keepGoing, "k", false,
+		"keep going after a read error, skipping the record with the error, for damaged media")
+	flag.StringVar(&opt.schemaFile, "schema", "",
+		"read formats and options from a JSON schema file written by -emit-schema\n\t "+
+			"command line option overrides option in file")
+	flag.StringVar(&opt.emitSchema, "emit-schema", "",
+		"write formats and options to a JSON schema file and exit")
+	flag.BoolVar(&opt.zigzag, "zigzag", false,
+		"zigzag decode unsigned fields into signed values, as used by protobuf")
+	flag.BoolVar(&opt.autoCount, "auto-count", false,
+		"decode the whole file as one record of the single binary field, count is computed from file size")
+	flag.BoolVar(&opt.pretty, "pretty", false,
+		"print each record as an aligned block of name : value lines")
+	flag.StringVar(&opt.resync, "resync", "",
+		"hex bytes like 0xaa55 each record starts with, bytes before it are dropped")
+	flag.StringVar(&opt.rangeCheck, "range-check", "",
+		"warn on stderr about field values out of inclusive range, like 0:0..100,2:-5..5")
+	flag.StringVar(&opt.outFile, "out", "",
+		"write output to file instead of stdout")
+	flag.StringVar(&opt.outFile, "w", "",
+		"same as -out")
+	flag.BoolVar(&opt.appendOut, "append", false,
+		"append to the -out file instead of truncating it")
+	flag.StringVar(&opt.cBitfields, "cbitfields", "",
+		"split an integer field into C style bitfields, LSB first, like a:3,b:5 for field 0 or 2=a:3,b:5 for field 2")
+	flag.BoolVar(&opt.stats, "stats", false,
+		"print count, min, max, sum and mean of each numeric field at the end instead of records")
+	flag.BoolVar(&opt.stats, "T", false,
+		"same as -stats")
+	flag.BoolVar(&opt.progress, "progress", false,
+		"write the records and bytes decoded and the offset to stderr every second, with a percentage for files")
+	flag.IntVar(&opt.group, "g", 1,
+		"print this many records per line, separated by a space, with the offset and count of the first one")
+	flag.BoolVar(&opt.follow, "F", false,
+		"follow a file being appended to like tail -f, waiting for more records at its end until interrupted")
+	flag.BoolVar(&opt.flush, "flush", false,
+		"flush output after every record, like -flush-every 1, for consumers watching a live feed")
+	flag.IntVar(&opt.flushEvery, "flush-every", 0,
+		"flush output every N records, by default output is flushed when the buffer is full")
+	flag.BoolVar(&opt.guessEndian, "guess-endian", false,
+		"guess byte order from the values of the first records, the guess is reported on stderr")
+	flag.StringVar(&opt.array, "array", "",
+		"read an array after each record, like 1:S for as many uint16 as the value of field 1, printed as a list")
+	flag.Var(&opt.color, "color",
+		"color the fields printed with -p by type: auto (the same as a bare -color) when printing to a terminal, always or never")
+	flag.BoolVar(&opt.nulEnd, "0", false,
+		"end each record printed with -p by a NUL byte instead of a newline, like find -print0")
+	flag.BoolVar(&opt.check, "check", false,
+		"check the binary and print format match, print the record size, field count and expanded print format and exit without reading input")
+	flag.BoolVar(&opt.printSize, "print-size", false,
+		"print record size and field count of the binary format on stderr")
+	flag.BoolVar(&opt.viz, "viz", false,
+		"ignore the formats and print bytes as shade characters for a visual of the data")
+	flag.StringVar(&opt.records, "records", "",
+		"only print records with 1-based index in the list, like 1,3,5-8 or 10-")
+	flag.StringVar(&opt.strTerm, "str-term", "0x00",
+		"hex byte terminating z strings")
+	flag.StringVar(&opt.swapFields, "swap-fields", "",
+		"byte swap the integer fields in the list like 1,3,5-7 after reading")
+	flag.BoolVar(&opt.offsetDelta, "offset-delta", false,
+		"print the number of bytes consumed by each record, like +8, after offset and record count")
+	flag.BoolVar(&opt.jsonOutput, "j", false,
+		"print each record as a JSON object on one line, keyed by field name")
+	flag.BoolVar(&opt.goLiteral, "go", false,
+		"print each record as a Go struct literal on one line, fields printed with %x are in hex")
+	flag.BoolVar(&opt.cLiteral, "c-struct", false,
+		"print each record as a C struct initializer on one line, fields printed with %x are in hex")
+	flag.BoolVar(&opt.jsonStrNums, "json-str-nums", false,
+		"quote integers beyond 2^53 in JSON output, as JavaScript loses their precision")
+	flag.IntVar(&opt.recSize, "recsize", 0,
+		"pad records to this many bytes, bytes after the fields are skipped")
+	flag.IntVar(&opt.recSize, "S", 0,
+		"same as -recsize, as a stride from the start of one record to the next")
+	flag.BoolVar(&opt.warnPadding, "warn-padding", false,
+		"warn on stderr about nonzero padding bytes skipped with -recsize")
+	flag.BoolVar(&opt.table, "table", false,
+		"print records as a table with a header of field names and aligned columns, output starts at EOF")
+	flag.StringVar(&opt.maxMem, "max-mem", "",
+		"approximate limit like 64M of the memory buffering modes like -table and -columnar can use, they abort beyond it")
+	flag.StringVar(&opt.epoch, "epoch", "unix",
+		"epoch of %T timestamps: unix seconds, mac seconds since 1904, filetime 100 ns since 1601 or dos date and time")
+	flag.StringVar(&opt.timeField, "t", "",
+		"print integer fields as Unix timestamps in s, ms or us, like 0 or 0:ms,3:us, whatever their print verb")
+	flag.BoolVar(&opt.forceUnsigned, "u", false,
+		"read all integer fields as unsigned, whatever their case in the binary format")
+	flag.BoolVar(&opt.forceSigned, "i", false,
+		"read all integer fields as signed, whatever their case in the binary format")
+	flag.BoolVar(&opt.selfSpec, "self", false,
+		"read the binary format from the first line of the input, the rest of the input is decoded with it")
+	flag.BoolVar(&opt.pack, "P", false,
+		"pack text into binary: write each input line of whitespace separated values as a record of the binary format")
+	flag.BoolVar(&opt.padShort, "pad", false,
+		"print a record cut short at EOF with zero values for the missing fields, 0 or an empty string")
+	flag.BoolVar(&opt.dumpTrailing, "dump-trailing", false,
+		"print the bytes at EOF not forming a whole record as hex, instead of a partial record")
+	flag.BoolVar(&opt.align, "align", false,
+		"align the fields printed with -p and the offset and count in columns, output is buffered in blocks of 1000 lines")
+	flag.BoolVar(&opt.fieldOffsets, "field-offsets", false,
+		"print the input offset and name of each field before its value, like @0000002 length=258")
+	flag.BoolVar(&opt.hexBytes, "hexbytes", false,
+		"print integer fields as the hex of their bytes, zero padded to their width, like ff for an int8 -1")
+	flag.BoolVar(&opt.raw, "raw", false,
+		"print the bytes of each field in hex in brackets after its value, in input order")
+	flag.BoolVar(&opt.explain, "explain", false,
+		"print the name, type, size and offset in the record of each field on stderr, then decode as usual")
+	flag.BoolVar(&opt.types, "types", false,
+		"print the Go type of the decoded value of each field on stderr")
+	flag.Var(&opt.enums, "enum",
+		"print labels for the values of a field, like 2=0:OK,1:WARN,2:FAIL, can be repeated")
+	flag.Var(&opt.enumFiles, "enum-file",
+		"print labels from a CSV file of value,label lines for a field, like 2:labels.csv, can be repeated")
+	flag.StringVar(&opt.histBuckets, "hist-buckets", "",
+		"print a histogram of a field at the end instead of records, like 0:0,100,10 for 10 buckets of field 0 from 0 to 100")
+	flag.BoolVar(&opt.inferRecsize, "infer-recsize", false,
+		"ignore the formats and print likely record sizes on stderr, found from how bytes repeat")
+	flag.BoolVar(&opt.columnar, "columnar", false,
+		"print one line per field with the values of all records at the end, instead of one line per record")
+	flag.StringVar(&opt.checksum, "crc", "",
+		"append a checksum of the bytes of each record as a last field printed with %08x, crc32, sum8 or xor")
+	flag.StringVar(&opt.recordHash, "record-hash", "",
+		"print a fnv or crc32 hash of the bytes of each record before the fields, to spot duplicates")
+	flag.StringVar(&opt.until, "until", "",
+		"stop before the first record which is all-zero or for which the expression is true, like \"f0 == 0\"")
+	flag.IntVar(&opt.linePad, "line-pad", 0,
+		"pad each output line with spaces to this many characters, for fixed width text consumers")
+	flag.StringVar(&opt.lineLong, "line-long", "truncate",
+		"what to do with lines longer than -line-pad: truncate or error")
+	flag.IntVar(&opt.ascending, "ascending", -1,
+		"warn on stderr about the first record where the integer field with this index decreases")
+	flag.BoolVar(&opt.strictAsc, "ascending-strict", false,
+		"with -ascending, equal values in consecutive records are also a violation")
+	flag.StringVar(&opt.asString, "as-string", "",
+		"print a run of byte fields as one string, like 0:32 for the 32 fields of C32 from field 0")
+	flag.StringVar(&opt.stringTrim, "string-trim", "nul",
+		"padding trimmed from the end of -as-string strings: nul, space for NUL and spaces, or none")
+	flag.StringVar(&opt.encoding, "enc", "",
+		"decode the bytes of 'a' string fields as ebcdic (code page 037), ascii or latin1, bytes not printable are shown as '.'")
+	flag.BoolVar(&opt.tsv, "tsv", false,
+		"print records as tab separated values, tabs and newlines in strings are escaped")
+	flag.BoolVar(&opt.header, "header", false,
+		"with -tsv or -csv, print a header line of field names first")
+	flag.BoolVar(&opt.csv, "csv", false,
+		"print records as comma separated values quoted as needed, values in decimal whatever the print format")
+	flag.StringVar(&opt.mask, "mask", "",
+		"AND integer fields with a mask and optionally shift right, like 0:0x0f,1:0xff00>>8")
+	flag.StringVar(&opt.fixedPoint, "q", "",
+		"read integer fields as fixed-point numbers with the given fraction bits, like 0:15 for Q15 in field 0, printed with %g by default")
+	flag.StringVar(&opt.scale, "m", "",
+		"scale number fields to float values * scale + bias, like 0:0.01:-40 for field 0, printed with %g by default")
+	flag.StringVar(&opt.bitWidth, "bitwidth", "",
+		"sign extend integer fields holding signed values of fewer bits, like 0:12 for a 12-bit value in field 0")
+	flag.StringVar(&opt.inputSep, "input-sep", "",
+		"split input on a separator with Go escapes like \\n---\\n, each chunk is decoded as one record")
+	flag.IntVar(&opt.minBytes, "min-bytes", 0,
+		"drop records of fewer bytes with a note on stderr, for runt frames of variable size records")
+	flag.StringVar(&opt.tmpl, "tmpl", "",
+		"print each record with a Go text/template like \"{{.f0}} {{hex .f1}}\", followed by a newline")
+	flag.StringVar(&opt.tmplFile, "tmpl-file", "",
+		"print each record with the Go text/template in the file")
+	flag.IntVar(&opt.jobs, "jobs", 1,
+		"with several input files, read up to this many files concurrently, output is still in file order")
+	flag.IntVar(&opt.onChange, "on-change", -1,
+		"only print records where the field with this index differs from the previous record")
+	flag.StringVar(&opt.prefix, "prefix", "",
+		"print this before each record line, before the offset and record count")
+	flag.StringVar(&opt.suffix, "suffix", "",
+		"print this at the end of each record line, before the newline")
+	flag.BoolVar(&opt.emptyFmt, "empty-fmt", false,
+		"allow an empty -p, printing only the offset and record count of each record")
+	flag.BoolVar(&opt.ascii, "a", false,
+		"append the record bytes as ASCII like hexdump -C, non printable bytes are shown as '.'")
+	flag.BoolVar(&opt.specTree, "spec-tree", false,
+		"print the fields and groups of the binary format with their offset and size, then exit without reading data")
+	flag.StringVar(&opt.fieldSelect, "fields", "",
+		"print only the fields with 0-based index in a list like 3,7,12 or 3-5, all fields are still decoded")
+	flag.BoolVar(&opt.countOnly, "count-only", false,
+		"instead of printing records, print the number of records and the bytes they take at the end")
+	flag.IntVar(&opt.limit, "n", 0,
+		"stop after printing this many records, 0 for no limit")
+	flag.IntVar(&opt.every, "every", 0,
+		"only print every Nth record, like 1000 for records 1000, 2000..., all records are still decoded")
+	flag.IntVar(&opt.expectRecords, "r", -1,
+		"expect exactly this many complete records in the input, more or fewer is an error")
+	flag.StringVar(&opt.unit, "unit", "",
+		"print units after field values, like \"1:°C,2:kPa\" for field 1 and 2")
+	flag.StringVar(&opt.skip, "s", "",
+		"skip this many bytes, like 512 or 0x200, before decoding, offsets start after them")
+	flag.BoolVar(&opt.nameHeader, "H", false,
+		"print a header line with the field names before the records")
+	flag.BoolVar(&opt.perFile, "per-file", false,
+		"with several input files and -banner=false, start offsets and the record count from 0 for each file")
+	flag.BoolVar(&opt.banner, "banner", true,
+		"with several input files, print a ==> name <== line before each file and start offsets and the record count from 0 for it")
+	flag.BoolVar(&opt.hexInput, "x", false,
+		"read the input as hex text like deadbeef0102, whitespace is ignored")
+	flag.BoolVar(&opt.decodeDump, "decode-dump", false,
+		"read the input as the lines of bprint -o output like 0000010 de ad be ef, to decode an edited dump")
+	flag.StringVar(&opt.byteLimit, "L", "",
+		"read at most this many bytes, like 4096 or 0x1000, after the bytes skipped by -s")
+	flag.BoolVar(&opt.gunzip, "z", false,
+		"decompress the input if it's gzip, detected by its 1f 8b magic, other input is read as is")
+	flag.StringVar(&opt.offsetFmt, "O", "",
+		"print -o offsets in hex, dec or oct, wide enough for the input size or with a width like hex:10")
+	flag.BoolVar(&opt.littleEndian, "le", false,
+		"read fields as little-endian (default)")
+	flag.BoolVar(&opt.bigEndian, "be", false,
+		"read fields as big-endian")
+	flag.BoolVar(&opt.bigEndian, "B", false,
+		"same as -be")
+	flag.BoolVar(&opt.nativeEndian, "N", false,
+		"read fields in the native byte order of the host")
+}
+
+func main() {
+	defer func() {
+		if err := recover(); err != nil {
+			if _, ok := err.(reportedError); !ok {
+				fmt.Fprintln(diagOutput, err)
+			}
+			if _, ok := err.(specError); ok {
+				os.Exit(exitSpecError)
+			}
+			os.Exit(exitError)
+		}
+	}()
+
+	flag.CommandLine.Parse(joinColorArg(os.Args[1:]))
+	if opt.printVersion {
+		printVersion()
+	}
+	if opt.schemaFile != "" {
+		readSchema(opt.schemaFile)
+	}
+	selectByteOrder()
+	emptyPrintFmt = opt.emptyFmt && flagSet("p") && opt.printFmt == ""
+	if opt.formatFile != "" {
+		readOptionFromFile()
+	}
+	if emptyPrintFmt {
+		// The empty -p overrides the print format in the file
+		opt.printFmt = ""
+	}
+	envFormats()
+	if opt.follow {
+		if flag.NArg() > 1 {
+			panic(specError{errors.New("Option -F needs one input file")})
+		}
+		followStop = make(chan os.Signal, 1)
+		signal.Notify(followStop, os.Interrupt)
+	}
+	// Input opened to read its first line as the binary format with -self
+	var selfReader io.Reader
+	var selfFile io.ReadCloser
+	if opt.selfSpec {
+		if flagSet("e") || opt.pack || flag.NArg() > 1 {
+			panic(specError{errors.New("Option -self needs one input file, and can't be used with -e or -P")})
+		}
+		selfReader, selfFile = openFile(flag.Arg(0))
+		defer selfFile.Close()
+		opt.binaryFmt, specLineSize = readSpecLine(selfReader.(*bufio.Reader))
+	}
+	if flagSet("d") {
+		fieldSep = parseFieldSep(opt.fieldSep)
+	}
+	if opt.flush && !flagSet("flush-every") {
+		opt.flushEvery = 1
+	}
+	if opt.every < 0 {
+		panic(specError{fmt.Errorf("Invalid sampling interval '%d', should be positive", opt.every)})
+	}
+	if opt.countWidth < 0 {
+		panic(specError{fmt.Errorf("Invalid record count width '%d', should be positive", opt.countWidth)})
+	} else if opt.countWidth > 0 {
+		countFmt = fmt.Sprintf("%%0%dd: ", opt.countWidth)
+	}
+	// The default record fills a line of the terminal
+	if opt.binaryFmt == "" && opt.printFmt == "" && opt.outFile == "" && isTerminal(os.Stdout) {
+		opt.binaryFmt = terminalBinaryFmt(terminalWidth(os.Stdout))
+	} else if opt.binaryFmt == "" {
+		opt.binaryFmt = defautlBinaryFmt
+	} else if isSpecFile(opt.binaryFmt) && !opt.selfSpec {
+		// Only -e names a file, a line of the input read as a file would
+		// let the input read any file
+		opt.binaryFmt = readSpecFile(opt.binaryFmt[1:])
+	}
+	if term := parseHexBytes(opt.strTerm); len(term) == 1 {
+		strTerm = term[0]
+	} else {
+		panic(specError{fmt.Errorf("String terminator '%s' should be one byte", opt.strTerm)})
+	}
+	formatField, names, recordSize, err := bprint.ParseNamedSpec(opt.binaryFmt)
+	if err != nil && opt.selfSpec {
+		panic(specError{fmt.Errorf("First input line is not a binary format: %v", err)})
+	} else if err != nil {
+		panic(specError{err})
+	}
+	if opt.forceUnsigned && opt.forceSigned {
+		panic(specError{errors.New("Options -u and -i conflict, integers are read either unsigned or signed")})
+	}
+	var skip int64
+	if opt.skip != "" {
+		skip = parseByteCount("skip", opt.skip)
+	}
+	// Bytes read after the skipped bytes, no limit if negative
+	var limit int64 = -1
+	if opt.byteLimit != "" {
+		limit = parseByteCount("byte limit", opt.byteLimit)
+	}
+	// With -r, a format like "S C*" has the count which splits the file
+	// into records of the same size
+	if tree, _ := bprint.ParseSpecTree(opt.binaryFmt); opt.expectRecords > 0 && flag.NArg() <= 1 &&
+		len(tree) > 0 && tree[len(tree)-1].Repeat == bprint.RepeatToEnd &&
+		(opt.expectRecords > 1 || regularFile(flag.Arg(0))) {
+		formatField, names, recordSize = inferRepeat(tree, opt.expectRecords, flag.Arg(0), int64(specLineSize)+skip, limit)
+	}
+	forceSign(formatField)
+	if opt.specTree {
+		tree, _ := bprint.ParseSpecTree(opt.binaryFmt)
+		printSpecTree(output, tree)
+		return
+	}
+	for _, v := range names {
+		if v != "" {
+			// Named fields, or fields in groups
+			fieldNames = names
+			break
+		}
+	}
+	if opt.autoCount {
+		formatField, recordSize = autoCountFields(formatField, recordSize, flag.Arg(0))
+	}
+	// Types of the decoded fields
+	fields := bprint.DataFields(formatField)
+	if len(fields) > 0 && fields[len(fields)-1].IsArray() && opt.array != "" {
+		panic(specError{errors.New("Option -array can't be used with a field repeated to the end by '*'")})
+	}
+	if opt.swapFields != "" {
+		swapFields = parseFieldList(opt.swapFields, len(fields))
+		for _, i := range swapFields {
+			if !fields[i].IsInt() {
+				panic(specError{fmt.Errorf("Field %d to swap is not an integer", i)})
+			}
+			if fields[i].Size() == 3 {
+				panic(specError{fmt.Errorf("Field %d to swap is a 24-bit integer, use -B or -le for its byte order", i)})
+			}
+		}
+	}
+	if opt.bitWidth != "" {
+		signWidths = parseSignWidths(opt.bitWidth, fields)
+	}
+	for i, v := range fields {
+		if v == bprint.STR {
+			fixedStrings = append(fixedStrings, i)
+		}
+	}
+	if opt.printSize {
+		printRecordSize(diagOutput, recordSize, len(fields))
+	}
+	// Types of the fields printed, after splitting bitfields, grouping
+	// strings and masking
+	printField := fields
+	tree, _ := bprint.ParseSpecTree(opt.binaryFmt)
+	if bits := bprint.SpecBits(tree); len(bits) > 0 {
+		specBits = bits
+		fieldNames = splitBitNames(fieldNames, len(fields))
+		printField = splitBitTypes(fields)
+	}
+	if opt.cBitfields != "" {
+		bitfields = parseCBitfields(opt.cBitfields, printField)
+		fieldNames = bitfields.expandNames(fieldNames, len(printField))
+		printField = bitfields.expandTypes(printField)
+	}
+
+	if opt.asString != "" {
+		charString = parseCharArray(opt.asString, printField)
+		if fieldNames != nil {
+			fieldNames = charString.groupNames(fieldNames)
+		}
+		printField = charString.groupTypes(printField)
+	}
+	if opt.mask != "" {
+		fieldMasks = parseFieldMasks(opt.mask, printField)
+		printField = maskTypes(fieldMasks, printField)
+	}
+	if opt.fixedPoint != "" {
+		fieldScales = parseFixedPoint(opt.fixedPoint, printField)
+		printField = scaleTypes(fieldScales, printField)
+	}
+	if opt.scale != "" {
+		scales := parseFieldScales(opt.scale, printField)
+		fieldScales = append(fieldScales, scales...)
+		printField = scaleTypes(scales, printField)
+	}
+	switch opt.stringTrim {
+	case "nul", "space", "none":
+	default:
+		panic(specError{fmt.Errorf("Unknown -string-trim '%s', should be nul, space or none", opt.stringTrim)})
+	}
+	if opt.encoding != "" {
+		if stringEncoding = stringEncodings[opt.encoding]; stringEncoding == nil {
+			panic(specError{fmt.Errorf("Unknown -enc '%s', should be ebcdic, ascii or latin1", opt.encoding)})
+		}
+	}
+	if opt.array != "" {
+		array = parseVarArray(opt.array, fields)
+		printField = append(printField, array.listType)
+	}
+	// Types of all the fields, the checks of records index them
+	recordField := printField
+	if opt.fieldSelect != "" {
+		selectedFields = parseFieldList(opt.fieldSelect, len(printField))
+		printField = selectTypes(printField)
+		fieldNames = selectNames(fieldNames)
+	}
+	if opt.checksum != "" {
+		if recordChecksum = recordChecksums[opt.checksum]; recordChecksum == nil {
+			panic(specError{fmt.Errorf("Unknown checksum '%s', should be crc32, sum8 or xor", opt.checksum)})
+		}
+		for len(fieldNames) < len(printField) {
+			fieldNames = append(fieldNames, "")
+		}
+		checksumField = len(printField)
+		printField = append(printField, bprint.U32)
+		fieldNames = append(fieldNames, "crc")
+	}
+	checkFieldNames(len(printField))
+	if opt.types {
+		printFieldTypes(diagOutput, printField)
+	}
+	formatFieldCnt := len(printField)
+	origPrintFmt := opt.printFmt
+	if opt.hexBytes {
+		hexDigits = hexFieldDigits(fields, printField)
+	}
+	if emptyPrintFmt {
+		// Only the prefixes are printed, fields are still decoded
+	} else if opt.printFmt == "" && recordChecksum != nil {
+		opt.printFmt = generatePrintFmt(printField[:len(printField)-1], fieldSep) + fieldSep + "%08x"
+	} else if opt.printFmt == "" {
+		opt.printFmt = generatePrintFmt(printField, fieldSep)
+	} else {
+		if opt.printFmt, err = processPrintFmt(opt.printFmt); err != nil {
+			panic(specError{err})
+		}
+		if cnt, err := countPrintFmtField(opt.printFmt); err != nil {
+			panic(specError{err})
+		} else if cnt == 1 && formatFieldCnt > 1 {
+			// A single print field is for all the fields, like -e c100 -p %d.
+			// The field count of -auto-count isn't even known in advance
+			opt.printFmt = repeatWithSep(opt.printFmt, fieldSep, formatFieldCnt)
+		}
+	}
+	if opt.timeField != "" {
+		timeFields = parseTimeFields(opt.timeField, printField)
+	}
+	checkPrintFmtVerbs(printField, opt.printFmt)
+	// Check if binary and print format has the same field count
+	printFieldCnt, err := countPrintFmtField(opt.printFmt)
+	if err != nil {
+		panic(specError{err})
+	}
+	if printFieldCnt != formatFieldCnt && !emptyPrintFmt {
+		panic(specError{fmt.Errorf("Binary format has %d fields, print fmt has %d fields. Not match.",
+			formatFieldCnt, printFieldCnt)})
+	}
+	if opt.check {
+		printCheck(output, recordSize, formatFieldCnt, opt.printFmt)
+		return
+	}
+	if opt.emitSchema != "" {
+		opt.printFmt = origPrintFmt
+		writeSchema(opt.emitSchema, formatField)
+		return
+	}
+
+	for _, v := range opt.enumFiles {
+		loadEnumFile(v, formatFieldCnt)
+	}
+	for _, v := range opt.enums {
+		parseEnum(v, formatFieldCnt)
+	}
+	if opt.unit != "" {
+		fieldUnits = parseFieldUnits(opt.unit, formatFieldCnt)
+	}
+	if opt.nulEnd {
+		recordEnd = "\x00"
+	}
+	opt.printFmt = convertPrintFields(opt.printFmt)
+	if useColor(opt.color) {
+		opt.printFmt = colorPrintFmt(opt.printFmt, printField)
+	}
+	if opt.raw {
+		if specBits != nil || charString != nil || array != nil {
+			panic(specError{errors.New("Option -raw can't be used with bit ranges, -as-string or -array, their values aren't one field each")})
+		}
+		rawFields = formatField
+		cnt := formatFieldCnt
+		if recordChecksum != nil {
+			cnt--
+		}
+		opt.printFmt = rawPrintFmt(opt.printFmt, cnt)
+	}
+	if opt.fieldOffsets {
+		if specBits != nil || charString != nil || array != nil {
+			panic(specError{errors.New("Option -field-offsets can't be used with bit ranges, -as-string or -array, their values aren't one field each")})
+		} else if opt.raw {
+			panic(specError{errors.New("Options -raw and -field-offsets conflict, only one can annotate the fields")})
+		}
+		offsetFields = formatField
+		cnt := formatFieldCnt
+		if recordChecksum != nil {
+			cnt--
+		}
+		opt.printFmt = offsetPrintFmt(opt.printFmt, cnt)
+	}
+	opt.printFmt += recordEnd
+	if opt.filter != "" {
+		recordFilter = parseExpr(opt.filter, recordField)
+	}
+	if opt.lineLong != "truncate" && opt.lineLong != "error" {
+		panic(specError{fmt.Errorf("Unknown -line-long '%s', should be truncate or error", opt.lineLong)})
+	}
+	if opt.goLiteral && opt.cLiteral {
+		panic(specError{errors.New("Options -go and -c-struct conflict, records are printed in one language")})
+	}
+	if opt.tmpl != "" && opt.tmplFile != "" {
+		panic(specError{errors.New("Options -tmpl and -tmpl-file conflict, only one template can be used")})
+	}
+	if opt.tmpl != "" {
+		recordTmpl = parseTemplate(opt.tmpl)
+	} else if opt.tmplFile != "" {
+		recordTmpl = readTemplate(opt.tmplFile)
+	}
+	if opt.until != "" {
+		sentinel = parseSentinel(opt.until, recordField)
+	}
+	if opt.ascending >= 0 {
+		if opt.ascending >= len(recordField) || !recordField[opt.ascending].IsInt() {
+			panic(specError{fmt.Errorf("Field %d to check for -ascending is not an integer field", opt.ascending)})
+		}
+		ascending = &ascendingCheck{field: opt.ascending, strict: opt.strictAsc}
+	}
+	if opt.onChange >= 0 {
+		if opt.onChange >= len(recordField) {
+			panic(specError{fmt.Errorf("Field %d for -on-change out of range, record has %d fields", opt.onChange, len(recordField))})
+		}
+		onChange = &changeCheck{field: opt.onChange}
+	}
+	if opt.histBuckets != "" {
+		histogram = parseBucketHist(opt.histBuckets, formatFieldCnt)
+	}
+	if emptyPrintFmt && !printFmtOutput() {
+		panic(specError{errors.New("Option -empty-fmt only works with the -p output")})
+	}
+	if opt.rangeCheck != "" {
+		rangeChecks = parseRangeChecks(opt.rangeCheck, recordField)
+	}
+	if opt.resync != "" {
+		syncWord = parseHexBytes(opt.resync)
+	}
+	if opt.inputSep != "" {
+		if syncWord != nil {
+			panic(specError{errors.New("Options -input-sep and -resync conflict")})
+		}
+		inputSep = parseInputSep(opt.inputSep)
+	}
+	if opt.records != "" {
+		recordSelect = parseRecordRanges(opt.records)
+	}
+	if epochTime = epochs[opt.epoch]; epochTime == nil {
+		panic(specError{fmt.Errorf("Unknown epoch '%s', should be unix, mac, filetime or dos", opt.epoch)})
+	}
+	if opt.recordHash != "" {
+		if recordHasher = recordHashers[opt.recordHash]; recordHasher == nil {
+			panic(specError{fmt.Errorf("Unknown record hash '%s', should be fnv or crc32", opt.recordHash)})
+		}
+	}
+	if opt.maxMem != "" {
+		maxMem = parseByteSize(opt.maxMem)
+	}
+	if opt.keepGoing && (array != nil || inputSep != nil || !fixedRecord(formatField)) {
+		panic(specError{errors.New("Option -k needs fixed size records, to know where the next record starts")})
+	}
+	if opt.recSize != 0 {
+		if opt.recSize < recordSize {
+			panic(specError{fmt.Errorf("Record size %d is smaller than the %d bytes of the binary format", opt.recSize, recordSize)})
+		}
+		paddedSize = opt.recSize
+	}
+
+	if opt.appendOut && opt.outFile == "" {
+		panic(specError{errors.New("-append needs an output file given with -out")})
+	}
+	if opt.outFile != "" {
+		f := openOutput(opt.outFile, opt.appendOut)
+		defer f.Close()
+		output = f
+	}
+	w := bufio.NewWriter(output)
+	defer w.Flush()
+	output = w
+	if opt.align && printFmtOutput() {
+		if opt.nulEnd {
+			panic(specError{errors.New("Option -align can't be used with -0, lines are aligned")})
+		}
+		if opt.linePad > 0 {
+			panic(specError{errors.New("Option -align can't be used with -line-pad, lines are padded before the columns are aligned")})
+		}
+		startAlign(output)
+	}
+
+	if opt.pack {
+		// The lines of all the inputs are packed in turn, like cat
+		paths := flag.Args()
+		if len(paths) == 0 {
+			paths = []string{""}
+		}
+		for _, path := range paths {
+			name := ""
+			if len(paths) > 1 {
+				name = path
+			}
+			textReader, f := openFile(path)
+			packRecords(textReader, name, formatField)
+			f.Close()
+		}
+		return
+	}
+
+	if opt.offsetFmt != "" {
+		offsetFmt = parseOffsetFmt(opt.offsetFmt, inputSize(flag.Args()))
+	}
+
+	if opt.explain {
+		printExplain(diagOutput, formatField, names)
+	}
+	if opt.hexInput && opt.decodeDump {
+		panic(specError{errors.New("Options -x and -decode-dump conflict, the input is read in one way")})
+	}
+	if opt.group < 1 {
+		panic(specError{fmt.Errorf("Invalid record group '%d', should be at least 1", opt.group)})
+	}
+	if opt.progress {
+		var total int64
+		if !opt.hexInput && !opt.decodeDump && !opt.gunzip {
+			// The percentage is of the input bytes
+			total = inputSize(flag.Args())
+		}
+		progress = startProgress(diagOutput, total, time.Second)
+		defer progress.done()
+	}
+	if flag.NArg() > 1 {
+		if opt.viz || opt.inferRecsize || opt.autoCount {
+			panic(specError{errors.New("Options -viz, -infer-recsize and -auto-count need one input file")})
+		}
+		if failed := decodeFiles(flag.Args(), opt.jobs, formatField, recordSize, skip, limit); failed > 0 {
+			panic(fmt.Sprintf("%d of %d input files could not be read", failed, flag.NArg()))
+		}
+		checkReadErrors()
+		checkTruncated()
+		checkSkipped()
+		return
+	}
+	binReader, f := selfReader, selfFile
+	if binReader == nil {
+		binReader, f = openFile(flag.Arg(0))
+		defer f.Close()
+	} else {
+		// The spec line is buffered, the file can't be seeked
+		f = nil
+	}
+	if skip > 0 {
+		skipHeader(binReader, f, skip)
+	}
+	offSet += specLineSize
+	if limit >= 0 {
+		binReader = io.LimitReader(binReader, limit)
+	}
+	if opt.viz {
+		vizDump(binReader, output)
+		return
+	}
+	if opt.inferRecsize {
+		printRecsizeCandidates(binReader, diagOutput)
+		return
+	}
+	if opt.guessEndian {
+		binReader = guessByteOrder(binReader, formatField, recordSize)
+	}
+
+	dumpRecords(binReader, formatField, recordSize)
+	checkReadErrors()
+	if !opt.follow {
+		// With -F, a record is only cut short by Ctrl-C
+		checkTruncated()
+	}
+	checkSkipped()
+}
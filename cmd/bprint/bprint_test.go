@@ -0,0 +1,2091 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gastaoss/bprint"
+)
+
+type binFmtData struct {
+	binFmt  string
+	fmtDesc []bprint.FieldType
+	size    int
+}
+
+func TestParseBinaryFmt(t *testing.T) {
+	testData := []binFmtData{
+		{"cslqCSLQ", []bprint.FieldType{bprint.I8, bprint.I16, bprint.I32, bprint.I64, bprint.U8, bprint.U16, bprint.U32, bprint.U64}, 30},
+		{"c2", []bprint.FieldType{bprint.I8, bprint.I8}, 2},
+		{"s1q", []bprint.FieldType{bprint.I16, bprint.I64}, 10},
+		{"c11q2", []bprint.FieldType{bprint.I8, bprint.I8, bprint.I8, bprint.I8, bprint.I8, bprint.I8, bprint.I8, bprint.I8, bprint.I8, bprint.I8, bprint.I8, bprint.I64, bprint.I64}, 27},
+	}
+
+	for _, td := range testData {
+		res, size, _ := parseBinaryFmt(td.binFmt)
+		for i, v := range res {
+			if td.fmtDesc[i] != v {
+				t.Error("binary fmt:", td.binFmt, "not parsed correctly, got", res)
+			}
+		}
+		if size != td.size {
+			t.Error("binary fmt:", td.binFmt, "size should be", td.size,
+				", got", size)
+		}
+	}
+}
+
+func Test24BitFmt(t *testing.T) {
+	if res, size, _ := parseBinaryFmt("cm2M"); size != 10 || len(res) != 4 || res[1] != bprint.I24 || res[3] != bprint.U24 {
+		t.Error("24-bit format not parsed correctly, got", res, size)
+	}
+	res := dumpString("mM", "%d %d", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	if res != "-1 16777215\n" {
+		t.Error("24-bit fields printed wrong, got", res)
+	}
+}
+
+func TestFixedString(t *testing.T) {
+	defer func() { fixedStrings, opt.stringTrim = nil, "nul" }()
+	res, size, _ := parseBinaryFmt("a16L")
+	if size != 20 || len(res) != 17 || !reflect.DeepEqual(bprint.DataFields(res), []bprint.FieldType{bprint.STR, bprint.U32}) {
+		t.Error("fixed length string format not parsed correctly, got", res, size)
+	}
+	if s := generatePrintFmt(bprint.DataFields(res), " "); s != "%s %02x" {
+		t.Error("fixed length string should default to a string verb, got", s)
+	}
+
+	fixedStrings = []int{0}
+	in := []byte{'a', 'b', 0, 0, 1, 0, 0, 0, 'c', 'd', ' ', 0, 2, 0, 0, 0}
+	if res := dumpString("a4L", "[%s] %d", in); res != "[ab] 1\n[cd ] 2\n" {
+		t.Error("fixed length string printed wrong, got", res)
+	}
+	opt.stringTrim = "space"
+	if res := dumpString("a4L", "[%s] %d", in); res != "[ab] 1\n[cd] 2\n" {
+		t.Error("fixed length string should be trimmed of spaces, got", res)
+	}
+	opt.stringTrim = "none"
+	if res := dumpString("a4L", "%q %d", in[:8]); res != "\"ab\\x00\\x00\" 1\n" {
+		t.Error("fixed length string should keep its padding, got", res)
+	}
+}
+
+func TestByteOrderMarkers(t *testing.T) {
+	defer func() { byteOrder = binary.LittleEndian }()
+	byteOrder = binary.BigEndian
+
+	in := []byte{0, 1, 0, 0, 0, 2, 1, 0}
+	if res := dumpString("S<L>S", "%d %d %d", in); res != "1 33554432 256\n" {
+		t.Error("byte order markers not applied, got", res)
+	}
+}
+
+func TestParseGroupFmt(t *testing.T) {
+	res, size, err := parseBinaryFmt("(ssC)100")
+	if err != nil || len(res) != 300 || size != 500 || res[2] != bprint.U8 || res[299] != bprint.U8 {
+		t.Error("repeated group not parsed correctly, got", len(res), size, err)
+	}
+	res, size, _ = parseBinaryFmt("(c(ss)2)3")
+	elem := []bprint.FieldType{bprint.I8, bprint.I16, bprint.I16, bprint.I16, bprint.I16}
+	if size != 27 || len(res) != 15 || !reflect.DeepEqual(res[10:], elem) {
+		t.Error("nested group not parsed correctly, got", res, size)
+	}
+	if res, _, _ := parseBinaryFmt("c(S)2"); !reflect.DeepEqual(res, []bprint.FieldType{bprint.I8, bprint.U16, bprint.U16}) {
+		t.Error("repeat number after ) should repeat the group, got", res)
+	}
+
+	for _, s := range []string{"(ss", "ss)2", "((c)2", "()3)"} {
+		if _, _, err := parseBinaryFmt(s); err == nil {
+			t.Error("unmatched parenthesis in", s, "should be rejected")
+		}
+	}
+}
+
+func TestParseVerboseFmt(t *testing.T) {
+	verbose, verboseSize, _ := parseBinaryFmt("i8,u32,f64")
+	terse, terseSize, _ := parseBinaryFmt("cLd")
+	if !reflect.DeepEqual(verbose, terse) || verboseSize != terseSize {
+		t.Error("verbose format decoded differently from cLd, got", verbose, verboseSize)
+	}
+
+	res, size, _ := parseBinaryFmt(" u16*3, strz ,")
+	if !reflect.DeepEqual(res, []bprint.FieldType{bprint.U16, bprint.U16, bprint.U16, bprint.STRZ}) || size != 6 {
+		t.Error("verbose format with repeat not parsed correctly, got", res, size)
+	}
+	if res, _, _ := parseBinaryFmt("f64,"); !reflect.DeepEqual(res, []bprint.FieldType{bprint.F64}) {
+		t.Error("single verbose type with trailing comma not parsed correctly, got", res)
+	}
+
+	if res, size, _ := parseBinaryFmt("str:16,u32"); size != 20 || len(bprint.DataFields(res)) != 2 {
+		t.Error("verbose fixed length string not parsed correctly, got", res, size)
+	}
+
+	for _, s := range []string{"i8,x16", "u8*0,", "str:0,", "str:x,"} {
+		if _, _, err := parseBinaryFmt(s); err == nil {
+			t.Error("verbose format", s, "should be rejected")
+		}
+	}
+}
+
+func TestSkip(t *testing.T) {
+	formatField, recordSize, _ := parseBinaryFmt("cx3l")
+	if recordSize != 8 || len(bprint.DataFields(formatField)) != 2 {
+		t.Error("skip format not parsed correctly, got", formatField, recordSize)
+	}
+	printFmt := generatePrintFmt(bprint.DataFields(formatField), " ")
+	if cnt, _ := countPrintFmtField(printFmt); cnt != 2 {
+		t.Error("skipped bytes should have no print field, got", printFmt)
+	}
+
+	defer func() { opt.printOffset = false }()
+	opt.printOffset = true
+	in := []byte{1, 0xaa, 0xbb, 0xcc, 2, 0, 0, 0, 0xff, 0xdd, 0xee, 0xff, 3, 0, 0, 0}
+	res := dumpString("cx3l", "%d %d", in)
+	if res != "0000000 1 2\n0000008 -1 3\n0000010 \n" {
+		t.Error("skipped bytes not dropped, got", res)
+	}
+}
+
+func TestParseUnsupportedBinaryFmt(t *testing.T) {
+	if _, _, err := parseBinaryFmt("ccid"); err == nil {
+		t.Error("Should return error for unsuppored field")
+	}
+}
+
+func TestParseNofieldNumberBinaryFmt(t *testing.T) {
+	if _, _, err := parseBinaryFmt("11clsq"); err == nil {
+		t.Error("Should return error for repeat number without field")
+	}
+}
+
+func TestGenerateOutputFmt(t *testing.T) {
+	var s string
+	s = generatePrintFmt([]bprint.FieldType{bprint.U8, bprint.I16}, " ")
+
+	if s != "%02x %02x" {
+		t.Error("length 2 space sep error")
+	}
+	s = generatePrintFmt([]bprint.FieldType{bprint.RGB, bprint.U8}, ",")
+	if s != "%s,%02x" {
+		t.Error("color field should default to string, got", s)
+	}
+}
+
+func TestProcessPrintFmt(t *testing.T) {
+	testData := []struct {
+		spec string
+		res  string
+	}{
+		{"hello %02d2# %#07x nihao %09o, 2#", "hello %02d %02d %#07x nihao %09o, %09o"},
+		{"%#08c %d %x hello", "%#08c %d %x hello"},
+		{"%#01x1# this %2d,2# world", "%#01x this %2d,%2d world"},
+		{"head %%02d2# end", "head %%02d2# end"},
+		{"%08b 4#|%X,2#", "%08b %08b %08b %08b|%X,%X"},
+		{"%%08b2# %b", "%%08b2# %b"},
+		{`%02x""4# end`, "%02x%02x%02x%02x end"},
+		{`%d"-"2#`, `%d"-"%d`},
+		{`%d\t3#`, "%d\t%d\t%d"},
+		{`%x\n2#|%d\\2#`, "%x\n%x|%d\\%d"},
+		{`%d\\t2#`, `%d\t%d`},
+		{"%02x, 3# end", "%02x, %02x, %02x end"},
+		{"%#x #%d # %s", "%#x #%d # %s"},
+		{"%#o2# #3", "%#o %#o #3"},
+	}
+
+	for _, td := range testData {
+		res, err := processPrintFmt(td.spec)
+		if err != nil || res != td.res {
+			t.Error("Print format processing wrong", td.spec, "converted to:", res)
+		}
+	}
+	if _, err := processPrintFmt("%d 99999999999999999999#"); err == nil {
+		t.Error("Print format with overflowing repeat count should be rejected")
+	}
+}
+
+func TestFieldSep(t *testing.T) {
+	defer func() { fieldSep = " " }()
+	fieldSep = parseFieldSep(`\t`)
+	if res, _ := processPrintFmt("%d3# %x,2#"); res != "%d\t%d\t%d %x,%x" {
+		t.Error("repeat without separator should use -d, got", res)
+	}
+	if res := generatePrintFmt([]bprint.FieldType{bprint.U8, bprint.STR}, fieldSep); res != "%02x\t%s" {
+		t.Error("default print format should use -d, got", res)
+	}
+	if res := parseFieldSep("%"); res != "%%" {
+		t.Error("'%' in separator should be escaped, got", res)
+	}
+}
+
+func TestCountPrintFmtField(t *testing.T) {
+	testData := []struct {
+		spec string
+		cnt  int
+	}{
+		{"hello %02d2# %#07x nihao %09o, 2#", 3},
+		{"%#08c %d %x hello", 3},
+		{"%#01x1# this %2d,2# world", 2},
+		{"head %%02d2# end", 0},
+		{"%08b %08b %08b|%X,%X", 5},
+		{"%%08b %b", 1},
+	}
+
+	for _, td := range testData {
+		res, err := countPrintFmtField(td.spec)
+		if err != nil || td.cnt != res {
+			t.Error("Print format spec count wrong", td.spec, "counted to:", res)
+		}
+	}
+}
+
+func BenchmarkReadData(b *testing.B) {
+	// Benchmark setup:
+	//   Intel Q6600 CPU, Debian 6 with Go 1.0.1
+	//   11MB random binary data, with default binary format
+	//
+	// Execution time:
+	//   use function         ~3.6s
+	//   use switch statement ~2.7s
+	b.StopTimer()
+	formatDesc, _, _ := parseBinaryFmt(defautlBinaryFmt)
+	formatDescLen := len(formatDesc)
+	data := make([]interface{}, formatDescLen, formatDescLen)
+
+	var err error
+	for i := 0; i < b.N; i++ {
+		bufReader, readCloser := openFile("testdata/bindata")
+		b.StartTimer()
+		for err == nil {
+			_, err = readData(bufReader, formatDesc, data)
+		}
+		b.StopTimer()
+		readCloser.Close()
+	}
+}
+
+func BenchmarkOutput(b *testing.B) {
+	// Printing 64K records of the default format to a file, on a Xeon:
+	//   unbuffered  ~112ms
+	//   buffered     ~74ms, as main does
+	formatField, recordSize, _ := parseBinaryFmt(defautlBinaryFmt)
+	in := make([]byte, 1<<20)
+	f, err := os.Create(filepath.Join(b.TempDir(), "out"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() { output = os.Stdout }()
+	for _, buffered := range []bool{false, true} {
+		name := "Unbuffered"
+		if buffered {
+			name = "Buffered"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				output = f
+				if buffered {
+					output = bufio.NewWriter(f)
+				}
+				opt.printFmt = convertPrintFields(generatePrintFmt(bprint.DataFields(formatField), " ")) + "\n"
+				dumpRecords(bytes.NewReader(in), formatField, recordSize)
+			}
+		})
+	}
+	f.Close()
+}
+
+func TestCheckPrintFmtVerbs(t *testing.T) {
+	fields := []bprint.FieldType{bprint.I8, bprint.U32}
+	checkPrintFmtVerbs(fields, "%02x %%f %d")
+	checkPrintFmtVerbs(fields, "%c %#08o")
+
+	testData := []struct {
+		binFmt   string
+		printFmt string
+	}{
+		{"L", "%s"},
+		{"cs", "%d %f"},
+		{"q", "%g"},
+	}
+	for _, td := range testData {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("print fmt", td.printFmt, "should be rejected for", td.binFmt)
+				}
+			}()
+			fields, _, _ := parseBinaryFmt(td.binFmt)
+			checkPrintFmtVerbs(fields, td.printFmt)
+		}()
+	}
+}
+
+func TestSelectByteOrder(t *testing.T) {
+	defer func() {
+		opt.littleEndian, opt.bigEndian, opt.nativeEndian = false, false, false
+		byteOrder = binary.LittleEndian
+	}()
+
+	opt.bigEndian = true
+	selectByteOrder()
+	if byteOrder != binary.BigEndian {
+		t.Error("-be should select big-endian")
+	}
+
+	opt.littleEndian = true
+	func() {
+		defer func() {
+			if err := recover(); err == nil {
+				t.Error("-le and -be together should be rejected")
+			}
+		}()
+		selectByteOrder()
+	}()
+
+	opt.littleEndian, opt.bigEndian, opt.nativeEndian = false, false, true
+	selectByteOrder()
+	if b := []byte{1, 0}; byteOrder.Uint16(b) != binary.NativeEndian.Uint16(b) {
+		t.Error("-N should select the native byte order")
+	}
+	opt.bigEndian = true
+	func() {
+		defer func() {
+			if err := recover(); err == nil {
+				t.Error("-B and -N together should be rejected")
+			}
+		}()
+		selectByteOrder()
+	}()
+
+	opt.bigEndian, opt.nativeEndian, opt.guessEndian = true, false, true
+	defer func() { opt.guessEndian = false }()
+	func() {
+		defer func() {
+			if err := recover(); err == nil {
+				t.Error("-B and -guess-endian together should be rejected")
+			}
+		}()
+		selectByteOrder()
+	}()
+}
+
+// dumpString runs dumpRecords over in and returns the output.
+func dumpString(binFmt, printFmt string, in []byte) string {
+	formatField, recordSize, _ := parseBinaryFmt(binFmt)
+	return dumpFields(formatField, recordSize, printFmt, in)
+}
+
+// dumpFields is like dumpString for already parsed fields.
+func dumpFields(formatField []bprint.FieldType, recordSize int, printFmt string, in []byte) string {
+	printFmt, _ = processPrintFmt(printFmt)
+	opt.printFmt = convertPrintFields(printFmt) + recordEnd
+	recordCnt, offSet = 0, 0
+	buf := new(bytes.Buffer)
+	output = buf
+	defer func() { output = os.Stdout }()
+	dumpRecords(bytes.NewReader(in), formatField, recordSize)
+	return buf.String()
+}
+
+func TestRecordFilter(t *testing.T) {
+	defer func() { recordFilter = nil }()
+	recordFilter = parseExpr("f0 > 1 && f1 != 0xff", []bprint.FieldType{bprint.U8, bprint.U8})
+
+	in := []byte{1, 0, 2, 0xff, 3, 0, 4, 1, 5}
+	res := dumpString("CC", "%d %x", in)
+	if res != "3 0\n4 1\n" {
+		t.Error("filtered output wrong, got", res)
+	}
+
+	// Signed and unsigned 64-bit values compare by value
+	recordFilter = parseExpr("f0 < 0 && f1 > 0x7fffffffffffffff", []bprint.FieldType{bprint.I64, bprint.U64})
+	in = []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0x80,
+		1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x80}
+	if res := dumpString("<q<Q", "%d %d", in); res != "-1 9223372036854775808\n" {
+		t.Error("filtered 64-bit output wrong, got", res)
+	}
+}
+
+func TestGoBytes(t *testing.T) {
+	defer func() { opt.goBytes = false }()
+	opt.goBytes = true
+
+	in := []byte{1, 0, 0, 0, 0xff, 0xfe, 0xfd, 0x7f, 0x80, 0x10}
+	res := dumpString("L", "%d", in)
+
+	e, err := parser.ParseExpr(res)
+	if err != nil {
+		t.Fatal("go bytes literal doesn't parse:", err, res)
+	}
+	lit, ok := e.(*ast.CompositeLit)
+	if !ok || len(lit.Elts) != len(in) {
+		t.Fatal("go bytes literal has wrong elements:", res)
+	}
+	for i, elt := range lit.Elts {
+		v, err := strconv.ParseUint(elt.(*ast.BasicLit).Value, 0, 8)
+		if err != nil || byte(v) != in[i] {
+			t.Error("go bytes literal element", i, "wrong:", res)
+		}
+	}
+}
+
+func TestTimestampFormat(t *testing.T) {
+	defer func() { opt.timeFormat = time.RFC3339 }()
+
+	in := []byte{0x00, 0xe1, 0xf5, 0x05}
+	if res := dumpString("L", "%T", in); res != "1973-03-03T09:46:40Z\n" {
+		t.Error("timestamp with default format wrong, got", res)
+	}
+	opt.timeFormat = "2006-01-02 15h"
+	if res := dumpString("LL", "%d %T", append(in, in...)); res != "100000000 1973-03-03 09h\n" {
+		t.Error("timestamp with custom format wrong, got", res)
+	}
+}
+
+func TestEpoch(t *testing.T) {
+	defer func() { epochTime = epochs["unix"] }()
+
+	tests := []struct {
+		epoch string
+		in    []byte
+		want  string
+	}{
+		// 2009-02-13T23:31:30Z is Unix time 1234567890
+		{"unix", []byte{0xd2, 0x02, 0x96, 0x49, 0, 0, 0, 0}, "2009-02-13T23:31:30Z\n"},
+		{"mac", []byte{0x52, 0xb3, 0xbb, 0xc5, 0, 0, 0, 0}, "2009-02-13T23:31:30Z\n"},
+		{"filetime", []byte{0x00, 0xf5, 0x96, 0x32, 0x33, 0x8e, 0xc9, 0x01}, "2009-02-13T23:31:30Z\n"},
+		// 1999-12-31 23:59:58
+		{"dos", []byte{0x7d, 0xbf, 0x9f, 0x27, 0, 0, 0, 0}, "1999-12-31T23:59:58Z\n"},
+	}
+	for _, tt := range tests {
+		epochTime = epochs[tt.epoch]
+		if res := dumpString("Q", "%T", tt.in); res != tt.want {
+			t.Error(tt.epoch, "timestamp wrong, got", res)
+		}
+	}
+}
+
+func TestOpenURL(t *testing.T) {
+	content := []byte{0xde, 0xad, 0xbe, 0xef}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/data" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content)
+	}))
+	defer ts.Close()
+
+	reader, f := openFile(ts.URL + "/data")
+	res, err := io.ReadAll(reader)
+	f.Close()
+	if err != nil || !bytes.Equal(res, content) {
+		t.Error("data read from URL wrong, got", res, err)
+	}
+
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("Should panic for non 200 response")
+		}
+	}()
+	openFile(ts.URL + "/missing")
+}
+
+func TestOpenSocket(t *testing.T) {
+	content := []byte{0xde, 0xad, 0xbe, 0xef}
+	for _, network := range []string{"tcp", "unix"} {
+		addr := "127.0.0.1:0"
+		if network == "unix" {
+			addr = filepath.Join(t.TempDir(), "s")
+		}
+		l, err := net.Listen(network, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			if conn, err := l.Accept(); err == nil {
+				conn.Write(content)
+				conn.Close()
+			}
+		}()
+		reader, f := openFile(network + "://" + l.Addr().String())
+		res, err := io.ReadAll(reader)
+		f.Close()
+		l.Close()
+		if err != nil || !bytes.Equal(res, content) {
+			t.Error("data read from", network, "socket wrong, got", res, err)
+		}
+	}
+
+	defer func() {
+		if err := recover(); err == nil || !strings.HasPrefix(err.(string), "While connecting to unix://") {
+			t.Error("connection failure should be reported, got", err)
+		}
+	}()
+	openFile("unix://" + filepath.Join(t.TempDir(), "missing"))
+}
+
+func TestOutBOM(t *testing.T) {
+	defer func() { opt.outBOM = false }()
+	opt.outBOM = true
+
+	res := dumpString("C", "%d", []byte{1, 2})
+	if res != "\xef\xbb\xbf1\n2\n" {
+		t.Errorf("output should start with BOM once, got %q", res)
+	}
+}
+
+// flakyReader fails every read that follows a successful one.
+type flakyReader struct {
+	r      io.Reader
+	failed bool
+}
+
+func (fr *flakyReader) Read(p []byte) (int, error) {
+	if !fr.failed {
+		fr.failed = true
+		return 0, errors.New("temporary failure")
+	}
+	fr.failed = false
+	return fr.r.Read(p[:1])
+}
+
+func TestRetryReader(t *testing.T) {
+	defer func(d time.Duration) { retryBackoff = d }(retryBackoff)
+	retryBackoff = time.Microsecond
+
+	content := []byte{1, 2, 3}
+	rr := &retryReader{r: &flakyReader{r: bytes.NewReader(content)}, retries: 1}
+	res, err := io.ReadAll(rr)
+	if err != nil || !bytes.Equal(res, content) {
+		t.Error("retry read wrong, got", res, err)
+	}
+
+	rr = &retryReader{r: &flakyReader{r: bytes.NewReader(content)}, retries: 0}
+	if _, err = io.ReadAll(rr); err == nil {
+		t.Error("read without retry should fail")
+	}
+}
+
+func TestZigzag(t *testing.T) {
+	defer func() { opt.zigzag = false }()
+	opt.zigzag = true
+
+	res := dumpString("CSLQ", "%d %d %d %d", []byte{
+		1,
+		2, 0,
+		3, 0, 0, 0,
+		4, 0, 0, 0, 0, 0, 0, 0,
+	})
+	if res != "-1 1 -2 2\n" {
+		t.Error("zigzag decode wrong, got", res)
+	}
+	if v := zigzagDecode(uint64(0xffffffffffffffff)); v != int64(-0x8000000000000000) {
+		t.Error("zigzag decode of max uint64 wrong, got", v)
+	}
+	if v := zigzagDecode(int8(3)); v != int8(3) {
+		t.Error("zigzag decode should leave signed field unchanged, got", v)
+	}
+}
+
+func TestBitWidth(t *testing.T) {
+	defer func() { signWidths = nil }()
+	formatField, _, _ := parseBinaryFmt("SSc")
+	signWidths = parseSignWidths("0:12,1:12,2:4", formatField)
+
+	// 0xf800 has reserved top bits set, 0x800 is -2048 in 12 bits
+	res := dumpString("SSc", "%d %d %d", []byte{0xff, 0x07, 0x00, 0xf8, 0x0f})
+	if res != "2047 -2048 -1\n" {
+		t.Error("sign extended fields wrong, got", res)
+	}
+	if v := signExtend(uint64(0xfff), 12); v != int64(-1) {
+		t.Error("sign extend of uint64 wrong, got", v)
+	}
+
+	for _, s := range []string{"0", "0:17", "3:4", "x:4", "0:0"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("bit width", s, "should be rejected")
+				}
+			}()
+			parseSignWidths(s, formatField)
+		}()
+	}
+}
+
+func TestAutoCountFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	formatField, recordSize, _ := parseBinaryFmt("C")
+	formatField, recordSize = autoCountFields(formatField, recordSize, path)
+	if len(formatField) != 100 || recordSize != 100 {
+		t.Error("100 byte file should have 100 fields, got", len(formatField), recordSize)
+	}
+	formatField, recordSize, _ = parseBinaryFmt("s")
+	formatField, recordSize = autoCountFields(formatField, recordSize, path)
+	if len(formatField) != 50 || recordSize != 100 || formatField[49] != bprint.I16 {
+		t.Error("100 byte file should have 50 int16 fields, got", len(formatField), recordSize)
+	}
+	// The bytes of a string after its first one aren't fields
+	formatField, recordSize, _ = parseBinaryFmt("a4")
+	formatField, recordSize = autoCountFields(formatField, recordSize, path)
+	if n := len(bprint.DataFields(formatField)); n != 25 || recordSize != 100 {
+		t.Error("100 byte file should have 25 4-byte strings, got", n, recordSize)
+	}
+
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("Should panic for more than one field")
+		}
+	}()
+	formatField, recordSize, _ = parseBinaryFmt("CC")
+	autoCountFields(formatField, recordSize, path)
+}
+
+func TestInferRepeat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, make([]byte, 120), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		spec       string
+		cnt        int
+		fieldCnt   int
+		recordSize int
+	}{
+		{"C*", 10, 12, 12},
+		{"S C*", 4, 29, 30},
+		{"L S*:v", 2, 29, 60},
+		{"C (S C)*", 3, 27, 40},
+	}
+	for _, v := range tests {
+		tree, _ := bprint.ParseSpecTree(v.spec)
+		fields, names, recordSize := inferRepeat(tree, v.cnt, path, 0, -1)
+		if len(fields) != v.fieldCnt || len(names) != v.fieldCnt || recordSize != v.recordSize {
+			t.Errorf("'%s' with %d records should have %d fields of %d bytes, got %d fields %d names of %d bytes",
+				v.spec, v.cnt, v.fieldCnt, v.recordSize, len(fields), len(names), recordSize)
+		}
+	}
+
+	// The bytes skipped by -s and after -L aren't in the records
+	tree, _ := bprint.ParseSpecTree("S C*")
+	if _, _, recordSize := inferRepeat(tree, 4, path, 20, -1); recordSize != 25 {
+		t.Errorf("record size after 20 skipped bytes should be 25, got %d", recordSize)
+	}
+	if _, _, recordSize := inferRepeat(tree, 4, path, 20, 40); recordSize != 10 {
+		t.Errorf("record size of 40 bytes after 20 skipped bytes should be 10, got %d", recordSize)
+	}
+
+	for _, v := range []struct {
+		spec string
+		cnt  int
+	}{
+		{"C*", 7},
+		{"S L*", 3},
+		{"z C*", 2},
+	} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Errorf("'%s' with %d records should panic", v.spec, v.cnt)
+				}
+			}()
+			tree, _ := bprint.ParseSpecTree(v.spec)
+			inferRepeat(tree, v.cnt, path, 0, -1)
+		}()
+	}
+}
+
+func TestPretty(t *testing.T) {
+	defer func() { opt.pretty, opt.printRecordCnt = false, false }()
+	opt.pretty, opt.printRecordCnt = true, true
+
+	res := dumpString("CsL", "%d %d %#x", []byte{1, 0xfe, 0xff, 0xef, 0xbe, 0xad, 0xde})
+	want := "record : 1\n" +
+		"f0     : 1\n" +
+		"f1     : -2\n" +
+		"f2     : 0xdeadbeef\n" +
+		"\n"
+	if res != want {
+		t.Errorf("pretty output wrong, got\n%s", res)
+	}
+}
+
+func TestZeroProgress(t *testing.T) {
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("Should panic for record consuming no data")
+		}
+	}()
+	output = new(bytes.Buffer)
+	defer func() { output = os.Stdout }()
+	dumpRecords(bytes.NewReader([]byte{1, 2}), []bprint.FieldType{}, 0)
+}
+
+func TestFloat(t *testing.T) {
+	formatField, recordSize, _ := parseBinaryFmt("cf2d")
+	if len(formatField) != 4 || recordSize != 17 {
+		t.Error("float format wrong, got", formatField, recordSize)
+	}
+	if printFmt := generatePrintFmt(formatField, " "); printFmt != "%02x %g %g %g" {
+		t.Error("default print format of floats wrong, got", printFmt)
+	}
+
+	in := []byte{
+		0x00, 0x00, 0xc0, 0x3f, // 1.5
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x04, 0xc0, // -2.5
+	}
+	if res := dumpString("fd", "%g %.2f", in); res != "1.5 -2.50\n" {
+		t.Error("float fields wrong, got", res)
+	}
+
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("integer print field for a float should be rejected")
+		}
+	}()
+	fields, _, _ := parseBinaryFmt("f")
+	checkPrintFmtVerbs(fields, "%d")
+}
+
+func TestColor(t *testing.T) {
+	res := dumpString("kCK", "%s %d %s", []byte{0xff, 0, 0, 7, 0x12, 0x34, 0x56, 0x80})
+	if res != "#ff0000 7 #12345680\n" {
+		t.Error("color output wrong, got", res)
+	}
+}
+
+func TestResync(t *testing.T) {
+	diagOutput = new(bytes.Buffer)
+	defer func() { syncWord, opt.printOffset, diagOutput = nil, false, os.Stderr }()
+	syncWord = parseHexBytes("0xAA55")
+	opt.printOffset = true
+
+	in := []byte{
+		0x01, 0x02,
+		0xaa, 0x55, 0x10, 0x00,
+		0xaa, 0x55, 0x20, 0x00,
+		0xff, 0xaa, 0x00,
+		0xaa, 0x55, 0x30, 0x00,
+	}
+	res := dumpString("SS", "%04x %d", in)
+	want := "0000002 55aa 16\n" +
+		"0000006 55aa 32\n" +
+		"000000d 55aa 48\n" +
+		"0000011 \n"
+	if res != want {
+		t.Errorf("resync output wrong, got\n%s", res)
+	}
+}
+
+func TestRangeCheck(t *testing.T) {
+	diag := new(bytes.Buffer)
+	diagOutput = diag
+	defer func() { rangeChecks, diagOutput = nil, os.Stderr }()
+	rangeChecks = parseRangeChecks("1:0..100", []bprint.FieldType{bprint.I8, bprint.U16})
+
+	dumpString("cS", "%d %d", []byte{1, 100, 0, 2, 101, 0})
+	if diag.String() != "Record 2 at offset 3: field 1 value 101 out of range 0..100\n" {
+		t.Error("range check warning wrong, got", diag.String())
+	}
+
+	// Float values and bounds, NaN is out of range
+	diag.Reset()
+	rangeChecks = parseRangeChecks("0:-0.5..1.5", []bprint.FieldType{bprint.F32})
+	dumpString("<f", "%g", []byte{0, 0, 0xc0, 0x3f, 0, 0, 0x20, 0x40, 0, 0, 0xc0, 0x7f})
+	if diag.String() != "Record 2 at offset 4: field 0 value 2.5 out of range -0.5..1.5\n"+
+		"Record 3 at offset 8: field 0 value NaN out of range -0.5..1.5\n" {
+		t.Errorf("float range check warning wrong, got %q", diag.String())
+	}
+
+	fields := []bprint.FieldType{bprint.U8, bprint.RGB}
+	for _, s := range []string{"2:0..1", "0:1", "x:0..1", "0:a..b", "1:0..1"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("range check", s, "should be rejected")
+				}
+			}()
+			parseRangeChecks(s, fields)
+		}()
+	}
+}
+
+func TestAppendOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	defer func() { output = os.Stdout }()
+	opt.printFmt = convertPrintFields("%02x") + "\n"
+
+	for _, in := range [][]byte{{1}, {2}} {
+		f := openOutput(path, true)
+		output = f
+		dumpRecords(bytes.NewReader(in), []bprint.FieldType{bprint.U8}, 1)
+		f.Close()
+	}
+	res, err := os.ReadFile(path)
+	if err != nil || string(res) != "01\n02\n" {
+		t.Errorf("appended output wrong, got %q %v", res, err)
+	}
+
+	f := openOutput(path, false)
+	f.Close()
+	if res, _ := os.ReadFile(path); len(res) != 0 {
+		t.Error("output file should be truncated without append")
+	}
+}
+
+func TestWhereAlias(t *testing.T) {
+	defer flag.Set("filter", "")
+	if err := flag.Set("where", "f2 > 1000"); err != nil || opt.filter != "f2 > 1000" {
+		t.Error("-where should set the filter, got", opt.filter, err)
+	}
+}
+
+func TestOutputFileAlias(t *testing.T) {
+	defer flag.Set("out", "")
+	if err := flag.Set("w", "out.txt"); err != nil || opt.outFile != "out.txt" {
+		t.Error("-w should set the output file, got", opt.outFile, err)
+	}
+}
+
+// chunkWriter keeps each write separately.
+type chunkWriter struct {
+	chunks []string
+}
+
+func (cw *chunkWriter) Write(p []byte) (int, error) {
+	cw.chunks = append(cw.chunks, string(p))
+	return len(p), nil
+}
+
+func TestFlushEvery(t *testing.T) {
+	defer func() { output, opt.flushEvery = os.Stdout, 0 }()
+	opt.flushEvery = 2
+	opt.printFmt = convertPrintFields("%02x") + "\n"
+	recordCnt = 0
+
+	cw := new(chunkWriter)
+	output = bufio.NewWriter(cw)
+	dumpRecords(bytes.NewReader([]byte{1, 2, 3, 4, 5}), []bprint.FieldType{bprint.U8}, 1)
+	if len(cw.chunks) != 3 || cw.chunks[0] != "01\n02\n" || cw.chunks[1] != "03\n04\n" || cw.chunks[2] != "05\n" {
+		t.Errorf("output should be flushed every 2 records, got %q", cw.chunks)
+	}
+}
+
+func TestGuessByteOrder(t *testing.T) {
+	diagOutput = new(bytes.Buffer)
+	defer func() { byteOrder, diagOutput = binary.LittleEndian, os.Stderr }()
+
+	// Small counters and lengths stored big-endian
+	in := []byte{
+		0, 0, 0, 1, 0, 0x10, 7,
+		0, 0, 0, 2, 0, 0x20, 8,
+		0, 0, 0, 3, 0, 0x30, 9,
+	}
+	formatField, recordSize, _ := parseBinaryFmt("LSC")
+	r := guessByteOrder(bytes.NewReader(in), formatField, recordSize)
+	if byteOrder != binary.BigEndian {
+		t.Error("big-endian data should be guessed as big-endian")
+	}
+	if res, _ := io.ReadAll(r); !bytes.Equal(res, in) {
+		t.Error("reader should still have the sampled data, got", res)
+	}
+
+	in = []byte{1, 0, 0, 0, 2, 0, 0, 0}
+	guessByteOrder(bytes.NewReader(in), []bprint.FieldType{bprint.I32}, 4)
+	if byteOrder != binary.LittleEndian {
+		t.Error("little-endian data should be guessed as little-endian")
+	}
+}
+
+func TestVarArray(t *testing.T) {
+	defer func() { array, opt.printOffset = nil, false }()
+	opt.printOffset = true
+	formatField, _, _ := parseBinaryFmt("cC")
+	array = parseVarArray("1:S", formatField)
+
+	in := []byte{
+		-1 & 0xff, 3, 1, 0, 2, 0, 3, 0,
+		5, 0,
+		6, 1, 0xff, 0xff,
+	}
+	res := dumpString("cC", "%d %d %v", in)
+	want := "0000000 -1 3 [1 2 3]\n" +
+		"0000008 5 0 []\n" +
+		"000000a 6 1 [65535]\n" +
+		"000000e \n"
+	if res != want {
+		t.Errorf("array output wrong, got\n%s", res)
+	}
+
+	array = parseVarArray("0:Cs", formatField)
+	res = dumpString("cC", "%d %d %v", []byte{2, 9, 1, 2, 0, 3, 4, 0})
+	if res != "0000000 2 9 [[1 2] [3 4]]\n0000008 \n" {
+		t.Errorf("array of multiple fields output wrong, got\n%s", res)
+	}
+
+	// A huge count is only truncated, the list isn't allocated first
+	countField, _, _ := parseBinaryFmt("Q")
+	a := parseVarArray("0:C", countField)
+	list, err := a.read(bytes.NewReader([]byte{1, 2}), []interface{}{uint64(1) << 60})
+	if err != io.ErrUnexpectedEOF || len(list) != 2 {
+		t.Errorf("array with a huge count should be truncated after 2 elements, got %v %v", list, err)
+	}
+}
+
+func TestPrintRecordSize(t *testing.T) {
+	formatField, recordSize, _ := parseBinaryFmt("CSLQ")
+	buf := new(bytes.Buffer)
+	printRecordSize(buf, recordSize, len(formatField))
+	if buf.String() != "Record size 15 bytes, 4 fields\n" {
+		t.Error("record size report wrong, got", buf.String())
+	}
+}
+
+func TestPrintCheck(t *testing.T) {
+	formatField, recordSize, _ := parseBinaryFmt("CS2")
+	printFmt, _ := processPrintFmt("%d-2# %x")
+	buf := new(bytes.Buffer)
+	printCheck(buf, recordSize, len(formatField), printFmt)
+	if buf.String() != "Record size 5 bytes, 3 fields\nPrint format: %d-%d %x\n" {
+		t.Error("check report wrong, got", buf.String())
+	}
+}
+
+func TestPrintFieldTypes(t *testing.T) {
+	formatField, _, _ := parseBinaryFmt("cLqkz")
+	buf := new(bytes.Buffer)
+	printFieldTypes(buf, formatField)
+	if buf.String() != "Field types: int8 uint32 int64 main.rgbColor string\n" {
+		t.Error("field types wrong, got", buf.String())
+	}
+
+	// The names must match the types readData stores
+	data := make([]interface{}, len(formatField))
+	readData(bytes.NewReader(make([]byte, 20)), formatField, data)
+	for i, v := range data {
+		if name := fmt.Sprintf("%T", v); name != goTypeNames[formatField[i]] {
+			t.Error("field", i, "is", name, "not", goTypeNames[formatField[i]])
+		}
+	}
+}
+
+func TestViz(t *testing.T) {
+	testData := []struct {
+		b  byte
+		ch rune
+	}{
+		{0, ' '}, {51, ' '}, {52, '░'}, {102, '░'}, {103, '▒'},
+		{153, '▒'}, {154, '▓'}, {204, '▓'}, {205, '█'}, {255, '█'},
+	}
+	for _, td := range testData {
+		if ch := vizChar(td.b); ch != td.ch {
+			t.Errorf("byte %d should be shown as %c, got %c", td.b, td.ch, ch)
+		}
+	}
+
+	in := make([]byte, vizLineBytes+2)
+	in[0], in[vizLineBytes+1] = 0xff, 0x80
+	buf := new(bytes.Buffer)
+	vizDump(bytes.NewReader(in), buf)
+	want := "█" + strings.Repeat(" ", vizLineBytes-1) + "\n ▒\n"
+	if buf.String() != want {
+		t.Errorf("viz output wrong, got %q", buf.String())
+	}
+}
+
+func TestRecordSelect(t *testing.T) {
+	defer func() { recordSelect, opt.printRecordCnt = nil, false }()
+	recordSelect = parseRecordRanges("3,5-6")
+	opt.printRecordCnt = true
+
+	res := dumpString("C", "%d", []byte{1, 2, 3, 4, 5, 6, 7, 8})
+	if res != "3: 3\n5: 5\n6: 6\n" {
+		t.Errorf("selected records wrong, got\n%s", res)
+	}
+
+	recordSelect = parseRecordRanges("2,4-")
+	res = dumpString("C", "%d", []byte{1, 2, 3, 4, 5})
+	if res != "2: 2\n4: 4\n5: 5\n" {
+		t.Errorf("selected records with open range wrong, got\n%s", res)
+	}
+
+	// Reading stopped after record 2, the offset there isn't the end
+	recordSelect, opt.printOffset = parseRecordRanges("1-2"), true
+	defer func() { opt.printOffset = false }()
+	res = dumpString("C", "%d", []byte{1, 2, 3, 4, 5})
+	if res != "0000000 1: 1\n0000001 2: 2\n" {
+		t.Errorf("no offset line expected after the last selected record, got\n%s", res)
+	}
+
+	for _, s := range []string{"0", "3-2", "a", "1,,2"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("record range", s, "should be rejected")
+				}
+			}()
+			parseRecordRanges(s)
+		}()
+	}
+}
+
+func TestEvery(t *testing.T) {
+	defer func() { opt.every, opt.limit, opt.printOffset = 0, 0, false }()
+	opt.every, opt.printOffset = 3, true
+
+	res := dumpString("S", "%d", []byte{1, 0, 2, 0, 3, 0, 4, 0, 5, 0, 6, 0, 7, 0})
+	if res != "0000004 3\n000000a 6\n000000e \n" {
+		t.Errorf("every 3rd record wrong, got\n%s", res)
+	}
+
+	opt.limit = 1
+	res = dumpString("S", "%d", []byte{1, 0, 2, 0, 3, 0, 4, 0, 5, 0, 6, 0, 7, 0})
+	if res != "0000004 3\n0000006 \n" {
+		t.Errorf("every 3rd record with -n 1 wrong, got\n%s", res)
+	}
+}
+
+func TestStrZ(t *testing.T) {
+	defer func() { strTerm = 0 }()
+
+	res := dumpString("zC", "%s %d", []byte("hi\x00\x07\x00\x08"))
+	if res != "hi 7\n 8\n" {
+		t.Errorf("NUL terminated string wrong, got %q", res)
+	}
+
+	// Offsets advance by the bytes read, the NUL included
+	func() {
+		defer func(f string) { opt.printOffset, offsetFmt = false, f }(offsetFmt)
+		opt.printOffset, offsetFmt = true, "%d "
+		res = dumpString("z L", "%s %d", []byte("hi\x00\x05\x00\x00\x00abc\x00\x06\x00\x00\x00"))
+		if res != "0 hi 5\n7 abc 6\n15 \n" {
+			t.Errorf("offsets after NUL terminated strings wrong, got %q", res)
+		}
+	}()
+
+	strTerm = '|'
+	res = dumpString("zz", "%s,%s", []byte("ab|c|d|no end"))
+	if res != "ab,c\nd,no end\n" {
+		t.Errorf("string with custom terminator wrong, got %q", res)
+	}
+}
+
+func TestSwapFields(t *testing.T) {
+	defer func() { swapFields = nil }()
+	swapFields = parseFieldList("1,3", 4)
+
+	in := []byte{1, 0, 2, 0, 3, 0, 5, 0, 0, 0, 0, 0, 0, 0}
+	res := dumpString("SSsQ", "%x %x %x %x", in)
+	if res != "1 200 3 500000000000000\n" {
+		t.Error("swapped fields wrong, got", res)
+	}
+	if v := swapBytes(int16(0x00ff)); v != int16(-256) {
+		t.Error("swapped int16 wrong, got", v)
+	}
+	if l := parseFieldList("0,2-4", 5); len(l) != 4 || l[3] != 4 {
+		t.Error("field list wrong, got", l)
+	}
+
+	for _, s := range []string{"4", "2-1", "a", "-1"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("field list", s, "should be rejected")
+				}
+			}()
+			parseFieldList(s, 4)
+		}()
+	}
+}
+
+func TestOffsetDelta(t *testing.T) {
+	defer func() { opt.offsetDelta, opt.printOffset = false, false }()
+	opt.offsetDelta, opt.printOffset = true, true
+
+	res := dumpString("Cz", "%d %s", []byte("\x01abc\x00\x02\x00\x03de\x00"))
+	want := "0000000 +5 1 abc\n" +
+		"0000005 +2 2 \n" +
+		"0000007 +4 3 de\n" +
+		"000000b \n"
+	if res != want {
+		t.Errorf("offset delta output wrong, got\n%s", res)
+	}
+}
+
+func TestWarnPadding(t *testing.T) {
+	diag := new(bytes.Buffer)
+	diagOutput = diag
+	defer func() { paddedSize, opt.warnPadding, diagOutput = 0, false, os.Stderr }()
+	paddedSize, opt.warnPadding = 4, true
+
+	res := dumpString("S", "%d", []byte{1, 0, 0, 0, 2, 0, 0, 0})
+	if res != "1\n2\n" {
+		t.Error("padded records wrong, got", res)
+	}
+	if diag.Len() != 0 {
+		t.Error("zero padding should be silent, got", diag.String())
+	}
+
+	dumpString("S", "%d", []byte{1, 0, 0, 0, 2, 0, 0xab, 0})
+	if diag.String() != "Record 2 at offset 4: nonzero padding ab 00\n" {
+		t.Error("nonzero padding warning wrong, got", diag.String())
+	}
+}
+
+func TestStride(t *testing.T) {
+	defer func() { paddedSize, opt.printOffset = 0, false }()
+	if err := flag.Set("S", "4"); err != nil || opt.recSize != 4 {
+		t.Error("-S should set the record size, got", opt.recSize, err)
+	}
+	flag.Set("S", "0")
+	paddedSize, opt.printOffset = 4, true
+
+	res := dumpString("S", "%d", []byte{1, 0, 0xff, 0xff, 2, 0, 0xff, 0xff})
+	if res != "0000000 1\n0000004 2\n0000008 \n" {
+		t.Errorf("offsets should advance by the stride, got %q", res)
+	}
+}
+
+func TestDumpTrailing(t *testing.T) {
+	defer func() { opt.dumpTrailing = false }()
+	opt.dumpTrailing = true
+
+	res := dumpString("SL", "%d %d", []byte{1, 0, 2, 0, 0, 0, 0xde, 0xad, 0xbe})
+	if res != "1 2\nTrailing 3 bytes at offset 6: de ad be\n" {
+		t.Error("trailing bytes dump wrong, got", res)
+	}
+	if res := dumpString("S", "%d", []byte{1, 0}); res != "1\n" {
+		t.Error("no trailing bytes should print nothing more, got", res)
+	}
+}
+
+func TestRecordHash(t *testing.T) {
+	defer func() { recordHasher = nil }()
+
+	in := []byte{1, 2, 3, 4, 1, 2}
+	for _, name := range []string{"fnv", "crc32"} {
+		recordHasher = recordHashers[name]
+		res := strings.Split(dumpString("CC", "%d %d", in), "\n")
+		if len(res) != 4 || res[0] != res[2] || res[0] == res[1] {
+			t.Error(name, "hashes of identical records should be identical, got", res)
+		}
+	}
+	if res := dumpString("CC", "%d %d", in[:2]); res != "b6cc4292 1 2\n" {
+		t.Error("crc32 record hash wrong, got", res)
+	}
+}
+
+func TestUntil(t *testing.T) {
+	defer func() { sentinel = nil }()
+
+	in := []byte{1, 2, 0, 3, 0, 0, 4, 5}
+	sentinel = parseSentinel("all-zero", []bprint.FieldType{bprint.U8, bprint.U8})
+	if res := dumpString("CC", "%d %d", in); res != "1 2\n0 3\n" {
+		t.Error("records until all-zero record wrong, got", res)
+	}
+	sentinel = parseSentinel("f0 == 0", []bprint.FieldType{bprint.U8, bprint.U8})
+	if res := dumpString("CC", "%d %d", in); res != "1 2\n" {
+		t.Error("records until expression wrong, got", res)
+	}
+}
+
+func TestLinePad(t *testing.T) {
+	defer func() { opt.linePad, opt.lineLong = 0, "truncate" }()
+	opt.linePad = 6
+
+	res := dumpString("L", "%d", []byte{1, 0, 0, 0, 0x40, 0xe2, 0x01, 0})
+	if res != "1     \n123456\n" {
+		t.Errorf("padded lines wrong, got %q", res)
+	}
+	res = dumpString("L", "%d", []byte{0x15, 0xcd, 0x5b, 0x07})
+	if res != "123456\n" {
+		t.Errorf("long line should be truncated, got %q", res)
+	}
+
+	opt.lineLong = "error"
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("long line should be an error with -line-long error")
+		}
+	}()
+	dumpString("L", "%d", []byte{0x15, 0xcd, 0x5b, 0x07})
+}
+
+func TestAscending(t *testing.T) {
+	diag := new(bytes.Buffer)
+	diagOutput = diag
+	defer func() { ascending, diagOutput = nil, os.Stderr }()
+
+	in := []byte{1, 0, 2, 0, 2, 0, 1, 0, 0, 0}
+	ascending = &ascendingCheck{field: 0}
+	dumpString("S", "%d", in)
+	if diag.String() != "Record 4 at offset 6: field 0 value 1 not ascending after 2 at offset 4\n" {
+		t.Error("ascending violation wrong, got", diag.String())
+	}
+
+	diag.Reset()
+	ascending = &ascendingCheck{field: 0, strict: true}
+	dumpString("S", "%d", in)
+	if diag.String() != "Record 3 at offset 4: field 0 value 2 not ascending after 2 at offset 2\n" {
+		t.Error("strictly ascending violation wrong, got", diag.String())
+	}
+}
+
+func TestInputSep(t *testing.T) {
+	defer func() { inputSep, opt.printOffset = nil, false }()
+	inputSep = parseInputSep(`\n---\n`)
+	opt.printOffset = true
+
+	// Bytes after the fields of a chunk are skipped
+	in := []byte("\x01\x00\n---\n\x02\x00\xff\n---\n\x03\x00")
+	res := dumpString("S", "%d", in)
+	if res != "0000000 1\n0000007 2\n000000f 3\n0000011 \n" {
+		t.Errorf("records split on separator wrong, got %q", res)
+	}
+
+	// Empty and short chunks are reported, the next chunks are decoded
+	diag := new(bytes.Buffer)
+	diagOutput = diag
+	defer func() { diagOutput, truncatedCnt = os.Stderr, 0 }()
+	truncatedCnt = 0
+	in = []byte("\x01\x00\n---\n\n---\n\x02\n---\n\x03\x00")
+	res = dumpString("S", "%d", in)
+	// The short chunk is printed like a partial record at EOF, here only its
+	// offset as it has no whole field
+	if res != "0000000 1\n000000c \n0000012 3\n0000014 \n" {
+		t.Errorf("records after empty and short chunks wrong, got %q", res)
+	}
+	if diag.String() != "Empty chunk at offset 7 skipped\n"+
+		"Record 2 at offset 12: truncated at end of chunk after 0 of 1 fields\n" {
+		t.Errorf("empty and short chunks should be reported, got %q", diag.String())
+	}
+	if truncatedCnt != 1 {
+		t.Errorf("short chunk should be counted as truncated, got %d", truncatedCnt)
+	}
+	// The fields of a short chunk are printed
+	diag.Reset()
+	in = []byte("\x01\x00\x02\x00\n---\n\x03\x00\x04\n---\n\x05\x00\x06\x00")
+	res = dumpString("SS", "%d %d", in)
+	if res != "0000000 1 2\n0000009 3\n0000011 5 6\n0000015 \n" {
+		t.Errorf("partial chunk should be printed with its fields, got %q", res)
+	}
+}
+
+func TestMinBytes(t *testing.T) {
+	diag := new(bytes.Buffer)
+	diagOutput = diag
+	defer func() { opt.minBytes, diagOutput = 0, os.Stderr }()
+	opt.minBytes = 4
+
+	res := dumpString("Cz", "%d %s", []byte{1, 'a', 'b', 'c', 0, 2, 0, 3, 'x', 'y', 'z', 0})
+	if res != "1 abc\n3 xyz\n" {
+		t.Error("records without runt frames wrong, got", res)
+	}
+	if diag.String() != "Dropped 2 byte record at offset 5, shorter than 4 bytes\n" {
+		t.Error("runt frame note wrong, got", diag.String())
+	}
+}
+
+func TestOnChange(t *testing.T) {
+	defer func() { onChange, opt.printRecordCnt = nil, false }()
+	onChange = &changeCheck{field: 0}
+	opt.printRecordCnt = true
+
+	in := []byte{1, 10, 1, 11, 1, 12, 2, 13, 2, 14, 1, 15}
+	if res := dumpString("CC", "%d %d", in); res != "1: 1 10\n4: 2 13\n6: 1 15\n" {
+		t.Error("records on change wrong, got", res)
+	}
+}
+
+func TestPrefixSuffix(t *testing.T) {
+	defer func() { opt.prefix, opt.suffix, opt.printOffset, opt.linePad = "", "", false, 0 }()
+	opt.prefix, opt.suffix, opt.printOffset = "> ", " <", true
+
+	res := dumpString("C", "%d", []byte{1, 2})
+	if res != "> 0000000 1 <\n> 0000001 2 <\n0000002 \n" {
+		t.Errorf("record lines wrapped wrong, got %q", res)
+	}
+	opt.linePad = 14
+	if res := dumpString("C", "%d", []byte{1}); res != "> 0000000 1 < \n0000001 \n" {
+		t.Errorf("wrapped line should be padded, got %q", res)
+	}
+}
+
+func TestEscapeStrings(t *testing.T) {
+	defer func() { opt.jsonOutput, output = false, os.Stdout }()
+	in := []byte{'a', 1, 'b', 0, 7}
+	if res := dumpString("z C", "%s %d", in); res != `a\x01b 7`+"\n" {
+		t.Errorf("z string should be escaped with %%s, got %q", res)
+	}
+	if res := dumpString("z C", "%q %d", in); res != `"a\x01b" 7`+"\n" {
+		t.Errorf("z string should only be quoted with %%q, got %q", res)
+	}
+	opt.jsonOutput = true
+	if res := dumpString("z C", "%s %d", in); res != `{"f0":"a\u0001b","f1":7}`+"\n" {
+		t.Errorf("JSON string should not be escaped twice, got %q", res)
+	}
+}
+
+func TestNulRecordEnd(t *testing.T) {
+	defer func() { recordEnd, opt.prefix, opt.printOffset, opt.linePad = "\n", "", false, 0 }()
+	recordEnd, opt.printOffset = "\x00", true
+
+	res := dumpString("C a3", "%d %s", []byte{1, 'a', ' ', 'b', 2, 'c', '\n', 'd'})
+	// The newline in the string is escaped by %s
+	if res != "0000000 1 a b\x000000004 2 c\\x0ad\x000000008 \x00" {
+		t.Errorf("records should end with NUL, got %q", res)
+	}
+	opt.prefix, opt.linePad = "> ", 8
+	if res := dumpString("C", "%d", []byte{1}); res != "> 000000\x000000001 \x00" {
+		t.Errorf("wrapped record should end with NUL, got %q", res)
+	}
+}
+
+func TestEmptyPrintFmt(t *testing.T) {
+	defer func() { emptyPrintFmt, opt.printOffset = false, false }()
+	emptyPrintFmt, opt.printOffset = true, true
+
+	res := dumpString("S", "", []byte{1, 0, 2, 0})
+	if res != "0000000 \n0000002 \n0000004 \n" {
+		t.Errorf("empty print format should print offsets only, got %q", res)
+	}
+}
+
+func TestASCIISidebar(t *testing.T) {
+	defer func() { opt.ascii, opt.printOffset = false, false }()
+	opt.ascii, opt.printOffset = true, true
+
+	res := dumpString("C4", "%02x4#", []byte("hi\x00\x7fABCD"))
+	if res != "0000000 68 69 00 7f  |hi..|\n0000004 41 42 43 44  |ABCD|\n0000008 \n" {
+		t.Errorf("ASCII sidebar wrong, got %q", res)
+	}
+	res = dumpString("Sz", "%d %s", []byte{'a', 0, 'o', 'k', 0})
+	if res != "0000000 97 ok  |a.ok.|\n0000005 \n" {
+		t.Errorf("ASCII sidebar should cover all bytes of the record, got %q", res)
+	}
+}
+
+func TestGroupSpec(t *testing.T) {
+	defer func() { opt.jsonOutput, fieldNames = false, nil }()
+	opt.jsonOutput = true
+
+	spec := "L S (C4)3"
+	_, fieldNames, _, _ = bprint.ParseNamedSpec(spec)
+	in := []byte{1, 0, 0, 0, 2, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	res := dumpString(spec, "%d2# %d12#", in)
+	want := `{"f0":1,"f1":2,` +
+		`"g0[0].0":1,"g0[0].1":2,"g0[0].2":3,"g0[0].3":4,` +
+		`"g0[1].0":5,"g0[1].1":6,"g0[1].2":7,"g0[1].3":8,` +
+		`"g0[2].0":9,"g0[2].1":10,"g0[2].2":11,"g0[2].3":12}` + "\n"
+	if res != want {
+		t.Error("group record output wrong, got", res)
+	}
+}
+
+func TestPrintSpecTree(t *testing.T) {
+	tree, err := bprint.ParseSpecTree("L S2 x (C (s)2)3 c")
+	if err != nil {
+		t.Fatal("spec not parsed", err)
+	}
+	buf := new(bytes.Buffer)
+	printSpecTree(buf, tree)
+	want := "f0 uint32 offset 0 size 4\n" +
+		"f1-f2 uint16 x2 offset 4 size 4\n" +
+		"- skip offset 8 size 1\n" +
+		"g0 group x3 offset 9 size 15\n" +
+		"  0 uint8 offset 9 size 1\n" +
+		"  g0 group x2 offset 10 size 4\n" +
+		"    0 int16 offset 10 size 2\n" +
+		"f12 int8 offset 24 size 1\n"
+	if buf.String() != want {
+		t.Errorf("spec tree wrong, got\n%s", buf.String())
+	}
+}
+
+func TestRepeatToEnd(t *testing.T) {
+	in := []byte{2, 1, 0, 2, 0, 3, 0}
+	if res := dumpString("C S*", "%d %v", in); res != "2 [1 2 3]\n" {
+		t.Errorf("fields repeated to the end wrong, got %q", res)
+	}
+
+	// A partial element at EOF is dropped, the record is still whole
+	diag := new(bytes.Buffer)
+	diagOutput, truncatedCnt = diag, 0
+	defer func() { diagOutput = os.Stderr }()
+	if res := dumpString("C S*", "%d %v", append(in, 4)); res != "2 [1 2 3]\n" || truncatedCnt != 0 {
+		t.Errorf("partial element should be dropped, got %q and %d truncated", res, truncatedCnt)
+	}
+	if want := "Record 1 at offset 0: partial list element at EOF dropped\n"; diag.String() != want {
+		t.Errorf("dropped element report wrong, got %q", diag.String())
+	}
+	tree, _ := bprint.ParseSpecTree("C S*:samples")
+	buf := new(bytes.Buffer)
+	printSpecTree(buf, tree)
+	if want := "f0 uint8 offset 0 size 1\nsamples uint16 x* offset 1 size 0\n"; buf.String() != want {
+		t.Errorf("spec tree of a field repeated to the end wrong, got\n%s", buf.String())
+	}
+}
+
+func TestRecordLimit(t *testing.T) {
+	defer func() { opt.limit, opt.printOffset, opt.printRecordCnt, recordFilter = 0, false, false, nil }()
+	opt.limit, opt.printOffset, opt.printRecordCnt = 2, true, true
+
+	in := []byte{1, 2, 3, 4, 5}
+	if res := dumpString("C", "%d", in); res != "0000000 1: 1\n0000001 2: 2\n0000002 \n" {
+		t.Errorf("record limit wrong, got %q", res)
+	}
+	r := bytes.NewReader(in)
+	formatField, recordSize, _ := parseBinaryFmt("C")
+	output = new(bytes.Buffer)
+	defer func() { output = os.Stdout }()
+	recordCnt, offSet = 0, 0
+	dumpRecords(r, formatField, recordSize)
+	if r.Len() != 3 {
+		t.Error("reading should stop after the limit, left", r.Len(), "bytes")
+	}
+
+	// Filtered records don't count
+	recordFilter = parseExpr("f0 != 2", []bprint.FieldType{bprint.U8})
+	if res := dumpString("C", "%d", in); res != "0000000 1: 1\n0000002 3: 3\n0000003 \n" {
+		t.Errorf("record limit with filter wrong, got %q", res)
+	}
+	opt.limit = 0
+	if res := dumpString("C", "%d", in[:2]); res != "0000000 1: 1\n0000002 \n" {
+		t.Errorf("limit 0 should print all records, got %q", res)
+	}
+}
+
+func TestFieldUnits(t *testing.T) {
+	defer func() { fieldUnits = nil }()
+	fieldUnits = parseFieldUnits("1:°C,2: kPa,0:%", 3)
+
+	res := dumpString("CcS", "%d %d %d", []byte{50, 0xfb, 0xe8, 0x03})
+	if res != "50% -5°C 1000 kPa\n" {
+		t.Error("units not printed after the fields, got", res)
+	}
+
+	for _, s := range []string{"3:V", "x:V", ":V", "1"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("unit", s, "should be rejected")
+				}
+			}()
+			parseFieldUnits(s, 3)
+		}()
+	}
+}
+
+func TestSkipHeader(t *testing.T) {
+	defer func() { opt.printOffset, offSet = false, 0 }()
+	opt.printOffset = true
+	formatField, recordSize, _ := parseBinaryFmt("S")
+	opt.printFmt = convertPrintFields("%d") + "\n"
+	in := []byte{0xff, 0xff, 0xff, 1, 0, 2, 0}
+
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, in, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, seekable := range []bool{true, false} {
+		r, f := openFile(path)
+		if !seekable {
+			// Like a pipe, bytes have to be read to be skipped
+			r, f = bufio.NewReader(bytes.NewReader(in)), nil
+		}
+		buf := new(bytes.Buffer)
+		output = buf
+		recordCnt = 0
+		skipHeader(r, f, parseByteCount("skip", "0x3"))
+		dumpRecords(r, formatField, recordSize)
+		output = os.Stdout
+		if f != nil {
+			f.Close()
+		}
+		if buf.String() != "0000003 1\n0000005 2\n0000007 \n" {
+			t.Errorf("header not skipped, seekable %v, got %q", seekable, buf.String())
+		}
+	}
+
+	for _, seekable := range []bool{true, false} {
+		func() {
+			r, f := openFile(path)
+			defer f.Close()
+			if !seekable {
+				r, f = bufio.NewReader(bytes.NewReader(in)), nil
+			}
+			defer func() {
+				if err := recover(); err != "While skipping 8 bytes: input has only 7 bytes" {
+					t.Errorf("skip past the end not reported, seekable %v, got %v", seekable, err)
+				}
+			}()
+			skipHeader(r, f, 8)
+		}()
+	}
+
+	for _, s := range []string{"-1", "0xg", "1K"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("skip", s, "should be rejected")
+				}
+			}()
+			parseByteCount("skip", s)
+		}()
+	}
+}
+
+func TestByteLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(path, []byte{0xff, 1, 0, 2, 0, 3}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	formatField, recordSize, _ := parseBinaryFmt("S")
+	opt.printFmt = convertPrintFields("%d") + "\n"
+	defer func() { opt.printOffset, output = false, os.Stdout }()
+	opt.printOffset = true
+	buf := new(bytes.Buffer)
+	output = buf
+
+	// The limit is after the skipped byte and cuts the 2nd record
+	recordCnt = 0
+	decodeFiles([]string{path}, 1, formatField, recordSize, 1, parseByteCount("byte limit", "3"))
+	if buf.String() != "0000001 1\n0000003 \n" {
+		t.Errorf("input not limited after the skipped bytes, got %q", buf.String())
+	}
+}
+
+func TestGunzip(t *testing.T) {
+	dir := t.TempDir()
+	zbuf := new(bytes.Buffer)
+	zw := gzip.NewWriter(zbuf)
+	zw.Write([]byte{0, 0, 1, 0, 2, 0, 3})
+	zw.Close()
+	paths := []string{filepath.Join(dir, "data.gz"), filepath.Join(dir, "data.bin")}
+	os.WriteFile(paths[0], zbuf.Bytes(), 0644)
+	os.WriteFile(paths[1], []byte{0, 0, 1, 0, 2, 0, 3}, 0644)
+
+	formatField, recordSize, _ := parseBinaryFmt("S")
+	defer func() { opt.gunzip, opt.printOffset = false, false }()
+	opt.gunzip, opt.printOffset = true, true
+	for _, path := range paths {
+		r, f := openFile(path)
+		skipHeader(r, f, 2)
+		res := new(bytes.Buffer)
+		output = res
+		recordCnt = 0
+		opt.printFmt = convertPrintFields("%d") + "\n"
+		dumpRecords(r, formatField, recordSize)
+		output = os.Stdout
+		f.Close()
+		// Offsets are of the decompressed bytes
+		if res.String() != "0000002 1\n0000004 2\n0000006 \n" {
+			t.Errorf("%s not decoded, got %q", filepath.Base(path), res.String())
+		}
+	}
+}
+
+func TestNamedFields(t *testing.T) {
+	defer func() { opt.nameHeader, opt.printOffset, opt.jsonOutput, fieldNames = false, false, false, nil }()
+	opt.nameHeader, opt.printOffset = true, true
+
+	spec := "c:flags S:length C2 L:crc"
+	_, fieldNames, _, _ = bprint.ParseNamedSpec(spec)
+	in := []byte{1, 2, 0, 3, 4, 5, 0, 0, 0}
+	res := dumpString(spec, "%d %d %d %d %d", in)
+	if res != "offset flags length f2 f3 crc\n0000000 1 2 3 4 5\n0000009 \n" {
+		t.Errorf("named header wrong, got %q", res)
+	}
+
+	opt.nameHeader, opt.printOffset, opt.jsonOutput = false, false, true
+	res = dumpString(spec, "%d %d %d %d %d", in)
+	if res != `{"flags":1,"length":2,"f2":3,"f3":4,"crc":5}`+"\n" {
+		t.Error("JSON keys should be the field names, got", res)
+	}
+
+	for _, names := range [][]string{{"len", "len"}, {"f1", ""}} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("field names", names, "should be rejected")
+				}
+			}()
+			fieldNames = names
+			checkFieldNames(2)
+		}()
+	}
+}
+
+func TestOffsetFmt(t *testing.T) {
+	defer func() { offsetFmt, opt.printOffset = "%07x ", false }()
+	testData := []struct {
+		s    string
+		size int64
+		fmt  string
+	}{
+		{"hex", 100, "%07x "},
+		{"hex", 0x123456789, "%09x "},
+		{"dec", 0, "%07d "},
+		{"oct:3", 0x123456789, "%03o "},
+	}
+	for _, td := range testData {
+		if f := parseOffsetFmt(td.s, td.size); f != td.fmt {
+			t.Error("offset format", td.s, "for size", td.size, "should be", td.fmt, "got", f)
+		}
+	}
+
+	offsetFmt, opt.printOffset = parseOffsetFmt("dec:4", 0), true
+	if res := dumpString("S", "%d", make([]byte, 22)); !strings.HasPrefix(res, "0000 0\n0002 0\n") || !strings.HasSuffix(res, "0022 \n") {
+		t.Errorf("decimal offsets wrong, got %q", res)
+	}
+
+	for _, s := range []string{"bin", "hex:", "dec:0", ":5"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("offset format", s, "should be rejected")
+				}
+			}()
+			parseOffsetFmt(s, 0)
+		}()
+	}
+}
+
+func TestShortPrintFmt(t *testing.T) {
+	// The default print format of C16
+	printFmt := strings.Repeat("%02x ", 15) + "%02x"
+	in := make([]byte, 21)
+	for i := range in {
+		in[i] = byte(i)
+	}
+	lines := strings.Split(strings.TrimSuffix(dumpString("C16", printFmt, in), "\n"), "\n")
+	if len(lines) != 2 || lines[1] != "10 11 12 13 14" {
+		t.Errorf("partial record should have 5 hex columns, got %q", lines)
+	}
+
+	for _, c := range []struct {
+		printFmt string
+		cnt      int
+		want     string
+	}{
+		{"%d, %d, %d\n", 1, "%d\n"},
+		{"[%d %x]\n", 0, "[]\n"},
+		{"%d %%%d", 1, "%d"},
+		{"%d %d", 2, "%d %d"},
+	} {
+		if res := shortPrintFmt(c.printFmt, c.cnt); res != c.want {
+			t.Errorf("print format %q for %d fields should be %q, got %q", c.printFmt, c.cnt, c.want, res)
+		}
+	}
+}
+
+func TestHexBytes(t *testing.T) {
+	defer func() { hexDigits = nil }()
+	in := make([]byte, 1+2+3+4+8+1)
+	for i := range in {
+		in[i] = 0xff
+	}
+	in[len(in)-1] = 5
+	fields, _, _ := parseBinaryFmt("csmlqC")
+	hexDigits = hexFieldDigits(fields, fields)
+	// The default print format has all the digits of each field
+	res := dumpString("csmlqC", generatePrintFmt(fields, " "), in)
+	if res != "ff ffff ffffff ffffffff ffffffffffffffff 05\n" {
+		t.Errorf("-1 of each width should be all ff bytes, got %q", res)
+	}
+	// The print fields of the user format the unsigned value
+	res = dumpString("csmlqC", "%d %x %X %d <%-6x> %d", in)
+	if res != "255 ffff FFFFFF 4294967295 <ffffffffffffffff> 5\n" {
+		t.Errorf("-1 of each width with explicit verbs wrong, got %q", res)
+	}
+	res = dumpString("cC", "<%-6x>|%4d", []byte{0xff, 5})
+	if res != "<ff    >|   5\n" {
+		t.Errorf("width and flags of print fields should be kept, got %q", res)
+	}
+	fields, _, _ = parseBinaryFmt("o")
+	hexDigits = hexFieldDigits(fields, fields)
+	if res = dumpString("o", "%x", bytes.Repeat([]byte{0xff}, 16)); res != strings.Repeat("f", 32)+"\n" {
+		t.Errorf("int128 -1 should be all ff bytes, got %q", res)
+	}
+
+	// Bit ranges take the digits of their bits
+	defer func() { specBits = nil }()
+	tree, _ := bprint.ParseSpecTree("S{0-2,3-15}")
+	specBits = bprint.SpecBits(tree)
+	fields, _, _ = parseBinaryFmt("S")
+	hexDigits = hexFieldDigits(fields, splitBitTypes(fields))
+	if !reflect.DeepEqual(hexDigits, []int{1, 4}) {
+		t.Error("hex digits of bit ranges wrong, got", hexDigits)
+	}
+}
+
+func TestPartialRecordOffset(t *testing.T) {
+	defer func() { opt.printOffset = false }()
+	opt.printOffset = true
+
+	// 3 records of 6 bytes, then 4 bytes of the next one
+	in := make([]byte, 3*6+4)
+	lines := strings.Split(strings.TrimSuffix(dumpString("SL", "%d %d", in), "\n"), "\n")
+	if len(lines) != 4 || lines[3] != "0000012 0" {
+		t.Errorf("partial record should start at offset 0x12, got %q", lines)
+	}
+	// Without any field decoded, only the offset is printed
+	lines = strings.Split(strings.TrimSuffix(dumpString("LS", "%d %d", in[:3*6+3]), "\n"), "\n")
+	if len(lines) != 4 || lines[3] != "0000012 " {
+		t.Errorf("offset of trailing bytes should be 0x12, got %q", lines)
+	}
+}
+
+func TestTimeFields(t *testing.T) {
+	defer func() { timeFields = nil }()
+	fields, _, _ := parseBinaryFmt("qLC")
+	timeFields = parseTimeFields("0:ms,1", fields)
+
+	in := []byte{
+		0x18, 0xfc, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0x00, 0xe1, 0xf5, 0x05,
+		7,
+	}
+	// A signed field before the epoch and an unsigned one, the verb doesn't
+	// matter and the other field is untouched
+	if res := dumpString("qLC", "%d %x %d", in); res != "1969-12-31T23:59:59Z 1973-03-03T09:46:40Z 7\n" {
+		t.Error("timestamp fields wrong, got", res)
+	}
+
+	for _, s := range []string{"x", "0:ns", "3", "0:", ":ms"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("timestamp field", s, "should be rejected")
+				}
+			}()
+			parseTimeFields(s, fields)
+		}()
+	}
+}
+
+func TestForceSign(t *testing.T) {
+	defer func() { opt.forceUnsigned, opt.forceSigned = false, false }()
+	in := []byte{0xff, 0xfe, 0xff}
+
+	fields, size, _ := parseBinaryFmt("cs")
+	opt.forceUnsigned = true
+	forceSign(fields)
+	if res := dumpFields(fields, size, "%d %d", in); res != "255 65534\n" {
+		t.Error("-u should read signed fields as unsigned, got", res)
+	}
+
+	fields, size, _ = parseBinaryFmt("CS")
+	opt.forceUnsigned, opt.forceSigned = false, true
+	forceSign(fields)
+	if res := dumpFields(fields, size, "%d %d", in); res != "-1 -2\n" {
+		t.Error("-i should read unsigned fields as signed, got", res)
+	}
+
+	fields, size, _ = parseBinaryFmt("v")
+	forceSign(fields)
+	if res := dumpFields(fields, size, "%d", []byte{0x7f}); res != "-1\n" {
+		t.Error("-i should read LEB128 numbers as signed, got", res)
+	}
+}
+
+func TestReadSpecFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spec")
+	if err := os.WriteFile(path, []byte("c   # flags\n s  # length\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	spec := readSpecFile(path)
+	if res := dumpString(spec, "%d %d", []byte{0xff, 2, 0}); res != "-1 2\n" {
+		t.Error("spec read from file not decoded correctly, got", res)
+	}
+}
+
+func TestAbsoluteOffset(t *testing.T) {
+	in := []byte{1, 0, 0, 0, 0xaa, 0xbb, 2, 0}
+	if res := dumpString("@0 L @6 S", "%d %d", in); res != "1 2\n" {
+		t.Error("@ offsets not decoded correctly, got", res)
+	}
+	if res := dumpString("L @0 C4", "%d %d %d %d %d", []byte{1, 2, 0, 0}); res != "513 1 2 0 0\n" {
+		t.Error("@ moving back not decoded correctly, got", res)
+	}
+	for spec, want := range map[string]bool{"@16 S": false, "@file": true, "@": false, "@./16": true} {
+		if isSpecFile(spec) != want {
+			t.Error("isSpecFile", spec, "should be", want)
+		}
+	}
+}
+
+func TestExpectRecords(t *testing.T) {
+	defer func() { opt.expectRecords = -1 }()
+	in := []byte{1, 0, 2, 0, 3}
+
+	opt.expectRecords = 2
+	for _, td := range []struct {
+		in  []byte
+		err interface{}
+	}{
+		{in[:4], nil},
+		{in, "Input has more than the 2 records expected by -r"},
+		{in[:3], "Input has 1 complete records, 2 expected by -r"},
+	} {
+		func() {
+			defer func() {
+				if err := recover(); err != td.err {
+					t.Errorf("% x should give %v, got %v", td.in, td.err, err)
+				}
+			}()
+			dumpString("S", "%d", td.in)
+		}()
+	}
+}
+
+func TestTruncatedRecord(t *testing.T) {
+	diag := new(bytes.Buffer)
+	diagOutput, truncatedCnt = diag, 0
+	defer func() { diagOutput, truncatedCnt = os.Stderr, 0 }()
+
+	dumpString("SSL", "%d %d %d", []byte{1, 0, 2, 0, 3, 0, 4, 0, 5, 0, 6})
+	if s := diag.String(); s != "Record 2 at offset 8: truncated at EOF after 1 of 3 fields\n" || truncatedCnt != 1 {
+		t.Errorf("truncated record not reported, got %q", s)
+	}
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("truncated record should fail")
+		} else if _, ok := err.(reportedError); !ok {
+			t.Error("a single truncated record was reported already, got", err)
+		}
+	}()
+	checkTruncated()
+}
+
+func TestCountOnly(t *testing.T) {
+	defer func() { opt.countOnly, opt.limit, recordFilter = false, 0, nil }()
+	opt.countOnly = true
+	in := []byte{1, 0, 2, 0, 3, 0, 4}
+
+	if res := dumpString("S", "%d", in); res != "3 records, 6 bytes\n" {
+		t.Errorf("records not counted, got %q", res)
+	}
+	opt.limit = 2
+	if res := dumpString("S", "%d", in); res != "2 records, 4 bytes\n" {
+		t.Errorf("-n should bound the count, got %q", res)
+	}
+	opt.limit = 0
+	recordFilter = parseExpr("f0 > 1", []bprint.FieldType{bprint.U16})
+	if res := dumpString("S", "%d", in); res != "2 records, 6 bytes\n" {
+		t.Errorf("only records matching -filter should be counted, got %q", res)
+	}
+}
+
+func TestEnvFormats(t *testing.T) {
+	defer func() { opt.binaryFmt, opt.printFmt = "", "" }()
+	t.Setenv("BPRINT_FMT", "cL")
+	t.Setenv("BPRINT_PFMT", "%d %x")
+
+	opt.binaryFmt, opt.printFmt = "", ""
+	envFormats()
+	if opt.binaryFmt != "cL" || opt.printFmt != "%d %x" {
+		t.Error("formats not taken from the environment, got", opt.binaryFmt, opt.printFmt)
+	}
+	opt.binaryFmt, opt.printFmt = "S", "%d"
+	envFormats()
+	if opt.binaryFmt != "S" || opt.printFmt != "%d" {
+		t.Error("given formats should override the environment, got", opt.binaryFmt, opt.printFmt)
+	}
+}
+
+func TestRecordGroup(t *testing.T) {
+	defer func() { opt.group, opt.printOffset = 1, false }()
+	opt.group, opt.printOffset = 3, true
+	res := dumpString("C", "%02x", []byte{1, 2, 3, 4, 5})
+	if res != "0000000 01 02 03\n0000003 04 05\n0000005 \n" {
+		t.Error("grouped records wrong, got", res)
+	}
+	opt.printOffset = false
+	if res := dumpString("C", "%02x", []byte{1, 2, 3}); res != "01 02 03\n" {
+		t.Error("full group wrong, got", res)
+	}
+}
+
+func TestCountWidth(t *testing.T) {
+	defer func() { countFmt, opt.printRecordCnt = "%d: ", false }()
+	countFmt, opt.printRecordCnt = "%03d: ", true
+	if res := dumpString("C", "%d", []byte{7, 8}); res != "001: 7\n002: 8\n" {
+		t.Error("padded record count wrong, got", res)
+	}
+}
+
+func TestReadSpecLine(t *testing.T) {
+	r := bufio.NewReader(bytes.NewReader([]byte("C S\r\n\x01\x02\x00")))
+	spec, size := readSpecLine(r)
+	if spec != "C S" || size != 5 {
+		t.Errorf("spec line wrong, got %q of %d bytes", spec, size)
+	}
+	if rest, _ := io.ReadAll(r); !bytes.Equal(rest, []byte{1, 2, 0}) {
+		t.Error("data after the spec line lost, got", rest)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("input without a spec line should panic")
+		}
+	}()
+	readSpecLine(bufio.NewReader(bytes.NewReader([]byte("C S"))))
+}
+
+func TestExplain(t *testing.T) {
+	defer func(f string) { offsetFmt = f }(offsetFmt)
+	offsetFmt = "%d "
+	fields, names, _, err := bprint.ParseNamedSpec("c S:len x2 a3 z L")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	printExplain(&b, fields, names)
+	want := "f0 int8 size 1 offset 0\n" +
+		"len uint16 size 2 offset 1\n" +
+		"f2 str size 3 offset 5\n" +
+		"f3 string size var offset 8\n" +
+		"f4 uint32 size 4 offset ?\n"
+	if b.String() != want {
+		t.Errorf("explain output wrong, got\n%s", b.String())
+	}
+}
+
+// badReader fails once when reading the byte at offset bad.
+type badReader struct {
+	r        io.Reader
+	pos, bad int
+	failed   bool
+}
+
+func (br *badReader) Read(p []byte) (int, error) {
+	if !br.failed && br.pos <= br.bad && br.bad < br.pos+len(p) {
+		if br.pos == br.bad {
+			br.failed = true
+			return 0, errors.New("bad sector")
+		}
+		p = p[:br.bad-br.pos]
+	}
+	n, err := br.r.Read(p)
+	br.pos += n
+	return n, err
+}
+
+func TestKeepGoing(t *testing.T) {
+	diag := new(bytes.Buffer)
+	diagOutput, skippedCnt = diag, 0
+	defer func() { opt.keepGoing, diagOutput, skippedCnt = false, os.Stderr, 0 }()
+	opt.keepGoing = true
+
+	opt.printFmt = "%d" + recordEnd
+	recordCnt, offSet = 0, 0
+	buf := new(bytes.Buffer)
+	output = buf
+	defer func() { output = os.Stdout }()
+	formatField, recordSize, _ := parseBinaryFmt("S")
+	dumpRecords(&badReader{r: bytes.NewReader([]byte{1, 0, 2, 0, 3, 0}), bad: 3}, formatField, recordSize)
+	if res := buf.String(); res != "1\n3\n" {
+		t.Error("bad record not skipped, got", res)
+	}
+	if s := diag.String(); s != "Record 2 at offset 2: read error: bad sector, skipped\n" || skippedCnt != 1 {
+		t.Error("skipped record report wrong, got", s, skippedCnt)
+	}
+
+	if fixedRecord([]bprint.FieldType{bprint.U8, bprint.STRZ}) || !fixedRecord([]bprint.FieldType{bprint.U8, bprint.STR}) {
+		t.Error("fixed size record check wrong")
+	}
+}
+
+func TestReadError(t *testing.T) {
+	diag := new(bytes.Buffer)
+	diagOutput, readErrorCnt = diag, 0
+	defer func() { diagOutput, readErrorCnt = os.Stderr, 0 }()
+
+	opt.printFmt = "%d" + recordEnd
+	recordCnt, offSet = 0, 0
+	buf := new(bytes.Buffer)
+	output = buf
+	defer func() { output = os.Stdout }()
+	formatField, recordSize, _ := parseBinaryFmt("S")
+	dumpRecords(&badReader{r: bytes.NewReader([]byte{1, 0, 2, 0}), bad: 2}, formatField, recordSize)
+	if readErrorCnt != 1 || !strings.Contains(diag.String(), "While reading data: read error: bad sector") {
+		t.Error("read error not counted, got", readErrorCnt, diag.String())
+	}
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("read error should make checkReadErrors panic")
+		}
+	}()
+	checkReadErrors()
+}
+
+func TestTerminalBinaryFmt(t *testing.T) {
+	defer func() { opt.printOffset, opt.ascii = false, false }()
+	for _, c := range []struct {
+		width         int
+		offset, ascii bool
+		want          string
+	}{
+		{0, false, false, "C16"},
+		{80, false, false, "C24"},
+		{80, true, false, "C24"},
+		{80, true, true, "C16"},
+		{132, false, false, "C44"},
+		{10, false, false, "C4"},
+	} {
+		opt.printOffset, opt.ascii = c.offset, c.ascii
+		if res := terminalBinaryFmt(c.width); res != c.want {
+			t.Errorf("binary format for %d columns wrong, got %s, expected %s", c.width, res, c.want)
+		}
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	fields, _, _ := parseBinaryFmt("Cf")
+	// Bad option values are spec errors, main exits with exitSpecError
+	for name, parse := range map[string]func(){
+		"-fields":      func() { parseFieldList("x", 2) },
+		"-records":     func() { parseRecordRanges("x") },
+		"-m":           func() { parseFieldScales("bad", fields) },
+		"-q":           func() { parseFixedPoint("bad", fields) },
+		"-enum":        func() { parseEnum("bad", 2) },
+		"-O":           func() { parseOffsetFmt("foo", 0) },
+		"-range-check": func() { parseRangeChecks("0", fields) },
+		"-mask":        func() { parseFieldMasks("1:0x0f", fields) },
+		"-bitwidth":    func() { parseSignWidths("1:4", fields) },
+		"-array":       func() { parseVarArray("1:S", fields) },
+		"-filter":      func() { parseExpr("f0 >", fields) },
+	} {
+		func() {
+			defer func() {
+				if _, ok := recover().(specError); !ok {
+					t.Error("bad", name, "should be a spec error")
+				}
+			}()
+			parse()
+		}()
+	}
+}
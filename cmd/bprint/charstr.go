@@ -0,0 +1,110 @@
+package main
+
+// -as-string prints a run of byte fields, like the 32 fields of C32 for a
+// fixed size char array, as one string field.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gastaoss/bprint"
+)
+
+type charArray struct {
+	field, cnt int
+}
+
+var charString *charArray
+
+// parseCharArray parses a run of byte fields like "0:32" for 32 fields from
+// field 0.
+func parseCharArray(s string, fields []bprint.FieldType) *charArray {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		panic(specError{fmt.Errorf("Invalid string field '%s', should be like 0:32", s)})
+	}
+	field, err1 := strconv.Atoi(parts[0])
+	cnt, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || field < 0 || cnt <= 0 {
+		panic(specError{fmt.Errorf("Invalid string field '%s', should be like 0:32", s)})
+	}
+	if field+cnt > len(fields) {
+		panic(specError{fmt.Errorf("String fields %d to %d out of range, record has %d fields", field, field+cnt-1, len(fields))})
+	}
+	for i := field; i < field+cnt; i++ {
+		if fields[i] != bprint.U8 && fields[i] != bprint.I8 {
+			panic(specError{fmt.Errorf("String field %d is %s, should be a byte", i, fields[i].String())})
+		}
+	}
+	return &charArray{field, cnt}
+}
+
+// groupTypes returns the types of the fields after grouping.
+func (ca *charArray) groupTypes(fields []bprint.FieldType) []bprint.FieldType {
+	res := append([]bprint.FieldType{}, fields[:ca.field]...)
+	res = append(res, bprint.STRZ)
+	return append(res, fields[ca.field+ca.cnt:]...)
+}
+
+// groupNames returns the names of the fields after grouping.
+func (ca *charArray) groupNames(names []string) []string {
+	res := append([]string{}, names[:ca.field]...)
+	res = append(res, names[ca.field])
+	return append(res, names[ca.field+ca.cnt:]...)
+}
+
+// group replaces the byte fields in data by the trimmed string. A partial
+// record only has the bytes read.
+func (ca *charArray) group(data []interface{}) []interface{} {
+	if len(data) <= ca.field {
+		return data
+	}
+	end := ca.field + ca.cnt
+	if end > len(data) {
+		end = len(data)
+	}
+	str := make([]byte, 0, ca.cnt)
+	for _, v := range data[ca.field:end] {
+		str = append(str, byte(toInt64(v)))
+	}
+	res := append([]interface{}{}, data[:ca.field]...)
+	res = append(res, trimString(string(str), opt.stringTrim))
+	return append(res, data[end:]...)
+}
+
+// trimString trims the padding at the end of a fixed size string: NUL bytes
+// for nul, NUL and spaces for space, nothing for none.
+func trimString(s, trim string) string {
+	switch trim {
+	case "nul":
+		return strings.TrimRight(s, "\x00")
+	case "space":
+		return strings.TrimRight(s, "\x00 ")
+	}
+	return s
+}
+
+// printableString returns s with the bytes which aren't valid UTF-8 or are
+// control characters escaped like \x00, and backslashes as \\, so strings
+// of arbitrary bytes printed with %s don't garble terminals or make the
+// output invalid UTF-8.
+func printableString(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == '\\' {
+			b.WriteString(`\\`)
+		} else if (r == utf8.RuneError && size == 1) || unicode.IsControl(r) {
+			for _, c := range []byte(s[i : i+size]) {
+				fmt.Fprintf(&b, "\\x%02x", c)
+			}
+		} else {
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
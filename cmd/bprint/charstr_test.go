@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCharArray(t *testing.T) {
+	defer func() { charString, opt.stringTrim = nil, "nul" }()
+	fields, _, _ := parseBinaryFmt("C32S")
+	charString = parseCharArray("0:32", fields)
+
+	in := append([]byte("hello world   "), make([]byte, 18)...)
+	in = append(in, 7, 0)
+	if res := dumpString("C32S", "%q %d", in); res != "\"hello world   \" 7\n" {
+		t.Error("string of byte fields wrong, got", res)
+	}
+	opt.stringTrim = "space"
+	if res := dumpString("C32S", "%s|%d", in); res != "hello world|7\n" {
+		t.Error("space trimmed string wrong, got", res)
+	}
+
+	for _, s := range []string{"0", "a:3", "1:32", "32:1"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("string field", s, "should be rejected")
+				}
+			}()
+			parseCharArray(s, fields)
+		}()
+	}
+}
+
+func TestPrintableString(t *testing.T) {
+	for _, c := range []struct{ in, want string }{
+		{"plain text", "plain text"},
+		{"café ☕", "café ☕"},
+		{"a\x00b\tc\n", `a\x00b\x09c\x0a`},
+		{"bad \xff\xe9 utf-8", `bad \xff\xe9 utf-8`},
+		{"c1 \u0085", `c1 \xc2\x85`},
+		{`a\b`, `a\\b`},
+	} {
+		if res := printableString(c.in); res != c.want {
+			t.Errorf("printable string of %q wrong, got %q", c.in, res)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+// -crc appends a checksum of the bytes of each record as a last field, to
+// check records against a checksum they embed. It's a uint32 printed with
+// %08x by default, counted as a field by the print format.
+
+import (
+	"hash/crc32"
+)
+
+// Checksums of -crc.
+var recordChecksums = map[string]func([]byte) uint32{
+	"crc32": crc32.ChecksumIEEE,
+	"sum8": func(b []byte) uint32 {
+		var sum uint8
+		for _, v := range b {
+			sum += v
+		}
+		return uint32(sum)
+	},
+	"xor": func(b []byte) uint32 {
+		var sum uint8
+		for _, v := range b {
+			sum ^= v
+		}
+		return uint32(sum)
+	},
+}
+
+var recordChecksum func([]byte) uint32
+
+// Index of the checksum field, after the fields printed.
+var checksumField int
+
+// appendChecksum returns data with the checksum of the record bytes raw
+// appended, data itself isn't changed. A record cut short at EOF has no
+// checksum, it would be printed in the place of a missing field.
+func appendChecksum(data []interface{}, raw []byte) []interface{} {
+	if len(data) != checksumField {
+		return data
+	}
+	return append(data[:len(data):len(data)], recordChecksum(raw))
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRecordChecksum(t *testing.T) {
+	defer func() { recordChecksum, checksumField = nil, 0 }()
+	checksumField = 2
+
+	in := []byte{1, 2, 0xff, 0x03, 7}
+	for _, c := range []struct {
+		name, want string
+	}{
+		{"crc32", "1 2 b6cc4292\n255 3 4bf4be37\n7\n"},
+		{"sum8", "1 2 00000003\n255 3 00000002\n7\n"},
+		{"xor", "1 2 00000003\n255 3 000000fc\n7\n"},
+	} {
+		recordChecksum = recordChecksums[c.name]
+		if res := dumpString("CC", "%d %d %08x", in); res != c.want {
+			t.Errorf("%s checksum wrong, got %q", c.name, res)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+// With -color, the fields printed with -p are wrapped in ANSI colors by
+// type: signed integers, unsigned integers, floats, strings and colors each
+// have their own. The escapes are added around the verbs of the print
+// format, so the record is still printed by one Fprintf.
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gastaoss/bprint"
+)
+
+// colorMode is the value of -color, a bare -color is auto.
+type colorMode string
+
+func (m *colorMode) String() string {
+	return string(*m)
+}
+
+func (m *colorMode) Set(s string) error {
+	switch s {
+	case "true":
+		s = "auto"
+	case "false":
+		s = "never"
+	}
+	if s != "auto" && s != "always" && s != "never" {
+		return fmt.Errorf("should be auto, always or never")
+	}
+	*m = colorMode(s)
+	return nil
+}
+
+func (m *colorMode) IsBoolFlag() bool {
+	return true
+}
+
+// joinColorArg returns args with a -color followed by its mode as another
+// argument, like -color always, joined as -color=always. As a bare -color
+// is auto, the flag package would take the mode for the first input file.
+func joinColorArg(args []string) []string {
+	res := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			return append(res, args[i:]...)
+		}
+		if (a == "-color" || a == "--color") && i+1 < len(args) {
+			switch args[i+1] {
+			case "auto", "always", "never":
+				a += "=" + args[i+1]
+				i++
+			}
+		}
+		res = append(res, a)
+	}
+	return res
+}
+
+const colorReset = "\x1b[0m"
+
+// fieldColor returns the ANSI escape starting the color of fields of type t,
+// or "" for types printed without color.
+func fieldColor(t bprint.FieldType) string {
+	switch t {
+	case bprint.I8, bprint.I16, bprint.I24, bprint.I32, bprint.I64, bprint.I128, bprint.SLEB:
+		return "\x1b[32m"
+	case bprint.U8, bprint.U16, bprint.U24, bprint.U32, bprint.U64, bprint.U128, bprint.ULEB:
+		return "\x1b[36m"
+	case bprint.F32, bprint.F64, bprint.F16:
+		return "\x1b[33m"
+	case bprint.STRZ, bprint.STR:
+		return "\x1b[35m"
+	case bprint.RGB, bprint.RGBA, bprint.GUID:
+		return "\x1b[34m"
+	}
+	return ""
+}
+
+// useColor reports whether -color applies, auto colors only when printing
+// to a terminal.
+func useColor(mode colorMode) bool {
+	switch mode {
+	case "always":
+		return true
+	case "auto":
+		return opt.outFile == "" && isTerminal(os.Stdout)
+	}
+	return false
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// colorPrintFmt wraps each print field of printFmt in the color of the
+// type of its binary field.
+func colorPrintFmt(printFmt string, formatField []bprint.FieldType) string {
+	var buf strings.Builder
+	prev := 0
+	for i, v := range findPrintFields(printFmt) {
+		buf.WriteString(printFmt[prev:v[0]])
+		if i < len(formatField) && fieldColor(formatField[i]) != "" {
+			buf.WriteString(fieldColor(formatField[i]) + printFmt[v[0]:v[1]] + colorReset)
+		} else {
+			buf.WriteString(printFmt[v[0]:v[1]])
+		}
+		prev = v[1]
+	}
+	buf.WriteString(printFmt[prev:])
+	return buf.String()
+}
+
+// colorWidth returns the number of characters of s shown on a terminal,
+// without the color escapes, and the index in s after the first width of
+// them.
+func colorWidth(s string, width int) (n, end int) {
+	end = len(s)
+	for i := 0; i < len(s); {
+		if s[i] == '\x1b' {
+			if j := strings.IndexByte(s[i:], 'm'); j >= 0 {
+				i += j + 1
+				continue
+			}
+		}
+		if n == width {
+			end = i
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		n++
+	}
+	return
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestColorPrintFmt(t *testing.T) {
+	formatField, _, _ := parseBinaryFmt("cLfz")
+	res := colorPrintFmt("%d %x, %g%% %s!", formatField)
+	want := "\x1b[32m%d\x1b[0m \x1b[36m%x\x1b[0m, \x1b[33m%g\x1b[0m%% \x1b[35m%s\x1b[0m!"
+	if res != want {
+		t.Errorf("print format not colored by type, got %q", res)
+	}
+}
+
+func TestColorMode(t *testing.T) {
+	var m colorMode
+	for _, td := range []struct {
+		arg, mode string
+	}{
+		{"true", "auto"}, {"always", "always"}, {"false", "never"},
+	} {
+		if err := m.Set(td.arg); err != nil || string(m) != td.mode {
+			t.Error("-color", td.arg, "should be", td.mode, "got", m, err)
+		}
+	}
+	if m.Set("yes") == nil {
+		t.Error("-color yes should be rejected")
+	}
+	if useColor("never") || !useColor("always") {
+		t.Error("never and always should decide color")
+	}
+}
+
+func TestJoinColorArg(t *testing.T) {
+	res := joinColorArg([]string{"-e", "C", "-color", "always", "-color", "in.bin", "--", "-color", "never"})
+	want := []string{"-e", "C", "-color=always", "-color", "in.bin", "--", "-color", "never"}
+	if !reflect.DeepEqual(res, want) {
+		t.Error("-color mode should be joined, got", res)
+	}
+}
+
+func TestColorLinePad(t *testing.T) {
+	l := "\x1b[36m1\x1b[0m \x1b[32m2\x1b[0m"
+	if res := padLines(l, 5); res != l+"  " {
+		t.Errorf("color escapes should not be padded, got %q", res)
+	}
+	if res := padLines(l, 1); res != "\x1b[36m1\x1b[0m"+colorReset {
+		t.Errorf("truncated colored line wrong, got %q", res)
+	}
+}
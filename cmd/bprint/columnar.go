@@ -0,0 +1,41 @@
+package main
+
+// With -columnar, the formatted values are collected per field and printed
+// at EOF as one line per field with the values of all records, for tools
+// working on columns.
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	columnNames []string
+	columns     [][]string
+)
+
+// addColumnValues appends the formatted values of a record to columns.
+func addColumnValues(data []interface{}) {
+	names, values := recordCells(data)
+	for len(columns) < len(values) {
+		columns = append(columns, nil)
+	}
+	if len(names) > len(columnNames) {
+		columnNames = names
+	}
+	size := 0
+	for i, v := range values {
+		columns[i] = append(columns[i], v)
+		size += len(v) + 16
+	}
+	retainMem(size, "-columnar")
+}
+
+// printColumns prints each column as its name followed by its values to w.
+func printColumns(w io.Writer) {
+	for i, col := range columns {
+		fmt.Fprintf(w, "%s: %s\n", columnNames[i], strings.Join(col, " "))
+	}
+	columnNames, columns = nil, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestColumnar(t *testing.T) {
+	defer func() { opt.columnar, opt.printOffset = false, false }()
+	opt.columnar, opt.printOffset = true, true
+
+	res := dumpString("CS", "%d %04x", []byte{1, 0xcd, 0xab, 2, 0x34, 0x12, 3, 0, 0})
+	want := "offset: 0000000 0000003 0000006\n" +
+		"f0: 1 2 3\n" +
+		"f1: abcd 1234 0000\n"
+	if res != want {
+		t.Error("columnar output wrong, got\n" + res)
+	}
+}
+
+func TestColumnarMaxMem(t *testing.T) {
+	defer func() { opt.columnar, maxMem, memUsed, columnNames, columns = false, 0, 0, nil, nil }()
+	opt.columnar, maxMem = true, 64
+
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("-columnar beyond -max-mem should abort")
+		}
+	}()
+	dumpString("C", "%d", make([]byte, 10))
+}
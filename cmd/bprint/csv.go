@@ -0,0 +1,52 @@
+package main
+
+// With -csv, records are printed as comma separated values with the quoting
+// of encoding/csv, for spreadsheets and data frames. Values are printed as
+// decoded, integers in decimal, whatever the print format.
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// CSV writer of output, made again when output changes.
+var csvOut struct {
+	w   *csv.Writer
+	out io.Writer
+}
+
+func printCSVLine(cells []string) {
+	if csvOut.w == nil || csvOut.out != output {
+		csvOut.w, csvOut.out = csv.NewWriter(output), output
+	}
+	csvOut.w.Write(cells)
+	// output is buffered already, keep lines in order with other output
+	csvOut.w.Flush()
+}
+
+// printCSVHeader prints the names of the offset, count and fieldCnt fields.
+func printCSVHeader(fieldCnt int) {
+	printCSVLine(cellNames(fieldCnt))
+}
+
+func printCSV(data []interface{}) {
+	var cells []string
+	if opt.printOffset {
+		cells = append(cells, strconv.Itoa(offSet))
+	}
+	if opt.printRecordCnt {
+		cells = append(cells, strconv.Itoa(recordCnt))
+	}
+	if opt.offsetDelta {
+		cells = append(cells, strconv.Itoa(recordBytes))
+	}
+	if recordHasher != nil {
+		cells = append(cells, fmt.Sprintf("%08x", recordHash))
+	}
+	for _, v := range data {
+		cells = append(cells, fmt.Sprint(v))
+	}
+	printCSVLine(cells)
+}
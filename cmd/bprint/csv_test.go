@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCSV(t *testing.T) {
+	defer func() { opt.csv, opt.header, opt.printOffset = false, false, false }()
+	opt.csv, opt.header, opt.printOffset = true, true, true
+
+	// Integers are decimal whatever the print format
+	in := []byte{0xff, 'a', ',', 'b', 0, 0x80, 'c', '"', 0}
+	res := dumpString("cz", "%02x %s", in)
+	want := "offset,f0,f1\n" +
+		"0,-1,\"a,b\"\n" +
+		"5,-128,\"c\"\"\"\n"
+	if res != want {
+		t.Errorf("CSV output wrong, got %q", res)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestDecodeString(t *testing.T) {
+	defer func() { stringEncoding, opt.stringTrim = nil, "nul" }()
+	for _, c := range []struct {
+		enc, trim, in, want string
+	}{
+		{"ebcdic", "space", "\xc8\x85\x93\x93\x96\x40\xf1\xf2\x40\x40", "Hello 12"},
+		{"ebcdic", "nul", "\xc1\x4b\x05\x00\x00", "A.."},
+		{"ascii", "nul", "ok\x01\xe9\x00", "ok.."},
+		{"latin1", "nul", "caf\xe9\x9f\x00", "café."},
+	} {
+		stringEncoding, opt.stringTrim = stringEncodings[c.enc], c.trim
+		if res := decodeString(c.in); res != c.want {
+			t.Errorf("%s string %q decoded as %q, should be %q", c.enc, c.in, res, c.want)
+		}
+	}
+	stringEncoding = nil
+	if res := decodeString("a\xe9\x00"); res != "a\xe9" {
+		t.Errorf("string without -enc should be kept, got %q", res)
+	}
+}
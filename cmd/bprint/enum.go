@@ -0,0 +1,116 @@
+package main
+
+// Labels for the values of enum fields are loaded from CSV files given with
+// -enum-file, one value,label pair per line, or given inline with -enum like
+// "2=0:OK,1:WARN", and printed instead of the values. Values can be decimal
+// or 0x hex.
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// stringList is a flag which can be repeated, collecting all values.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// Labels of field values indexed by field, then by the decimal value.
+var enumLabels = map[int]map[string]string{}
+
+// loadEnumFile loads the labels for a field given like "2:labels.csv".
+func loadEnumFile(s string, fieldCnt int) {
+	idx := strings.Index(s, ":")
+	if idx <= 0 {
+		panic(specError{fmt.Errorf("Invalid enum file '%s', should be like 2:labels.csv", s)})
+	}
+	field := enumField(s[:idx], fieldCnt)
+	f, err := os.Open(s[idx+1:])
+	if err != nil {
+		panic(fmt.Sprintf("While opening enum file: %v", err))
+	}
+	defer f.Close()
+	enumLabels[field] = readEnumCSV(f, s[idx+1:])
+}
+
+// parseEnum sets the labels for a field given like "2=0:OK,1:WARN,2:FAIL".
+func parseEnum(s string, fieldCnt int) {
+	idx := strings.Index(s, "=")
+	if idx <= 0 {
+		panic(specError{fmt.Errorf("Invalid enum '%s', should be like 2=0:OK,1:WARN", s)})
+	}
+	field := enumField(s[:idx], fieldCnt)
+	labels := map[string]string{}
+	for _, v := range strings.Split(s[idx+1:], ",") {
+		value, label, ok := strings.Cut(v, ":")
+		n, isNum := new(big.Int).SetString(strings.TrimSpace(value), 0)
+		if !ok || !isNum {
+			panic(specError{fmt.Errorf("Invalid enum label '%s', should be like 0:OK", v)})
+		}
+		labels[n.String()] = label
+	}
+	enumLabels[field] = labels
+}
+
+// enumField returns the field index s of -enum or -enum-file.
+func enumField(s string, fieldCnt int) int {
+	field, err := strconv.Atoi(s)
+	if err != nil {
+		panic(specError{fmt.Errorf("Invalid enum field index '%s'", s)})
+	}
+	if field < 0 || field >= fieldCnt {
+		panic(specError{fmt.Errorf("Enum field %d out of range, record has %d fields", field, fieldCnt)})
+	}
+	return field
+}
+
+// readEnumCSV reads value,label lines from r. A first line without a
+// number value is taken as a header and skipped.
+func readEnumCSV(r io.Reader, name string) map[string]string {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 2
+	cr.TrimLeadingSpace = true
+	labels := map[string]string{}
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return labels
+		}
+		if err != nil {
+			panic(specError{fmt.Errorf("Enum file %s error: %v", name, err)})
+		}
+		v, ok := new(big.Int).SetString(strings.TrimSpace(rec[0]), 0)
+		if !ok {
+			if line == 1 {
+				continue
+			}
+			panic(specError{fmt.Errorf("Enum file %s error: line %d value '%s' is not a number", name, line, rec[0])})
+		}
+		labels[v.String()] = rec[1]
+	}
+}
+
+// labelConv returns a conversion to the label of a value, values without a
+// label are formatted with spec.
+func labelConv(labels map[string]string, spec string) func(v interface{}) interface{} {
+	return func(v interface{}) interface{} {
+		if isNumber(v) {
+			if label, ok := labels[toBigInt(v).String()]; ok {
+				return label
+			}
+		}
+		return fmt.Sprintf(spec, v)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnumFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "labels.csv")
+	csv := "value,label\n0,off\n1,on\n0xff,\"error, fatal\"\n"
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { enumLabels = map[int]map[string]string{} }()
+	loadEnumFile("1:"+path, 2)
+
+	res := dumpString("CC", "%d %02x", []byte{1, 0, 1, 1, 1, 0xff, 1, 7})
+	if res != "1 off\n1 on\n1 error, fatal\n1 07\n" {
+		t.Error("enum labels wrong, got", res)
+	}
+
+	for _, s := range []string{"labels.csv", "x:" + path, "2:" + path} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("enum file", s, "should be rejected")
+				}
+			}()
+			loadEnumFile(s, 2)
+		}()
+	}
+}
+
+func TestEnum(t *testing.T) {
+	defer func() { enumLabels = map[int]map[string]string{} }()
+	parseEnum("0=0:OK,1:WARN,0x2:FAIL", 2)
+
+	res := dumpString("CC", "%d %d", []byte{0, 5, 2, 6, 9, 7})
+	if res != "OK 5\nFAIL 6\n9 7\n" {
+		t.Error("inline enum labels wrong, got", res)
+	}
+
+	for _, s := range []string{"0:OK", "x=0:OK", "2=0:OK", "0=OK", "0=a:OK", "0=0:OK,"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("enum", s, "should be rejected")
+				}
+			}()
+			parseEnum(s, 2)
+		}()
+	}
+}
+
+func TestReadEnumCSVError(t *testing.T) {
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("enum file line without number value should be rejected")
+		}
+	}()
+	readEnumCSV(strings.NewReader("1,on\nx,off\n"), "test.csv")
+}
@@ -0,0 +1,280 @@
+package main
+
+// A small expression language evaluated against the fields of a record.
+//
+// Fields are referred to as f0, f1, ... in the order they appear in the
+// binary format. Integer literals can be decimal, 0x hex or 0 octal.
+// Operators and their precedence are the same as in Go:
+//
+//	* / % << >> &
+//	+ - | ^
+//	== != < <= > >=
+//	&&
+//	||
+//
+// Unary -, ! and ^ are also supported. Comparison and logical operators
+// evaluate to 1 or 0, any non zero value is true. Only integer fields can be
+// used, not floats, colors or strings.
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/gastaoss/bprint"
+)
+
+// expr evaluates to a value from the fields of one record. Integers are
+// evaluated using big.Int so that signed and unsigned 64-bit fields compare
+// correctly.
+type expr func(data []interface{}) *big.Int
+
+var (
+	bigZero = big.NewInt(0)
+	bigOne  = big.NewInt(1)
+)
+
+func bigBool(b bool) *big.Int {
+	if b {
+		return bigOne
+	}
+	return bigZero
+}
+
+func isTrue(v *big.Int) bool {
+	return v.Sign() != 0
+}
+
+func toBigInt(v interface{}) *big.Int {
+	switch v := v.(type) {
+	case int8:
+		return big.NewInt(int64(v))
+	case int16:
+		return big.NewInt(int64(v))
+	case int32:
+		return big.NewInt(int64(v))
+	case int64:
+		return big.NewInt(v)
+	case uint8:
+		return new(big.Int).SetUint64(uint64(v))
+	case uint16:
+		return new(big.Int).SetUint64(uint64(v))
+	case uint32:
+		return new(big.Int).SetUint64(uint64(v))
+	case uint64:
+		return new(big.Int).SetUint64(v)
+	case *big.Int:
+		return v
+	}
+	panic(fmt.Sprintf("Value %v of type %T can't be used in expression", v, v))
+}
+
+type exprParser struct {
+	src    string
+	pos    int
+	fields []bprint.FieldType
+}
+
+// Binary operators grouped by precedence, lowest first.
+var exprBinaryOps = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<=", ">=", "<", ">"},
+	{"+", "-", "|", "^"},
+	{"*", "/", "%", "<<", ">>", "&"},
+}
+
+// parseExpr compiles src into an expr. fields are the types of the fields in
+// a record, referring to a field beyond them or to a field which isn't an
+// integer is an error.
+func parseExpr(src string, fields []bprint.FieldType) expr {
+	p := &exprParser{src: src, fields: fields}
+	e := p.parseBinary(0)
+	p.skipSpace()
+	if p.pos != len(p.src) {
+		p.error("unexpected '%s'", p.src[p.pos:])
+	}
+	return e
+}
+
+func (p *exprParser) error(format string, a ...interface{}) {
+	panic(specError{fmt.Errorf("Expression error in \"%s\" at %d: %s", p.src, p.pos,
+		fmt.Sprintf(format, a...))})
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// matchOp consumes and returns one of ops at the current position.
+func (p *exprParser) matchOp(ops []string) string {
+	p.skipSpace()
+	rest := p.src[p.pos:]
+	for _, op := range ops {
+		if !strings.HasPrefix(rest, op) {
+			continue
+		}
+		// Do not take the prefix of a longer operator, e.g. < in <<, & in &&
+		if len(op) == 1 && len(rest) > 1 &&
+			strings.Contains("<>&|=", rest[1:2]) && strings.Contains("<>&|", op) {
+			continue
+		}
+		p.pos += len(op)
+		return op
+	}
+	return ""
+}
+
+func (p *exprParser) parseBinary(level int) expr {
+	if level == len(exprBinaryOps) {
+		return p.parseUnary()
+	}
+	lhs := p.parseBinary(level + 1)
+	for {
+		op := p.matchOp(exprBinaryOps[level])
+		if op == "" {
+			return lhs
+		}
+		rhs := p.parseBinary(level + 1)
+		lhs = binaryExpr(op, lhs, rhs)
+	}
+}
+
+func binaryExpr(op string, lhs, rhs expr) expr {
+	switch op {
+	case "||":
+		return func(d []interface{}) *big.Int { return bigBool(isTrue(lhs(d)) || isTrue(rhs(d))) }
+	case "&&":
+		return func(d []interface{}) *big.Int { return bigBool(isTrue(lhs(d)) && isTrue(rhs(d))) }
+	case "==", "!=", "<=", ">=", "<", ">":
+		return func(d []interface{}) *big.Int {
+			c := lhs(d).Cmp(rhs(d))
+			switch op {
+			case "==":
+				return bigBool(c == 0)
+			case "!=":
+				return bigBool(c != 0)
+			case "<=":
+				return bigBool(c <= 0)
+			case ">=":
+				return bigBool(c >= 0)
+			case "<":
+				return bigBool(c < 0)
+			}
+			return bigBool(c > 0)
+		}
+	}
+	return func(d []interface{}) *big.Int {
+		x, y := lhs(d), rhs(d)
+		z := new(big.Int)
+		switch op {
+		case "+":
+			return z.Add(x, y)
+		case "-":
+			return z.Sub(x, y)
+		case "*":
+			return z.Mul(x, y)
+		case "/", "%":
+			if y.Sign() == 0 {
+				panic("Expression error: division by zero")
+			}
+			if op == "/" {
+				return z.Quo(x, y)
+			}
+			return z.Rem(x, y)
+		case "|":
+			return z.Or(x, y)
+		case "^":
+			return z.Xor(x, y)
+		case "&":
+			return z.And(x, y)
+		case "<<":
+			return z.Lsh(x, uint(y.Uint64()))
+		}
+		return z.Rsh(x, uint(y.Uint64()))
+	}
+}
+
+func (p *exprParser) parseUnary() expr {
+	op := p.matchOp([]string{"-", "!", "^"})
+	if op == "" {
+		return p.parsePrimary()
+	}
+	e := p.parseUnary()
+	switch op {
+	case "-":
+		return func(d []interface{}) *big.Int { return new(big.Int).Neg(e(d)) }
+	case "!":
+		return func(d []interface{}) *big.Int { return bigBool(!isTrue(e(d))) }
+	}
+	return func(d []interface{}) *big.Int { return new(big.Int).Not(e(d)) }
+}
+
+// exprType reports whether fields of type t can be used in expressions,
+// their values are integers.
+func exprType(t bprint.FieldType) bool {
+	switch t {
+	case bprint.I128, bprint.U128, bprint.ULEB, bprint.SLEB:
+		return true
+	}
+	return t.IsInt()
+}
+
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
+
+func isWordChar(b byte) bool {
+	return isDigit(b) || b == '_' || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
+
+func (p *exprParser) parsePrimary() expr {
+	p.skipSpace()
+	if p.pos == len(p.src) {
+		p.error("unexpected end of expression")
+	}
+	if p.src[p.pos] == '(' {
+		p.pos++
+		e := p.parseBinary(0)
+		if p.matchOp([]string{")"}) == "" {
+			p.error("missing ')'")
+		}
+		return e
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && isWordChar(p.src[p.pos]) {
+		p.pos++
+	}
+	word := p.src[start:p.pos]
+	if word == "" {
+		p.error("unexpected '%c'", p.src[p.pos])
+	}
+	if isDigit(word[0]) {
+		v, ok := new(big.Int).SetString(word, 0)
+		if !ok {
+			p.pos = start
+			p.error("invalid number '%s'", word)
+		}
+		return func([]interface{}) *big.Int { return v }
+	}
+	if word[0] == 'f' {
+		if idx, err := strconv.Atoi(word[1:]); err == nil {
+			if idx >= len(p.fields) {
+				p.pos = start
+				p.error("field %s out of range, record has %d fields", word, len(p.fields))
+			}
+			if t := p.fields[idx]; !exprType(t) {
+				p.pos = start
+				p.error("field %s is %s, only integer fields can be used", word, t.String())
+			}
+			return func(d []interface{}) *big.Int { return toBigInt(d[idx]) }
+		}
+	}
+	p.pos = start
+	p.error("unknown name '%s'", word)
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/gastaoss/bprint"
+)
+
+func TestExpr(t *testing.T) {
+	data := []interface{}{int8(-1), uint64(18446744073709551615), uint16(0x1234), new(big.Int).Lsh(big.NewInt(1), 100)}
+	fields := []bprint.FieldType{bprint.I8, bprint.U64, bprint.U16, bprint.U128}
+	testData := []struct {
+		src string
+		res int64
+	}{
+		{"f0", -1},
+		{"f0 < f1", 1},
+		{"f1 > 0xffffffff && f0 == -1", 1},
+		{"f2 & 0xff == 0x34", 1},
+		{"(f2 >> 8) + 1", 0x13},
+		{"1 + 2 * 3", 7},
+		{"!(f0 < 0) || f2 != 0x1234", 0},
+		{"-f0 % 2", 1},
+		{"f2 <= 4660 && f2 >= 4660", 1},
+		{"f3 == 1 << 100", 1},
+	}
+
+	for _, td := range testData {
+		res := parseExpr(td.src, fields)(data)
+		if res.Int64() != td.res {
+			t.Error("expression", td.src, "should be", td.res, "got", res)
+		}
+	}
+}
+
+func TestExprError(t *testing.T) {
+	// Float, color and string fields can't be compared to integers
+	fields := []bprint.FieldType{bprint.U8, bprint.RGB, bprint.F32, bprint.STRZ}
+	for _, src := range []string{"f4 > 1", "f0 >", "(f0", "g0", "f0 = 1", "0x", "f2 > 1", "f1 == 0", "f0 + f3"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("expression", src, "should be rejected")
+				}
+			}()
+			parseExpr(src, fields)
+		}()
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+// With -fields, only the listed fields are printed. All fields are still
+// decoded, so offsets and the record size don't change, and -filter, -until
+// and the other checks of records still index all fields. Options about
+// printed fields, like -unit and -enum-file, index the selected fields.
+
+import (
+	"fmt"
+
+	"github.com/gastaoss/bprint"
+)
+
+// Indices of the fields printed with -fields, nil for all fields.
+var selectedFields []int
+
+// selectValues returns the values of the selected fields, those missing in
+// a partial record are left out.
+func selectValues(data []interface{}) []interface{} {
+	if selectedFields == nil {
+		return data
+	}
+	res := make([]interface{}, 0, len(selectedFields))
+	for _, i := range selectedFields {
+		if i < len(data) {
+			res = append(res, data[i])
+		}
+	}
+	return res
+}
+
+// selectTypes returns the types of the selected fields.
+func selectTypes(types []bprint.FieldType) []bprint.FieldType {
+	res := make([]bprint.FieldType, len(selectedFields))
+	for j, i := range selectedFields {
+		res[j] = types[i]
+	}
+	return res
+}
+
+// selectNames returns the names of the selected fields, a field without a
+// name keeps the name of its index in the record like f3.
+func selectNames(names []string) []string {
+	res := make([]string, len(selectedFields))
+	for j, i := range selectedFields {
+		if i < len(names) && names[i] != "" {
+			res[j] = names[i]
+		} else {
+			res[j] = fmt.Sprintf("f%d", i)
+		}
+	}
+	return res
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSelectedFields(t *testing.T) {
+	defer func() { selectedFields, opt.printOffset = nil, false }()
+	formatField, _, _ := parseBinaryFmt("CSCL")
+	selectedFields = parseFieldList("3,0-1", len(formatField))
+	if types := selectTypes(formatField); len(types) != 3 || types[0].String() != "uint32" {
+		t.Error("selected types wrong, got", types)
+	}
+	if names := selectNames([]string{"a", "b"}); len(names) != 3 || names[0] != "f3" || names[2] != "b" {
+		t.Error("selected names wrong, got", names)
+	}
+
+	opt.printOffset = true
+	in := []byte{1, 2, 0, 3, 4, 0, 0, 0, 5, 6, 0, 7, 8, 0, 0, 0}
+	if res := dumpString("CSCL", "%d %d %d", in); res != "0000000 4 1 2\n0000008 8 5 6\n0000010 \n" {
+		t.Errorf("only selected fields should be printed, got %q", res)
+	}
+}
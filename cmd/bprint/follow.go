@@ -0,0 +1,55 @@
+package main
+
+// -F follows a file being appended to like tail -f: at the end of the file,
+// bprint waits for more data instead of exiting, until interrupted.
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// Wait before reading again at the end of a followed file.
+var followDelay = 200 * time.Millisecond
+
+// followReader reads r, waiting for more data at EOF instead of returning
+// it. It returns io.EOF once stop receives, so the records read are still
+// printed on Ctrl-C. The output is flushed before waiting.
+type followReader struct {
+	r    io.Reader
+	stop <-chan os.Signal
+}
+
+func (fr *followReader) Read(p []byte) (int, error) {
+	for {
+		select {
+		case <-fr.stop:
+			return 0, io.EOF
+		default:
+		}
+		n, err := fr.r.Read(p)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+		flushOutput()
+		select {
+		case <-fr.stop:
+			return 0, io.EOF
+		case <-time.After(followDelay):
+		}
+	}
+}
+
+// Interrupt signal ending -F, set by main.
+var followStop chan os.Signal
+
+// followFile returns r reading f to follow it with -F, it's only followed
+// if it's a regular file.
+func followFile(r io.Reader, f io.ReadCloser) io.Reader {
+	if file, ok := f.(*os.File); ok {
+		if fi, err := file.Stat(); err == nil && fi.Mode().IsRegular() {
+			return &followReader{r: r, stop: followStop}
+		}
+	}
+	return r
+}
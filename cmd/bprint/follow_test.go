@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+// growingReader returns EOF until more data is appended.
+type growingReader struct {
+	chunks [][]byte
+	eofs   int
+}
+
+func (gr *growingReader) Read(p []byte) (int, error) {
+	if len(gr.chunks) == 0 || gr.eofs > 0 {
+		gr.eofs--
+		return 0, io.EOF
+	}
+	n := copy(p, gr.chunks[0])
+	gr.chunks = gr.chunks[1:]
+	gr.eofs = 2
+	return n, nil
+}
+
+func TestFollowReader(t *testing.T) {
+	defer func(d time.Duration) { followDelay = d }(followDelay)
+	followDelay = time.Millisecond
+	stop := make(chan os.Signal, 1)
+	gr := &growingReader{chunks: [][]byte{{1, 0}, {2}, {0, 3, 0}}}
+	fr := &followReader{r: gr, stop: stop}
+
+	// Bytes past the current end are read when more data arrives
+	b := make([]byte, 4)
+	if n, err := io.ReadFull(fr, b); n != 4 || err != nil {
+		t.Error("follow reader should wait for more data, got", n, err)
+	}
+	stop <- os.Interrupt
+	if n, err := fr.Read(b); n != 0 || err != io.EOF {
+		t.Error("follow reader should end when stopped, got", n, err)
+	}
+}
+
+func TestFollowFile(t *testing.T) {
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer f.Close()
+	if _, ok := followFile(f, f).(*followReader); ok {
+		t.Error("only regular files should be followed")
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+// -x reads the input as hex text like "deadbeef0102", as copied from a
+// debugger, and decodes it into bytes as it's read. Whitespace between the
+// digits is ignored, so hex dumps split in lines or byte groups work too.
+// -decode-dump reads the lines printed by bprint -o back instead, like
+// "0000010 de ad be ef", so a dump edited as text can be decoded again.
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// hexReader decodes the hex text read from r. The error for a character
+// which is not a hex digit or an odd number of digits gives its offset in
+// the text.
+type hexReader struct {
+	r   *bufio.Reader
+	off int64
+}
+
+func newHexReader(r io.Reader) *hexReader {
+	return &hexReader{r: bufio.NewReader(r)}
+}
+
+func (h *hexReader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		var hi, lo byte
+		if hi, err = h.digit(); err != nil {
+			// io.EOF between bytes is the end of the input
+			return
+		}
+		if lo, err = h.digit(); err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("odd number of hex digits in input")
+			}
+			return
+		}
+		p[n] = hi<<4 | lo
+		n++
+		if h.r.Buffered() == 0 {
+			// Return the bytes decoded, instead of waiting for more input
+			// from a pipe
+			return
+		}
+	}
+	return
+}
+
+// digit returns the value of the next hex digit, skipping whitespace.
+func (h *hexReader) digit() (byte, error) {
+	for {
+		c, err := h.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		h.off++
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			continue
+		case '0' <= c && c <= '9':
+			return c - '0', nil
+		case 'a' <= c && c <= 'f':
+			return c - 'a' + 10, nil
+		case 'A' <= c && c <= 'F':
+			return c - 'A' + 10, nil
+		}
+		return 0, fmt.Errorf("invalid hex character %q at input offset %d", c, h.off-1)
+	}
+}
+
+// dumpReader decodes the bytes of dump lines read from r, each an offset
+// followed by hex bytes. A record count like "1:" after the offset and the
+// -a column like "|ab..|" are skipped too. The offsets must increase, so
+// lines without one are an error instead of losing their first byte.
+type dumpReader struct {
+	r    *bufio.Reader
+	line int
+	buf  []byte
+	// Offset of the previous line, -1 before the first line
+	prev int64
+}
+
+func newDumpReader(r io.Reader) *dumpReader {
+	return &dumpReader{r: bufio.NewReader(r), prev: -1}
+}
+
+func (d *dumpReader) Read(p []byte) (n int, err error) {
+	for len(d.buf) == 0 {
+		line, err := d.r.ReadString('\n')
+		if line == "" {
+			return 0, err
+		}
+		d.line++
+		var off int64
+		if off, d.buf, err = dumpLineBytes(line); err != nil {
+			return 0, fmt.Errorf("dump line %d: %v", d.line, err)
+		}
+		if off < 0 {
+			continue
+		}
+		if off <= d.prev {
+			return 0, fmt.Errorf("dump line %d: offset %x is not after the offset %x of the previous line, it should start the line",
+				d.line, off, d.prev)
+		}
+		d.prev = off
+	}
+	n = copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return
+}
+
+// dumpLineBytes returns the offset and the bytes of a dump line, an empty
+// line has none and an offset of -1.
+func dumpLineBytes(line string) (off int64, res []byte, err error) {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return -1, nil, nil
+	}
+	if off, err = strconv.ParseInt(words[0], 16, 64); err != nil || off < 0 {
+		return 0, nil, fmt.Errorf("offset '%s' is not hex", words[0])
+	}
+	words = words[1:]
+	if len(words) > 0 && strings.HasSuffix(words[0], ":") {
+		words = words[1:]
+	}
+	for _, w := range words {
+		if strings.HasPrefix(w, "|") {
+			break
+		}
+		b, err := hex.DecodeString(w)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid hex bytes '%s'", w)
+		}
+		res = append(res, b...)
+	}
+	return off, res, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestHexReader(t *testing.T) {
+	b, err := io.ReadAll(newHexReader(strings.NewReader("dead BEEF\n01 02\n")))
+	if err != nil || string(b) != "\xde\xad\xbe\xef\x01\x02" {
+		t.Errorf("hex input decoded wrong, got %x %v", b, err)
+	}
+
+	b, err = io.ReadAll(newHexReader(strings.NewReader("0102 0")))
+	if err == nil || string(b) != "\x01\x02" {
+		t.Error("odd number of hex digits should be an error after the bytes decoded, got", b, err)
+	}
+	_, err = io.ReadAll(newHexReader(strings.NewReader("01zz")))
+	if err == nil || err.Error() != `invalid hex character 'z' at input offset 2` {
+		t.Error("non-hex character error wrong, got", err)
+	}
+}
+
+func TestDumpReader(t *testing.T) {
+	dump := "0000000 1: de ad be ef  |....|\n\n0000004 0102\n0000006 \n"
+	b, err := io.ReadAll(newDumpReader(strings.NewReader(dump)))
+	if err != nil || string(b) != "\xde\xad\xbe\xef\x01\x02" {
+		t.Errorf("dump input decoded wrong, got %x %v", b, err)
+	}
+
+	for _, c := range []struct {
+		dump string
+		err  string
+	}{
+		{"0000000 01\n0000001 0g\n", "dump line 2: invalid hex bytes '0g'"},
+		{"0000000 01 2\n", "dump line 1: invalid hex bytes '2'"},
+		{"offset 01\n", "dump line 1: offset 'offset' is not hex"},
+		{"de ad\n01 02\n", "dump line 2: offset 1 is not after the offset de of the previous line, it should start the line"},
+		{"0000004 01\n0000004 02\n", "dump line 2: offset 4 is not after the offset 4 of the previous line, it should start the line"},
+	} {
+		if _, err := io.ReadAll(newDumpReader(strings.NewReader(c.dump))); err == nil || err.Error() != c.err {
+			t.Errorf("dump %q should fail with %s, got %v", c.dump, c.err, err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+// With -hist-buckets, records are not printed. Instead the values of one
+// numeric field are counted into equal width buckets over a range, and the
+// histogram is printed at the end. This suits fields with too many distinct
+// values to count each one.
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+type bucketHist struct {
+	field    int
+	min, max *big.Rat
+	counts   []int64
+	// Values below min and above max
+	under, over int64
+}
+
+var histogram *bucketHist
+
+// parseBucketHist parses a histogram spec like "0:0,100,10" for 10 buckets
+// of field 0 values from 0 to 100.
+func parseBucketHist(s string, fieldCnt int) *bucketHist {
+	invalid := func() {
+		panic(specError{fmt.Errorf("Invalid histogram '%s', should be like 0:0,100,10 for field:min,max,buckets", s)})
+	}
+	idx := strings.Index(s, ":")
+	if idx <= 0 {
+		invalid()
+	}
+	field, err := strconv.Atoi(s[:idx])
+	if err != nil {
+		invalid()
+	}
+	if field < 0 || field >= fieldCnt {
+		panic(specError{fmt.Errorf("Histogram field %d out of range, record has %d fields", field, fieldCnt)})
+	}
+	args := strings.Split(s[idx+1:], ",")
+	if len(args) != 3 {
+		invalid()
+	}
+	min, ok1 := parseBound(args[0])
+	max, ok2 := parseBound(args[1])
+	cnt, err := strconv.Atoi(args[2])
+	if !ok1 || !ok2 || err != nil || cnt <= 0 {
+		invalid()
+	}
+	if min.Cmp(max) >= 0 {
+		panic(specError{fmt.Errorf("Histogram min %s should be less than max %s", ratString(min), ratString(max))})
+	}
+	return &bucketHist{field: field, min: min, max: max, counts: make([]int64, cnt)}
+}
+
+// add counts the value of the histogram field in data. A float NaN isn't
+// counted, an infinity is below min or above max.
+func (h *bucketHist) add(data []interface{}) {
+	if h.field >= len(data) {
+		return
+	}
+	v := toBigRat(data[h.field])
+	if v == nil {
+		switch data[h.field].(type) {
+		case float32, float64:
+			if f := toFloat64(data[h.field]); math.IsInf(f, -1) {
+				h.under++
+			} else if math.IsInf(f, 1) {
+				h.over++
+			}
+		}
+		return
+	}
+	switch {
+	case v.Cmp(h.min) < 0:
+		h.under++
+	case v.Cmp(h.max) > 0:
+		h.over++
+	case v.Cmp(h.max) == 0:
+		// The last bucket includes max
+		h.counts[len(h.counts)-1]++
+	default:
+		// (v - min) * buckets / (max - min), rounded down
+		i := new(big.Rat).Sub(v, h.min)
+		i.Mul(i, new(big.Rat).SetInt64(int64(len(h.counts))))
+		i.Quo(i, new(big.Rat).Sub(h.max, h.min))
+		h.counts[new(big.Int).Quo(i.Num(), i.Denom()).Int64()]++
+	}
+}
+
+// bound returns the lower bound of bucket i.
+func (h *bucketHist) bound(i int) string {
+	r := new(big.Rat).Sub(h.max, h.min)
+	r.Mul(r, big.NewRat(int64(i), int64(len(h.counts))))
+	return ratString(r.Add(r, h.min))
+}
+
+// print prints the histogram as a table of bucket ranges and counts to w.
+// The buckets are [lower, upper) except the last one including max.
+func (h *bucketHist) print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tcount\n", fieldName(h.field))
+	if h.under != 0 {
+		fmt.Fprintf(tw, "<%s\t%d\n", ratString(h.min), h.under)
+	}
+	for i, cnt := range h.counts {
+		end := ")"
+		if i == len(h.counts)-1 {
+			end = "]"
+		}
+		fmt.Fprintf(tw, "[%s,%s%s\t%d\n", h.bound(i), h.bound(i+1), end, cnt)
+	}
+	if h.over != 0 {
+		fmt.Fprintf(tw, ">%s\t%d\n", ratString(h.max), h.over)
+	}
+	tw.Flush()
+}
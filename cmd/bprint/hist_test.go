@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestBucketHist(t *testing.T) {
+	defer func() { histogram = nil }()
+	histogram = parseBucketHist("1:0,100,4", 2)
+
+	in := []byte{}
+	for _, v := range []byte{0, 24, 25, 60, 99, 100, 101, 200} {
+		in = append(in, 0, v)
+	}
+	res := dumpString("CC", "%d %d", in)
+	want := "f1        count\n" +
+		"[0,25)    2\n" +
+		"[25,50)   1\n" +
+		"[50,75)   1\n" +
+		"[75,100]  2\n" +
+		">100      2\n"
+	if res != want {
+		t.Error("histogram wrong, got\n" + res)
+	}
+
+	// Float values are counted, a NaN isn't
+	histogram = parseBucketHist("0:-0.5,0.5,2", 1)
+	in = []byte{}
+	for _, v := range []uint32{0xbe800000, 0x3e800000, 0x3f000000, 0x7fc00000, 0xff800000} {
+		in = append(in, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+	}
+	res = dumpString("<f", "%g", in)
+	want = "f0        count\n" +
+		"<-0.5     1\n" +
+		"[-0.5,0)  1\n" +
+		"[0,0.5]   2\n"
+	if res != want {
+		t.Error("float histogram wrong, got\n" + res)
+	}
+
+	h := parseBucketHist("0:-1,1,3", 1)
+	if h.bound(1) != "-0.3333333333333333" {
+		t.Error("fractional bucket bound wrong, got", h.bound(1))
+	}
+}
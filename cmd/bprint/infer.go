@@ -0,0 +1,105 @@
+package main
+
+// -infer-recsize guesses the record size of an unknown file by looking for
+// the period at which the bytes repeat best, which is the autocorrelation of
+// the byte stream. Fields like magic numbers, flags and high bytes of
+// counters usually repeat from record to record.
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	// Bytes read to infer the record size
+	inferSampleSize = 64 << 10
+	// Largest record size tried
+	inferMaxPeriod = 1024
+	// Number of candidates reported
+	inferCandidateCnt = 3
+	// Score difference under which a period is preferred to its multiple
+	inferScoreSlack = 0.05
+)
+
+type periodScore struct {
+	period int
+	// Fraction of bytes equal to the byte one period later
+	score float64
+}
+
+// inferRecordSize returns the periods at which sample repeats best, best
+// first.
+func inferRecordSize(sample []byte) []periodScore {
+	maxPeriod := len(sample) / 2
+	if maxPeriod > inferMaxPeriod {
+		maxPeriod = inferMaxPeriod
+	}
+	scores := make([]periodScore, 0, maxPeriod)
+	for p := 1; p <= maxPeriod; p++ {
+		same := 0
+		for i := p; i < len(sample); i++ {
+			if sample[i] == sample[i-p] {
+				same++
+			}
+		}
+		scores = append(scores, periodScore{p, float64(same) / float64(len(sample)-p)})
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].score > scores[j].score
+	})
+	return distinctPeriods(scores)
+}
+
+// distinctPeriods returns the first inferCandidateCnt periods of scores,
+// sorted best first, which aren't multiples of each other. A multiple of a
+// period repeats as well, so only one of them is kept: the smaller one if
+// its score is within inferScoreSlack of the larger one, the best one
+// otherwise.
+func distinctPeriods(scores []periodScore) []periodScore {
+	var res []periodScore
+	// A later period can still replace candidates, all are tried
+	for _, s := range scores {
+		related := -1
+		for i, r := range res {
+			if s.period%r.period == 0 || r.period%s.period == 0 {
+				related = i
+				break
+			}
+		}
+		switch {
+		case related < 0 && len(res) < inferCandidateCnt:
+			res = append(res, s)
+		case related >= 0 && res[related].period%s.period == 0 && s.score >= res[related].score-inferScoreSlack:
+			// The candidate repeats because s does, s replaces it and
+			// its other multiples
+			kept := []periodScore{}
+			for i, r := range res {
+				if i == related {
+					kept = append(kept, s)
+				} else if r.period%s.period != 0 {
+					kept = append(kept, r)
+				}
+			}
+			res = kept
+		}
+	}
+	return res
+}
+
+// printRecsizeCandidates reads a sample from r and prints the likely record
+// sizes to w.
+func printRecsizeCandidates(r io.Reader, w io.Writer) {
+	sample := make([]byte, inferSampleSize)
+	n, err := io.ReadFull(r, sample)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		panic(fmt.Sprintf("While reading data: %v", err))
+	}
+	if n < 2 {
+		panic("Not enough data to infer the record size")
+	}
+	fmt.Fprintln(w, "Likely record sizes:")
+	for _, c := range inferRecordSize(sample[:n]) {
+		fmt.Fprintf(w, "%d bytes, %.0f%% of bytes repeat\n", c.period, c.score*100)
+	}
+}
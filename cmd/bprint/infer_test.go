@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestInferRecordSize(t *testing.T) {
+	// 8 byte records of a magic number, a counter and a constant
+	var in []byte
+	for i := 0; i < 200; i++ {
+		in = append(in, 0xaa, 0x55, byte(i), byte(i>>8), 1, 0, 0, 0)
+	}
+	cands := inferRecordSize(in)
+	if len(cands) == 0 || cands[0].period != 8 {
+		t.Fatal("record size should be inferred as 8, got", cands)
+	}
+	for _, c := range cands[1:] {
+		if c.period%8 == 0 {
+			t.Error("multiple of the record size", c.period, "should be left out")
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	printRecsizeCandidates(bytes.NewReader(in), buf)
+	want := "Likely record sizes:\n8 bytes, 88% of bytes repeat\n"
+	if !bytes.HasPrefix(buf.Bytes(), []byte(want)) {
+		t.Error("record size candidates wrong, got", buf.String())
+	}
+}
+
+func TestDistinctPeriods(t *testing.T) {
+	for _, c := range []struct {
+		scores []periodScore
+		want   []int
+	}{
+		// 8 scores nearly as well as its double, 24 is a multiple of 8
+		{[]periodScore{{16, 0.90}, {8, 0.88}, {24, 0.87}, {5, 0.5}, {3, 0.4}}, []int{8, 5, 3}},
+		// 8 scores much worse than 16, it's left out
+		{[]periodScore{{16, 0.90}, {8, 0.5}, {7, 0.4}, {6, 0.3}}, []int{16, 7, 6}},
+		// 6 replaces 12 and 18, 7 takes the place left
+		{[]periodScore{{12, 0.9}, {18, 0.88}, {5, 0.87}, {6, 0.86}, {7, 0.1}}, []int{6, 5, 7}},
+	} {
+		var got []int
+		for _, s := range distinctPeriods(c.scores) {
+			got = append(got, s.period)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Error("distinct periods of", c.scores, "should be", c.want, "got", got)
+		}
+	}
+}
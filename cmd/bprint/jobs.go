@@ -0,0 +1,186 @@
+package main
+
+// Several input files are decoded one after the other, each after a banner
+// like tail's "==> name <==" with offsets and the record count from 0. With
+// -banner=false they're decoded as if concatenated: offsets and the record
+// count continue from the previous file, or start from 0 for each file with
+// -per-file. A partial record at the end of a file is not joined with the
+// next file's bytes. A file which can't be read is reported and skipped.
+// Either way -n and -records are for all the records, and the summaries like
+// -count-only or -stats are printed once for all the files.
+// With -jobs, the files after the current one are read ahead concurrently,
+// so slow sources like URLs don't hold up decoding. At most -max-mem bytes,
+// or readAheadSize without it, are read ahead, shared by the jobs, the rest
+// of a file is read as it's decoded. Decoding itself is still done in file
+// order as the decoding state is global, so the output is the same as
+// decoding the files one by one. Without -jobs each file is only opened
+// when it's decoded, and streamed like a single input.
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/gastaoss/bprint"
+)
+
+// Bytes read ahead by all the -jobs without -max-mem.
+const readAheadSize = 64 << 20
+
+// fileData is an opened input file with its first bytes read ahead.
+type fileData struct {
+	buf []byte
+	// The rest of the file after buf
+	r   io.Reader
+	f   io.ReadCloser
+	err interface{}
+}
+
+// openAhead opens the file at path and reads up to ahead bytes of it, a
+// panic is returned in err.
+func openAhead(path string, ahead int64) (fd fileData) {
+	defer func() {
+		if err := recover(); err != nil {
+			fd.err = err
+		}
+	}()
+	r, f := openFile(path)
+	if ahead > 0 {
+		buf, err := io.ReadAll(io.LimitReader(r, ahead))
+		if err != nil {
+			f.Close()
+			panic(fmt.Sprintf("While reading %s: %v", path, err))
+		}
+		fd.buf = buf
+	}
+	fd.r, fd.f = r, f
+	return
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (cr *countingReader) Read(p []byte) (n int, err error) {
+	n, err = cr.r.Read(p)
+	cr.n += n
+	return
+}
+
+// decodeFiles decodes the files at paths in order, reading the files after
+// the current one ahead with jobs above 1. The first skip bytes of each
+// file are skipped, then up to limit bytes are decoded if limit isn't
+// negative. It returns the number of files which couldn't be read.
+func decodeFiles(paths []string, jobs int, formatField []bprint.FieldType, recordSize int, skip, limit int64) (failed int) {
+	files := make([]chan fileData, len(paths))
+	for i := range files {
+		files[i] = make(chan fileData, 1)
+	}
+	// A slot is taken while a file is read ahead and until it's decoded,
+	// limiting the files kept in memory
+	var slots chan struct{}
+	if jobs > 1 {
+		ahead := int64(readAheadSize)
+		if maxMem > 0 {
+			ahead = maxMem
+		}
+		ahead /= int64(jobs)
+		slots = make(chan struct{}, jobs)
+		go func() {
+			for i, path := range paths {
+				slots <- struct{}{}
+				go func(path string, ch chan fileData) {
+					ch <- openAhead(path, ahead)
+				}(path, files[i])
+			}
+		}()
+	}
+
+	// Offset of the current file in the concatenated input
+	base := 0
+	banners := 0
+	banner := opt.banner && len(paths) > 1
+	// The last file decoded ended with a partial record
+	partial := false
+	startOutput()
+	for i, ch := range files {
+		var fd fileData
+		if slots != nil {
+			fd = <-ch
+		} else {
+			fd = openAhead(paths[i], 0)
+		}
+		if fd.err != nil {
+			fmt.Fprintln(diagOutput, fd.err)
+			failed++
+			if slots != nil {
+				<-slots
+			}
+			continue
+		}
+		if banner {
+			printBanner(paths[i], banners)
+			banners++
+		}
+		if opt.perFile || banner {
+			recordCnt, base = 0, 0
+			resetChecks()
+		}
+		offSet = 0
+		in := &countingReader{r: fd.r}
+		var binReader io.Reader = in
+		seekable := fd.f
+		if len(fd.buf) > 0 {
+			binReader = io.MultiReader(bytes.NewReader(fd.buf), in)
+			seekable = nil
+		}
+		// Bytes skipped by seeking, not read
+		seeked := 0
+		if skip > 0 {
+			if skipHeader(binReader, seekable, skip); seekable != nil && in.n == 0 {
+				seeked = int(skip)
+			}
+		}
+		if limit >= 0 {
+			binReader = io.LimitReader(binReader, limit)
+		}
+		offSet += base
+		if opt.guessEndian {
+			binReader = guessByteOrder(binReader, formatField, recordSize)
+		}
+		var stopped bool
+		stopped, partial = decodeInput(binReader, formatField, recordSize)
+		fd.f.Close()
+		if slots != nil {
+			<-slots
+		}
+		// The next file starts after the bytes of this one which were read
+		base += seeked + len(fd.buf) + in.n
+		if banner && !partial {
+			printEndOffset()
+		}
+		if stopped {
+			break
+		}
+	}
+	if !banner && !partial {
+		printEndOffset()
+	}
+	endOutput()
+	return failed
+}
+
+// printBanner prints the banner of the file at path before its records, the
+// banners before it are separated by an empty line. Output modes other than
+// the print format have no banner, their output could no longer be parsed.
+func printBanner(path string, banners int) {
+	if !printFmtOutput() {
+		return
+	}
+	if banners > 0 {
+		fmt.Fprintln(recordOutput())
+	}
+	fmt.Fprintf(recordOutput(), "==> %s <==\n", path)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeFiles(t *testing.T) {
+	// The first file is slow to read, its output must still come first
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte{1, 2})
+	}))
+	defer ts.Close()
+	path := filepath.Join(t.TempDir(), "b.bin")
+	if err := os.WriteFile(path, []byte{3, 4, 5}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	formatField, recordSize, _ := parseBinaryFmt("C")
+	opt.printFmt = convertPrintFields("%d") + "\n"
+	defer func() { opt.printRecordCnt, opt.perFile, opt.banner, output = false, false, true, os.Stdout }()
+	opt.printRecordCnt, opt.banner = true, false
+	buf := new(bytes.Buffer)
+	output = buf
+
+	recordCnt = 0
+	decodeFiles([]string{ts.URL, path, ts.URL}, 2, formatField, recordSize, 0, -1)
+	want := "1: 1\n2: 2\n" + "3: 3\n4: 4\n5: 5\n" + "6: 1\n7: 2\n"
+	if buf.String() != want {
+		t.Error("output of files not in file order, got", buf.String())
+	}
+
+	opt.perFile = true
+	buf.Reset()
+	decodeFiles([]string{ts.URL, path, ts.URL}, 2, formatField, recordSize, 0, -1)
+	want = "1: 1\n2: 2\n" + "1: 3\n2: 4\n3: 5\n" + "1: 1\n2: 2\n"
+	if buf.String() != want {
+		t.Error("-per-file didn't reset the record count, got", buf.String())
+	}
+}
+
+func TestDecodeFilesPartialRecord(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.bin"), filepath.Join(dir, "b.bin")
+	if err := os.WriteFile(a, []byte{1, 0, 2}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte{3, 0}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	formatField, recordSize, _ := parseBinaryFmt("S")
+	opt.printFmt = convertPrintFields("%d") + "\n"
+	defer func() { opt.printOffset, opt.banner, output, diagOutput = false, true, os.Stdout, os.Stderr }()
+	opt.printOffset, opt.banner = true, false
+	buf, diag := new(bytes.Buffer), new(bytes.Buffer)
+	output, diagOutput = buf, diag
+
+	recordCnt = 0
+	failed := decodeFiles([]string{a, filepath.Join(dir, "missing.bin"), b}, 1, formatField, recordSize, 0, -1)
+	// The trailing byte of a.bin isn't joined with b.bin, offsets continue
+	if want := "0000000 1\n0000002 \n0000003 3\n0000005 \n"; buf.String() != want {
+		t.Error("partial record joined with next file or offsets not continued, got", buf.String())
+	}
+	if failed != 1 || !strings.Contains(diag.String(), "While opening file:") {
+		t.Error("missing file not reported, failed", failed, "diag", diag.String())
+	}
+}
+
+func TestFileBanner(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.bin"), filepath.Join(dir, "b.bin")
+	if err := os.WriteFile(a, []byte{1, 2}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte{3}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	formatField, recordSize, _ := parseBinaryFmt("C")
+	opt.printFmt = convertPrintFields("%d") + "\n"
+	defer func() { opt.printRecordCnt, opt.jsonOutput, output = false, false, os.Stdout }()
+	opt.printRecordCnt = true
+	buf := new(bytes.Buffer)
+	output = buf
+
+	recordCnt = 0
+	decodeFiles([]string{a, b}, 1, formatField, recordSize, 0, -1)
+	want := "==> " + a + " <==\n1: 1\n2: 2\n\n==> " + b + " <==\n1: 3\n"
+	if buf.String() != want {
+		t.Errorf("files not printed after a banner, got %q", buf.String())
+	}
+
+	// The records of other output modes aren't mixed with banners
+	opt.jsonOutput = true
+	buf.Reset()
+	decodeFiles([]string{a, b}, 1, formatField, recordSize, 0, -1)
+	if strings.Contains(buf.String(), "==>") {
+		t.Errorf("banner printed with -j, got %q", buf.String())
+	}
+}
+
+func TestDecodeFilesSummary(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.bin"), filepath.Join(dir, "b.bin")
+	if err := os.WriteFile(a, []byte{1, 2}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte{3, 4, 5, 6}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	formatField, recordSize, _ := parseBinaryFmt("C")
+	opt.printFmt = convertPrintFields("%d") + "\n"
+	defer func() {
+		opt.printRecordCnt, opt.banner, opt.limit, opt.countOnly, opt.stats = false, true, 0, false, false
+		stats, output = nil, os.Stdout
+	}()
+	opt.printRecordCnt, opt.banner, opt.limit = true, false, 3
+	buf := new(bytes.Buffer)
+	output = buf
+
+	// -n is for the records of all the files
+	recordCnt = 0
+	decodeFiles([]string{a, b}, 1, formatField, recordSize, 0, -1)
+	if want := "1: 1\n2: 2\n3: 3\n"; buf.String() != want {
+		t.Errorf("-n should stop after 3 records of all files, got %q", buf.String())
+	}
+
+	opt.limit, opt.countOnly = 0, true
+	buf.Reset()
+	decodeFiles([]string{a, b}, 1, formatField, recordSize, 0, -1)
+	if want := "6 records, 6 bytes\n"; buf.String() != want {
+		t.Errorf("-count-only should print the total once, got %q", buf.String())
+	}
+	// -s and -L apply to each file
+	buf.Reset()
+	decodeFiles([]string{a, b}, 1, formatField, recordSize, 1, 2)
+	if want := "3 records, 3 bytes\n"; buf.String() != want {
+		t.Errorf("-count-only total after -s and -L wrong, got %q", buf.String())
+	}
+
+	opt.countOnly, opt.stats = false, true
+	buf.Reset()
+	decodeFiles([]string{a, b}, 1, formatField, recordSize, 0, -1)
+	if res := buf.String(); strings.Count(res, "field") != 1 || !strings.Contains(res, "f0     6      1    6    21   3.5") {
+		t.Errorf("-stats should print one table for all files, got %q", res)
+	}
+
+	// With banners, the summaries are still for all the files, without a
+	// banner as they're not the records of one file
+	opt.banner, opt.printRecordCnt = true, false
+	buf.Reset()
+	stats = nil
+	decodeFiles([]string{a, b}, 1, formatField, recordSize, 0, -1)
+	if res := buf.String(); strings.Contains(res, "==>") || strings.Count(res, "field") != 1 ||
+		!strings.Contains(res, "f0     6      1    6    21   3.5") {
+		t.Errorf("-stats with banners should print one table for all files, got %q", res)
+	}
+	opt.countOnly, opt.stats = true, false
+	buf.Reset()
+	decodeFiles([]string{a, b}, 1, formatField, recordSize, 0, -1)
+	if want := "6 records, 6 bytes\n"; buf.String() != want {
+		t.Errorf("-count-only with banners should print the total once, got %q", buf.String())
+	}
+}
+
+func TestDecodeFilesReadAhead(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.bin"), filepath.Join(dir, "b.bin")
+	if err := os.WriteFile(a, []byte{1, 1, 2, 2, 3, 3}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte{3, 3, 4}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	formatField, recordSize, _ := parseBinaryFmt("C")
+	opt.printFmt = convertPrintFields("%d") + "\n"
+	defer func() {
+		opt.printOffset, opt.banner, maxMem, onChange, output = false, true, 0, nil, os.Stdout
+	}()
+	opt.printOffset, opt.banner = true, false
+	buf := new(bytes.Buffer)
+	output = buf
+
+	// Only 2 bytes of each file are read ahead, the rest is read after them
+	maxMem = 4
+	decodeFiles([]string{a, b}, 2, formatField, recordSize, 0, -1)
+	want := "0000000 1\n0000001 1\n0000002 2\n0000003 2\n0000004 3\n0000005 3\n" +
+		"0000006 3\n0000007 3\n0000008 4\n0000009 \n"
+	if buf.String() != want {
+		t.Errorf("files bigger than the read ahead wrong, got %q", buf.String())
+	}
+
+	// With a banner, the first record of a file is a change
+	onChange = &changeCheck{field: 0}
+	opt.printOffset, opt.banner = false, true
+	for _, jobs := range []int{1, 2} {
+		buf.Reset()
+		decodeFiles([]string{a, b}, jobs, formatField, recordSize, 0, -1)
+		want = "==> " + a + " <==\n1\n2\n3\n\n==> " + b + " <==\n3\n4\n"
+		if buf.String() != want {
+			t.Errorf("-on-change with %d jobs should restart for each file, got %q", jobs, buf.String())
+		}
+	}
+}
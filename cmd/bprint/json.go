@@ -0,0 +1,80 @@
+package main
+
+// With -j, each record is printed as a JSON object on one line, with field
+// names as keys in the order of fields.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+
+	"github.com/gastaoss/bprint"
+)
+
+// Integers beyond this lose precision as JavaScript numbers.
+var maxSafeJSONInt = big.NewInt(1<<53 - 1)
+
+// jsonValue returns the JSON encoding of a field value. Large integers are
+// quoted with -json-str-nums.
+func jsonValue(v interface{}) []byte {
+	if isNumber(v) {
+		i := toBigInt(v)
+		if opt.jsonStrNums && new(big.Int).Abs(i).Cmp(maxSafeJSONInt) > 0 {
+			return strconv.AppendQuote(nil, i.String())
+		}
+		return []byte(i.String())
+	}
+	switch v := v.(type) {
+	case float32, float64:
+		if f := toFloat64(v); math.IsNaN(f) || math.IsInf(f, 0) {
+			// Not representable in JSON
+			return []byte("null")
+		}
+	case rgbColor, bprint.UUID:
+		return strconv.AppendQuote(nil, fmt.Sprint(v))
+	case []interface{}:
+		buf := []byte{'['}
+		for i, e := range v {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, jsonValue(e)...)
+		}
+		return append(buf, ']')
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+func printJSON(data []interface{}) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	add := func(key string, value []byte) {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(strconv.AppendQuote(nil, key))
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	if opt.printOffset {
+		add("offset", []byte(strconv.Itoa(offSet)))
+	}
+	if opt.printRecordCnt {
+		add("record", []byte(strconv.Itoa(recordCnt)))
+	}
+	if recordHasher != nil {
+		add("hash", strconv.AppendQuote(nil, fmt.Sprintf("%08x", recordHash)))
+	}
+	for i, v := range data {
+		add(fieldName(i), jsonValue(v))
+	}
+	buf.WriteString("}\n")
+	output.Write(buf.Bytes())
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	defer func() { opt.jsonOutput, opt.jsonStrNums, opt.printOffset = false, false, false }()
+	opt.jsonOutput, opt.printOffset = true, true
+
+	in := []byte{
+		0xff,
+		0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		'a', '"', 0,
+		0xff, 0, 0,
+	}
+	res := dumpString("cQzk", "%d %d %s %s", in)
+	want := `{"offset":0,"f0":-1,"f1":18446744073709551614,"f2":"a\"","f3":"#ff0000"}` + "\n"
+	if res != want {
+		t.Error("JSON output wrong, got", res)
+	}
+
+	opt.jsonStrNums, opt.printOffset = true, false
+	res = dumpString("cQ", "%d %d", in[:9])
+	want = `{"f0":-1,"f1":"18446744073709551614"}` + "\n"
+	if res != want {
+		t.Error("JSON output with string numbers wrong, got", res)
+	}
+	res = dumpString("Q", "%d", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x1f, 0})
+	if res != `{"f0":9007199254740991}`+"\n" {
+		t.Error("JSON output of safe integer should not be quoted, got", res)
+	}
+}
+
+func TestJSONFloat(t *testing.T) {
+	defer func() { opt.jsonOutput = false }()
+	opt.jsonOutput = true
+
+	in := []byte{0x00, 0x00, 0xc0, 0x3f, 0x00, 0x00, 0xc0, 0x7f}
+	if res := dumpString("ff", "%g %g", in); res != `{"f0":1.5,"f1":null}`+"\n" {
+		t.Error("JSON output of floats wrong, got", res)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+// With -go or -c-struct, each record is printed as a struct literal on one
+// line, like {Flags: 1, Length: 258} or {.flags = 1, .length = 258}, to
+// paste sample data into code. Integers printed with %x in the print format
+// are in hex with 0x.
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gastaoss/bprint"
+)
+
+// literalName returns the field name as an identifier, exported for Go. Other
+// characters than letters and digits, like in mac[1], become '_'.
+func literalName(name string, c bool) string {
+	id := strings.TrimRight(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return '_'
+	}, name), "_")
+	if c || id == "" {
+		return id
+	}
+	return strings.ToUpper(id[:1]) + id[1:]
+}
+
+// literalValue returns the source code of a value printed with spec, in Go
+// or in C.
+func literalValue(spec string, v interface{}, c bool) string {
+	switch v := v.(type) {
+	case []interface{}:
+		elems := make([]string, len(v))
+		for i, e := range v {
+			elems[i] = literalValue(spec, e, c)
+		}
+		if c {
+			return "{" + strings.Join(elems, ", ") + "}"
+		} else if len(v) == 0 {
+			return "nil"
+		}
+		return fmt.Sprintf("[]%T{%s}", v[0], strings.Join(elems, ", "))
+	case string:
+		return strconv.Quote(v)
+	case rgbColor, bprint.UUID:
+		return strconv.Quote(fmt.Sprint(v))
+	case float32, float64:
+		switch f := toFloat64(v); {
+		case math.IsNaN(f) && c:
+			return "NAN"
+		case math.IsNaN(f):
+			return "math.NaN()"
+		case math.IsInf(f, 0) && c:
+			return strings.Replace(strconv.FormatFloat(f, 'g', -1, 64), "Inf", "INFINITY", 1)
+		case math.IsInf(f, 0):
+			return fmt.Sprintf("math.Inf(%d)", int(math.Copysign(1, f)))
+		}
+		return fmt.Sprint(v)
+	}
+	if !isNumber(v) {
+		return strconv.Quote(fmt.Sprint(v))
+	}
+	if verb := spec[len(spec)-1]; verb == 'x' || verb == 'X' {
+		return fmt.Sprintf("%#"+string(verb), v)
+	}
+	return fmt.Sprint(v)
+}
+
+// printLiteral prints a record as a Go struct literal, or as a C designated
+// initializer with -c-struct.
+func printLiteral(data []interface{}) {
+	c := opt.cLiteral
+	var cells []string
+	add := func(name, value string) {
+		if c {
+			cells = append(cells, "."+literalName(name, c)+" = "+value)
+		} else {
+			cells = append(cells, literalName(name, c)+": "+value)
+		}
+	}
+	if opt.printOffset {
+		add("offset", strconv.Itoa(offSet))
+	}
+	if opt.printRecordCnt {
+		add("record", strconv.Itoa(recordCnt))
+	}
+	if recordHasher != nil {
+		add("hash", fmt.Sprintf("0x%08x", recordHash))
+	}
+	for i, v := range data {
+		if i < len(fieldConv) && fieldConv[i] != nil {
+			v = fieldConv[i](v)
+		}
+		add(fieldName(i), literalValue(prettySpecs[i], v, c))
+	}
+	fmt.Fprintln(output, "{"+strings.Join(cells, ", ")+"},")
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLiteral(t *testing.T) {
+	defer func() { opt.goLiteral, opt.cLiteral, fieldNames = false, false, nil }()
+	opt.goLiteral = true
+
+	in := []byte{1, 2, 1, 0xef, 0xbe, 0xad, 0xde}
+	fieldNames = []string{"flags", "length", "crc"}
+	if res := dumpString("CSL", "%d %d %x", in); res != "{Flags: 1, Length: 258, Crc: 0xdeadbeef},\n" {
+		t.Errorf("Go struct literal wrong, got %q", res)
+	}
+	fieldNames = nil
+	if res := dumpString("cz", "%d %s", []byte{0xff, 'h', 'i', 0}); res != "{F0: -1, F1: \"hi\"},\n" {
+		t.Errorf("Go struct literal without names wrong, got %q", res)
+	}
+
+	opt.goLiteral, opt.cLiteral = false, true
+	fieldNames = []string{"flags", "mac[0]"}
+	if res := dumpString("CC", "%d %X", []byte{1, 0xab}); res != "{.flags = 1, .mac_0 = 0XAB},\n" {
+		t.Errorf("C struct initializer wrong, got %q", res)
+	}
+}
+
+func TestLiteralValue(t *testing.T) {
+	var tests = []struct {
+		v    interface{}
+		c    bool
+		want string
+	}{
+		{[]interface{}{uint8(1), uint8(2)}, false, "[]uint8{1, 2}"},
+		{[]interface{}{uint8(1), uint8(2)}, true, "{1, 2}"},
+		{[]interface{}{}, false, "nil"},
+		{math.NaN(), false, "math.NaN()"},
+		{math.Inf(-1), true, "-INFINITY"},
+		{float32(1.5), false, "1.5"},
+		{"a\"b", true, `"a\"b"`},
+	}
+	for _, v := range tests {
+		if res := literalValue("%v", v.v, v.c); res != v.want {
+			t.Errorf("literal of %v should be %s, got %s", v.v, v.want, res)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+// -mask ANDs fields with a constant and optionally shifts them right, for ad
+// hoc extraction of bits without naming bitfields, e.g. "0:0xf0>>4" for the
+// high nibble of field 0.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gastaoss/bprint"
+)
+
+type fieldMask struct {
+	field int
+	mask  uint64
+	shift uint
+}
+
+var fieldMasks []fieldMask
+
+// parseFieldMasks parses a mask list like "0:0x0f,1:0xff00>>8".
+func parseFieldMasks(s string, fields []bprint.FieldType) (masks []fieldMask) {
+	for _, v := range strings.Split(s, ",") {
+		invalid := func() {
+			panic(specError{fmt.Errorf("Invalid mask '%s', should be like 0:0x0f or 0:0xf0>>4", v)})
+		}
+		idx := strings.Index(v, ":")
+		if idx <= 0 {
+			invalid()
+		}
+		var m fieldMask
+		var err error
+		if m.field, err = strconv.Atoi(v[:idx]); err != nil {
+			invalid()
+		}
+		maskStr := v[idx+1:]
+		if i := strings.Index(maskStr, ">>"); i >= 0 {
+			shift, err := strconv.ParseUint(maskStr[i+2:], 10, 8)
+			if err != nil || shift >= 64 {
+				invalid()
+			}
+			m.shift = uint(shift)
+			maskStr = maskStr[:i]
+		}
+		if m.mask, err = strconv.ParseUint(maskStr, 0, 64); err != nil {
+			invalid()
+		}
+		if m.field < 0 || m.field >= len(fields) {
+			panic(specError{fmt.Errorf("Mask field %d out of range, record has %d fields", m.field, len(fields))})
+		}
+		if !fields[m.field].IsInt() {
+			panic(specError{fmt.Errorf("Mask field %d is %s, should be an integer", m.field, fields[m.field].String())})
+		}
+		masks = append(masks, m)
+	}
+	return
+}
+
+// maskTypes returns the types of the fields after masking, masked fields
+// become unsigned 64-bit like bitfields.
+func maskTypes(masks []fieldMask, fields []bprint.FieldType) []bprint.FieldType {
+	res := append([]bprint.FieldType{}, fields...)
+	for _, m := range masks {
+		res[m.field] = bprint.U64
+	}
+	return res
+}
+
+// applyMasks masks the fields in data in place.
+func applyMasks(masks []fieldMask, data []interface{}) {
+	for _, m := range masks {
+		if m.field < len(data) {
+			data[m.field] = uint64(toInt64(data[m.field])) & m.mask >> m.shift
+		}
+	}
+}
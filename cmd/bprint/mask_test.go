@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFieldMask(t *testing.T) {
+	defer func() { fieldMasks = nil }()
+	fields, _, _ := parseBinaryFmt("CSc")
+	fieldMasks = parseFieldMasks("0:0xf0>>4,1:0x0ff0>>4,2:0xff", fields)
+
+	if res := dumpString("CSc", "%d %x %d", []byte{0xab, 0x34, 0x12, 0xff}); res != "10 23 255\n" {
+		t.Error("masked fields wrong, got", res)
+	}
+
+	for _, s := range []string{"0", "x:1", "3:1", "0:z", "0:1>>64"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("mask", s, "should be rejected")
+				}
+			}()
+			parseFieldMasks(s, fields)
+		}()
+	}
+}
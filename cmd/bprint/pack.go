@@ -0,0 +1,287 @@
+package main
+
+// -P packs text into binary, the reverse of decoding: each input line holds
+// the values of one record separated by whitespace, which are written in
+// the types and byte order of the binary format. Integers can be decimal or
+// 0x prefixed hex, colors are like #ff0080, GUIDs are in the canonical form
+// and strings are single words.
+// Skipped bytes are written as zeros.
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/gastaoss/bprint"
+)
+
+// packRecords reads the lines of r and writes each as a record of
+// formatField to output. An invalid value panics with its line number,
+// after name for one of several inputs.
+func packRecords(r io.Reader, name string, formatField []bprint.FieldType) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		tokens := strings.Fields(scanner.Text())
+		if len(tokens) == 0 {
+			continue
+		}
+		rec, err := packRecord(formatField, tokens)
+		if err != nil {
+			if name != "" {
+				panic(fmt.Sprintf("%s line %d: %v", name, line, err))
+			}
+			panic(fmt.Sprintf("Line %d: %v", line, err))
+		}
+		output.Write(rec)
+	}
+	if err := scanner.Err(); err != nil {
+		panic(fmt.Sprintf("While reading text input: %v", err))
+	}
+}
+
+// packRecord returns the bytes of a record of fields with the values in
+// tokens.
+func packRecord(fields []bprint.FieldType, tokens []string) (rec []byte, err error) {
+	data := bprint.DataFields(fields)
+	if len(data) > 0 && data[len(data)-1].IsArray() {
+		if len(tokens) < len(data)-1 {
+			return nil, fmt.Errorf("%d values, record has %d fields before the '*' field", len(tokens), len(data)-1)
+		}
+	} else if len(tokens) != len(data) {
+		return nil, fmt.Errorf("%d values, record has %d fields", len(tokens), len(data))
+	}
+	order := byteOrder
+	next := 0
+	for i, t := range fields {
+		if t.IsArray() {
+			// The rest of the values are elements
+			elem := fields[i+1:]
+			for next < len(tokens) {
+				cnt := len(bprint.DataFields(elem))
+				if next+cnt > len(tokens) {
+					return nil, fmt.Errorf("%d values left, an element has %d", len(tokens)-next, cnt)
+				}
+				var b []byte
+				if b, err = packRecord(elem, tokens[next:next+cnt]); err != nil {
+					return nil, err
+				}
+				rec = append(rec, b...)
+				next += cnt
+			}
+			return rec, nil
+		}
+		switch t {
+		case bprint.SKIP:
+			rec = append(rec, 0)
+			continue
+		case bprint.BACK:
+			// The values of the fields read again could disagree
+			return nil, fmt.Errorf("a record with 'X' can't be packed")
+		case bprint.STRTAIL, bprint.ALIGNTAIL:
+			// Written with the STR or ALIGN before it
+			continue
+		case bprint.ALIGN:
+			size := 1
+			for j := i + 1; j < len(fields) && fields[j] == bprint.ALIGNTAIL; j++ {
+				size++
+			}
+			rec = append(rec, make([]byte, (size-len(rec)%size)%size)...)
+			continue
+		case bprint.LITTLE:
+			order = binary.LittleEndian
+			continue
+		case bprint.BIG:
+			order = binary.BigEndian
+			continue
+		}
+		tok := tokens[next]
+		next++
+		if t == bprint.STR {
+			size := 1
+			for j := i + 1; j < len(fields) && fields[j] == bprint.STRTAIL; j++ {
+				size++
+			}
+			if len(tok) > size {
+				return nil, fmt.Errorf("string '%s' longer than %d bytes", tok, size)
+			}
+			rec = append(rec, tok...)
+			rec = append(rec, make([]byte, size-len(tok))...)
+			continue
+		}
+		var b []byte
+		if b, err = packValue(t, order, tok); err != nil {
+			return nil, fmt.Errorf("field %d: %v", next-1, err)
+		}
+		rec = append(rec, b...)
+	}
+	return rec, nil
+}
+
+// packValue returns the bytes of a value of type t parsed from s.
+func packValue(t bprint.FieldType, order binary.ByteOrder, s string) ([]byte, error) {
+	b := make([]byte, t.Size())
+	switch t {
+	case bprint.I8, bprint.I16, bprint.I24, bprint.I32, bprint.I64:
+		v, err := strconv.ParseInt(s, 0, t.Size()*8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value '%s'", t.String(), s)
+		}
+		putUint(order, b, uint64(v))
+	case bprint.U8, bprint.U16, bprint.U24, bprint.U32, bprint.U64:
+		v, err := strconv.ParseUint(s, 0, t.Size()*8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value '%s'", t.String(), s)
+		}
+		putUint(order, b, v)
+	case bprint.I128, bprint.U128:
+		v, ok := new(big.Int).SetString(s, 0)
+		if !ok || !fitsInt128(v, t == bprint.I128) {
+			return nil, fmt.Errorf("invalid %s value '%s'", t.String(), s)
+		}
+		if v.Sign() < 0 {
+			// Two's complement
+			v.Add(v, new(big.Int).Lsh(big.NewInt(1), 128))
+		}
+		v.FillBytes(b)
+		if order.Uint16([]byte{0, 1}) != 1 {
+			// Little-endian
+			for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+				b[i], b[j] = b[j], b[i]
+			}
+		}
+	case bprint.F32:
+		v, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value '%s'", t.String(), s)
+		}
+		order.PutUint32(b, math.Float32bits(float32(v)))
+	case bprint.F64:
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value '%s'", t.String(), s)
+		}
+		order.PutUint64(b, math.Float64bits(v))
+	case bprint.F16:
+		v, err := strconv.ParseFloat(s, 32)
+		h := float16Bits(float32(v))
+		if err != nil || h&0x7fff == 0x7c00 && !math.IsInf(v, 0) {
+			return nil, fmt.Errorf("invalid %s value '%s'", t.String(), s)
+		}
+		order.PutUint16(b, h)
+	case bprint.RGB, bprint.RGBA:
+		c, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+		if err != nil || len(s) != 1+2*len(b) || s[0] != '#' {
+			return nil, fmt.Errorf("invalid %s value '%s', should be like #ff0080", t.String(), s)
+		}
+		// Bytes are in R, G, B (, A) order
+		putUint(binary.BigEndian, b, c)
+	case bprint.GUID:
+		u, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+		if err != nil || len(u) != 16 || len(s) != 36 || strings.Count(s, "-") != 4 {
+			return nil, fmt.Errorf("invalid %s value '%s', should be like 00112233-4455-6677-8899-aabbccddeeff", t.String(), s)
+		}
+		// The first three fields are little-endian
+		b[0], b[1], b[2], b[3] = u[3], u[2], u[1], u[0]
+		b[4], b[5], b[6], b[7] = u[5], u[4], u[7], u[6]
+		copy(b[8:], u[8:])
+	case bprint.STRZ:
+		return append([]byte(s), strTerm), nil
+	case bprint.ULEB:
+		v, err := strconv.ParseUint(s, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value '%s'", t.String(), s)
+		}
+		return binary.AppendUvarint(nil, v), nil
+	case bprint.SLEB:
+		v, err := strconv.ParseInt(s, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value '%s'", t.String(), s)
+		}
+		return appendSLEB128(nil, v), nil
+	default:
+		return nil, fmt.Errorf("%s fields can't be packed", t.String())
+	}
+	return b, nil
+}
+
+// fitsInt128 reports whether v is in the range of a signed or unsigned
+// 128-bit integer.
+func fitsInt128(v *big.Int, signed bool) bool {
+	if !signed {
+		return v.Sign() >= 0 && v.BitLen() <= 128
+	}
+	if v.Sign() < 0 {
+		// -2^127 needs 128 bits as a magnitude
+		return new(big.Int).Add(v, big.NewInt(1)).BitLen() <= 127
+	}
+	return v.BitLen() <= 127
+}
+
+// appendSLEB128 appends v to b as a signed LEB128 number, which unlike
+// binary.AppendVarint isn't zigzag encoded.
+func appendSLEB128(b []byte, v int64) []byte {
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 && c&0x40 == 0 || v == -1 && c&0x40 != 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+// float16Bits converts f to IEEE 754 half precision bits, rounding to the
+// nearest even. Values beyond the range become infinity.
+func float16Bits(f float32) uint16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int(b>>23&0xff) - 127 + 15
+	frac := b & 0x7fffff
+	switch {
+	case b>>23&0xff == 0xff:
+		if frac != 0 {
+			// NaN
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	case exp >= 0x1f:
+		return sign | 0x7c00
+	case exp <= 0:
+		if exp < -10 {
+			// Below half of the smallest subnormal
+			return sign
+		}
+		// Subnormal, the rounding may carry into the smallest normal
+		return sign | uint16(roundShift(frac|0x800000, uint(14-exp)))
+	}
+	// A carry of the rounding into the exponent is right, up to infinity
+	return sign | uint16(roundShift(uint32(exp)<<23|frac, 13))
+}
+
+// roundShift shifts v right by s bits, rounding to the nearest even.
+func roundShift(v uint32, s uint) uint32 {
+	r, rem, half := v>>s, v&(1<<s-1), uint32(1)<<(s-1)
+	if rem > half || rem == half && r&1 == 1 {
+		r++
+	}
+	return r
+}
+
+// putUint puts the low len(b) bytes of v into b in the given byte order.
+func putUint(order binary.ByteOrder, b []byte, v uint64) {
+	bigEndian := order.Uint16([]byte{0, 1}) == 1
+	for i := range b {
+		if bigEndian {
+			b[len(b)-1-i] = byte(v >> (8 * uint(i)))
+		} else {
+			b[i] = byte(v >> (8 * uint(i)))
+		}
+	}
+}
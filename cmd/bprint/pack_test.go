@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gastaoss/bprint"
+)
+
+func TestPackRecords(t *testing.T) {
+	defer func() { byteOrder, output = binary.LittleEndian, os.Stdout }()
+	byteOrder = binary.BigEndian
+	buf := new(bytes.Buffer)
+	output = buf
+
+	formatField, _, _ := parseBinaryFmt("c S x k a3 <m")
+	packRecords(strings.NewReader("-1 0x102 #ff0080 ab -2\n\n127 65535 #000000 abc 5\n"), "", formatField)
+	want := []byte{
+		0xff, 0x01, 0x02, 0, 0xff, 0x00, 0x80, 'a', 'b', 0, 0xfe, 0xff, 0xff,
+		0x7f, 0xff, 0xff, 0, 0, 0, 0, 'a', 'b', 'c', 5, 0, 0,
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("packed records wrong, got % x", buf.Bytes())
+	}
+	// Decoding gives the values back, the string padding isn't trimmed here
+	if res := dumpString("c S x k a3 <m", "%d %d %s %s %d", buf.Bytes()); res != "-1 258 #ff0080 ab\\x00 -2\n127 65535 #000000 abc 5\n" {
+		t.Errorf("packed records don't decode to the input, got %q", res)
+	}
+	output = buf
+
+	for _, s := range []string{"1 2", "128 1 #000000 a 1", "1 x #000000 a 1", "1 1 red a 1", "1 1 #000000 abcd 1", "1 1 #000000 a 0x800000"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil || !strings.HasPrefix(err.(string), "Line 2: ") {
+					t.Error("line", s, "should be rejected with its line number, got", err)
+				}
+			}()
+			packRecords(strings.NewReader("1 1 #000000 a 1\n"+s+"\n"), "", formatField)
+		}()
+	}
+}
+
+func TestFloat16Bits(t *testing.T) {
+	testData := []struct {
+		f float32
+		h uint16
+	}{
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{65504, 0x7bff},
+		{65520, 0x7c00},
+		{1.0 / (1 << 24), 0x0001},
+		{1.0 / (1 << 25), 0x0000},
+		{1.5 / (1 << 24), 0x0002},
+		{1.0 / (1 << 14), 0x0400},
+		{1 + 1.0/(1<<11), 0x3c00},
+		{1 + 3.0/(1<<11), 0x3c02},
+	}
+	for _, td := range testData {
+		if h := float16Bits(td.f); h != td.h {
+			t.Errorf("float16 bits of %g should be %04x, got %04x", td.f, td.h, h)
+		}
+	}
+	if _, err := packValue(bprint.F16, binary.LittleEndian, "1e5"); err == nil {
+		t.Error("float16 value beyond the range should be rejected")
+	}
+}
+
+func TestPackLEB128(t *testing.T) {
+	formatField, _, _ := parseBinaryFmt("vV")
+	for _, v := range []int64{0, 1, 63, 64, -1, -64, -65, 150, -128, 1 << 62, -1 << 63} {
+		s := fmt.Sprintf("%d %d", uint64(v)&(1<<63-1), v)
+		rec, err := packRecord(formatField, strings.Fields(s))
+		if err != nil {
+			t.Error("LEB128 values", s, "not packed, got", err)
+			continue
+		}
+		if res := dumpString("vV", "%d %d", rec); res != s+"\n" {
+			t.Errorf("LEB128 values %s don't decode back, got %q from % x", s, res, rec)
+		}
+	}
+}
+
+func TestPack128Bit(t *testing.T) {
+	defer func() { byteOrder = binary.LittleEndian }()
+	formatField, _, _ := parseBinaryFmt("oO")
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		byteOrder = order
+		for _, s := range []string{"-1 18446744073709551766", "-170141183460469231731687303715884105728 340282366920938463463374607431768211455"} {
+			rec, err := packRecord(formatField, strings.Fields(s))
+			if err != nil {
+				t.Error("128-bit values", s, "not packed, got", err)
+				continue
+			}
+			if res := dumpString("oO", "%d %d", rec); res != s+"\n" {
+				t.Errorf("128-bit values %s don't decode back, got %q", s, res)
+			}
+		}
+	}
+	for _, s := range []string{"170141183460469231731687303715884105728 0", "0 -1", "0 340282366920938463463374607431768211456"} {
+		if _, err := packRecord(formatField, strings.Fields(s)); err == nil {
+			t.Error("128-bit values", s, "should be out of range")
+		}
+	}
+}
+
+func TestPackGUID(t *testing.T) {
+	formatField, _, _ := parseBinaryFmt("g")
+	s := "00112233-4455-6677-8899-aabbccddeeff"
+	rec, err := packRecord(formatField, []string{s})
+	want := []byte{0x33, 0x22, 0x11, 0x00, 0x55, 0x44, 0x77, 0x66, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+	if err != nil || !bytes.Equal(rec, want) {
+		t.Errorf("GUID packed wrong, got % x %v", rec, err)
+	}
+	if res := dumpString("g", "%s", rec); res != s+"\n" {
+		t.Errorf("GUID doesn't decode back, got %q", res)
+	}
+	for _, v := range []string{"00112233445566778899aabbccddeeff", "00112233-4455-6677-8899-aabbccddee", "0011223-34455-6677-8899-aabbccddeeff-", "g0112233-4455-6677-8899-aabbccddeeff"} {
+		if _, err := packRecord(formatField, []string{v}); err == nil {
+			t.Error("GUID", v, "should be rejected")
+		}
+	}
+}
+
+func TestPackAlign(t *testing.T) {
+	formatField, _, _ := parseBinaryFmt("z !4 L")
+	rec, err := packRecord(formatField, []string{"abcde", "6"})
+	if want := []byte("abcde\x00\x00\x00\x06\x00\x00\x00"); err != nil || !bytes.Equal(rec, want) {
+		t.Errorf("aligned record packed wrong, got % x %v", rec, err)
+	}
+}
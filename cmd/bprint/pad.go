@@ -0,0 +1,61 @@
+package main
+
+// -pad fills the fields missing from a record cut short at EOF with zero
+// values, so every record printed has the full field count.
+
+import (
+	"math/big"
+
+	"github.com/gastaoss/bprint"
+)
+
+// zeroValue returns the value of a field of type t decoded from zero bytes,
+// an empty string or list for strings and arrays.
+func zeroValue(t bprint.FieldType) interface{} {
+	switch t {
+	case bprint.I8:
+		return int8(0)
+	case bprint.I16:
+		return int16(0)
+	case bprint.I24, bprint.I32:
+		return int32(0)
+	case bprint.I64, bprint.SLEB:
+		return int64(0)
+	case bprint.U8:
+		return uint8(0)
+	case bprint.U16:
+		return uint16(0)
+	case bprint.U24, bprint.U32:
+		return uint32(0)
+	case bprint.U64, bprint.ULEB:
+		return uint64(0)
+	case bprint.F16, bprint.F32:
+		return float32(0)
+	case bprint.F64:
+		return float64(0)
+	case bprint.I128, bprint.U128:
+		return new(big.Int)
+	case bprint.RGB, bprint.RGBA:
+		return rgbColor{Alpha: t == bprint.RGBA}
+	case bprint.GUID:
+		return bprint.UUID{}
+	case bprint.STR, bprint.STRZ:
+		return ""
+	}
+	return []interface{}{}
+}
+
+// padRecord fills data after the n fields read with zero values of the
+// fields of formatField, and of the -array field. It returns the field
+// count of the whole record.
+func padRecord(data []interface{}, n int, formatField []bprint.FieldType) int {
+	fields := bprint.DataFields(formatField)
+	for ; n < len(data); n++ {
+		if n < len(fields) {
+			data[n] = zeroValue(fields[n])
+		} else {
+			data[n] = zeroValue(array.listType)
+		}
+	}
+	return n
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/gastaoss/bprint"
+)
+
+func TestPadRecord(t *testing.T) {
+	diag := new(bytes.Buffer)
+	diagOutput, truncatedCnt = diag, 0
+	defer func() { opt.padShort, diagOutput, truncatedCnt = false, os.Stderr, 0 }()
+	opt.padShort = true
+	if res := dumpString("C S a2", "%d %d [%s]", []byte{1, 2, 0, 'a', 'b', 3, 4}); res != "1 2 [ab]\n3 0 []\n" {
+		t.Error("short record not padded, got", res)
+	}
+	if s := diag.String(); s != "Record 2 at offset 5: truncated at EOF after 1 of 3 fields\n" || truncatedCnt != 1 {
+		t.Error("padded record should still be reported as truncated, got", s)
+	}
+
+	data := make([]interface{}, 4)
+	list, _, _ := parseBinaryFmt("C*")
+	fields := []bprint.FieldType{bprint.F64, bprint.I128, bprint.RGB, list[0], bprint.U8}
+	if n := padRecord(data, 0, fields); n != 4 {
+		t.Error("padded field count wrong, got", n)
+	}
+	if data[0] != float64(0) || toBigInt(data[1]).Sign() != 0 || data[2] != (rgbColor{}) || len(data[3].([]interface{})) != 0 {
+		t.Error("zero values wrong, got", data)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+// With -progress, a status line with the records and bytes decoded and the
+// current offset is written to stderr every second, overwriting itself with
+// a carriage return. A ticker only marks the line as due, it's printed by
+// the decoding loop so the counts are read where they're updated.
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+type progressReport struct {
+	w io.Writer
+	// Total bytes of the input files, 0 if unknown like for stdin
+	total int64
+	bytes int64
+	due   atomic.Bool
+	stop  chan struct{}
+}
+
+// Reports progress when -progress is given.
+var progress *progressReport
+
+// startProgress starts reporting progress to w every interval, total is the
+// input size for a percentage.
+func startProgress(w io.Writer, total int64, interval time.Duration) *progressReport {
+	p := &progressReport{w: w, total: total, stop: make(chan struct{})}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.due.Store(true)
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// update counts the n bytes of a decoded record and prints the status line
+// if it's due.
+func (p *progressReport) update(n int) {
+	p.bytes += int64(n)
+	if p.due.Load() {
+		p.due.Store(false)
+		p.print()
+	}
+}
+
+func (p *progressReport) print() {
+	fmt.Fprintf(p.w, "\r%d records, %d bytes read, offset %#x", recordCnt, p.bytes, offSet)
+	if p.total > 0 {
+		fmt.Fprintf(p.w, " (%d%%)", int64(offSet)*100/p.total)
+	}
+}
+
+// done stops the reports and prints the final status line.
+func (p *progressReport) done() {
+	close(p.stop)
+	p.print()
+	fmt.Fprintln(p.w)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	buf := new(bytes.Buffer)
+	progress = startProgress(buf, 8, time.Hour)
+	defer func() { progress = nil }()
+
+	dumpString("S", "%d", []byte{1, 0, 2, 0})
+	if buf.Len() != 0 {
+		t.Errorf("progress printed before it's due, got %q", buf.String())
+	}
+	progress.due.Store(true)
+	dumpString("S", "%d", []byte{3, 0})
+	progress.done()
+	want := "\r1 records, 6 bytes read, offset 0x2 (25%)\r1 records, 6 bytes read, offset 0x2 (25%)\n"
+	if buf.String() != want {
+		t.Errorf("progress line wrong, got %q", buf.String())
+	}
+}
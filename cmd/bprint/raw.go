@@ -0,0 +1,150 @@
+package main
+
+// -raw prints the bytes of each field in hex after its value, like
+// "258 [02 01]" for a little-endian uint16, to check how the binary format
+// maps to the input. The bytes are in input order whatever the byte order.
+// -field-offsets prints the offset of each field before it in the same way,
+// like "@0000002 length=258".
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gastaoss/bprint"
+)
+
+// Fields of the binary format with -raw, nil without it.
+var rawFields []bprint.FieldType
+
+// Fields of the binary format with -field-offsets, nil without it.
+var offsetFields []bprint.FieldType
+
+// fieldBytes returns the bytes in the record raw of each data field of
+// fields. A list repeated to the end has the rest of the record, the bytes
+// of a field cut short at the end of raw are the bytes left.
+func fieldBytes(fields []bprint.FieldType, raw []byte) (res [][]byte) {
+	for _, v := range fieldSpans(fields, raw) {
+		res = append(res, raw[v[0]:v[1]])
+	}
+	return
+}
+
+// fieldSpans returns the start and end in the record raw of each data field
+// of fields, like fieldBytes.
+func fieldSpans(fields []bprint.FieldType, raw []byte) (res [][2]int) {
+	pos := 0
+	for i, t := range fields {
+		size := t.Size()
+		switch t {
+		case bprint.SKIP:
+			pos++
+			continue
+		case bprint.BACK:
+			pos--
+			continue
+		case bprint.STRTAIL, bprint.ALIGNTAIL, bprint.LITTLE, bprint.BIG:
+			continue
+		case bprint.ALIGN:
+			n := 1
+			for j := i + 1; j < len(fields) && fields[j] == bprint.ALIGNTAIL; j++ {
+				n++
+			}
+			pos += (n - pos%n) % n
+			continue
+		case bprint.STR:
+			for j := i + 1; j < len(fields) && fields[j] == bprint.STRTAIL; j++ {
+				size++
+			}
+		case bprint.STRZ:
+			// With the terminator
+			for size = 1; pos+size <= len(raw) && raw[pos+size-1] != strTerm; size++ {
+			}
+		case bprint.ULEB, bprint.SLEB:
+			for size = 1; pos+size <= len(raw) && raw[pos+size-1]&0x80 != 0; size++ {
+			}
+		}
+		if t.IsArray() {
+			size = len(raw) - pos
+		}
+		if pos >= len(raw) {
+			return
+		}
+		end := pos + size
+		if end > len(raw) {
+			end = len(raw)
+		}
+		res = append(res, [2]int{pos, end})
+		pos = end
+		if t.IsArray() {
+			return
+		}
+	}
+	return
+}
+
+// rawPrintFmt returns printFmt with the bytes of a field printed in
+// brackets after each of the first cnt print fields.
+func rawPrintFmt(printFmt string, cnt int) string {
+	res := ""
+	prev := 0
+	for i, v := range findPrintFields(printFmt) {
+		if i == cnt {
+			break
+		}
+		res += printFmt[prev:v[1]] + " [%s]"
+		prev = v[1]
+	}
+	return res + printFmt[prev:]
+}
+
+// addRawBytes returns the values of data with the bytes of the fields of
+// the record raw in hex after each of them, the values after the fields
+// like the -crc checksum are left alone.
+func addRawBytes(data []interface{}, raw []byte) []interface{} {
+	var hex []interface{}
+	for _, b := range fieldBytes(rawFields, raw) {
+		hex = append(hex, fmt.Sprintf("% x", b))
+	}
+	hex = selectValues(hex)
+	res := make([]interface{}, 0, len(data)+len(hex))
+	for i, v := range data {
+		res = append(res, v)
+		if i < len(hex) && (recordChecksum == nil || i < checksumField) {
+			res = append(res, hex[i])
+		}
+	}
+	return res
+}
+
+// offsetPrintFmt returns printFmt with the offset and name of a field
+// printed like "@%s name=" before each of the first cnt print fields.
+func offsetPrintFmt(printFmt string, cnt int) string {
+	res := ""
+	prev := 0
+	for i, v := range findPrintFields(printFmt) {
+		if i == cnt {
+			break
+		}
+		res += printFmt[prev:v[0]] + "@%s " + strings.ReplaceAll(fieldName(i), "%", "%%") + "="
+		prev = v[0]
+	}
+	return res + printFmt[prev:]
+}
+
+// addFieldOffsets returns the values of data with the input offset of each
+// field of the record raw before it, the record starting at offSet.
+func addFieldOffsets(data []interface{}, raw []byte) []interface{} {
+	var offs []interface{}
+	for _, v := range fieldSpans(offsetFields, raw) {
+		offs = append(offs, offsetString(offSet+v[0]))
+	}
+	offs = selectValues(offs)
+	res := make([]interface{}, 0, len(data)+len(offs))
+	for i, v := range data {
+		if i < len(offs) && (recordChecksum == nil || i < checksumField) {
+			res = append(res, offs[i])
+		}
+		res = append(res, v)
+	}
+	return res
+}
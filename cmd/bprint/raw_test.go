@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFieldBytes(t *testing.T) {
+	for _, c := range []struct {
+		spec string
+		in   []byte
+		want string
+	}{
+		{"S x >S", []byte{2, 1, 0, 3, 4}, "[[2 1] [3 4]]"},
+		{"z !4 L", []byte("hi\x00\x00\x05\x00\x00\x00"), "[[104 105 0] [5 0 0 0]]"},
+		{"v a2 X C", []byte{0x96, 0x01, 'a', 'b'}, "[[150 1] [97 98] [98]]"},
+		{"C S*", []byte{1, 2, 0, 3, 0}, "[[1] [2 0 3 0]]"},
+		{"S L", []byte{1, 0, 2}, "[[1 0] [2]]"},
+	} {
+		fields, _, _ := parseBinaryFmt(c.spec)
+		if res := fmt.Sprint(fieldBytes(fields, c.in)); res != c.want {
+			t.Errorf("bytes of the fields of %s wrong, got %s", c.spec, res)
+		}
+	}
+}
+
+func TestRawBytes(t *testing.T) {
+	defer func() { rawFields = nil }()
+	if res := rawPrintFmt("%d, %x%%\n", 2); res != "%d [%s], %x [%s]%%\n" {
+		t.Error("raw print format wrong, got", res)
+	}
+	if res := rawPrintFmt("%d %d", 1); res != "%d [%s] %d" {
+		t.Error("raw print format of the first field wrong, got", res)
+	}
+
+	rawFields, _, _ = parseBinaryFmt(">S C")
+	in := []byte{1, 2, 3, 4, 5}
+	if res := dumpString(">S C", rawPrintFmt("%d %d", 2), in); res != "258 [01 02] 3 [03]\n1029 [04 05]\n" {
+		t.Errorf("raw bytes of the fields wrong, got %q", res)
+	}
+}
+
+func TestFieldOffsets(t *testing.T) {
+	defer func() { offsetFields, fieldNames = nil, nil }()
+	fieldNames = []string{"flags"}
+	if res := offsetPrintFmt("%d  %x\n", 2); res != "@%s flags=%d  @%s f1=%x\n" {
+		t.Error("field offset print format wrong, got", res)
+	}
+
+	offsetFields, _, _ = parseBinaryFmt("C z")
+	in := []byte{1, 'a', 0, 2, 'b', 'c', 0}
+	res := dumpString("C z", offsetPrintFmt("%d %s", 2), in)
+	if res != "@0000000 flags=1 @0000001 f1=a\n@0000003 flags=2 @0000004 f1=bc\n" {
+		t.Errorf("field offsets wrong, got %q", res)
+	}
+}
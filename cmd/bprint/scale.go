@@ -0,0 +1,114 @@
+package main
+
+// -m maps raw integer or float fields to engineering units with a linear
+// transform value * scale + bias, e.g. "0:0.01:-40" for ADC counts of
+// 0.01 °C from -40 °C in field 0. Scaled fields are float64. -q reads
+// integer fields as fixed-point numbers, "0:15" is Q15 in field 0, which is
+// the scale 2^-15 without bias.
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gastaoss/bprint"
+)
+
+type fieldScale struct {
+	field       int
+	scale, bias float64
+}
+
+var fieldScales []fieldScale
+
+// parseFieldScales parses a scale list like "0:0.01:-40,2:0.5", the bias is
+// optional.
+func parseFieldScales(s string, fields []bprint.FieldType) (scales []fieldScale) {
+	for _, v := range strings.Split(s, ",") {
+		invalid := func() {
+			panic(specError{fmt.Errorf("Invalid scale '%s', should be like 0:0.01 or 0:0.01:-40", v)})
+		}
+		parts := strings.Split(v, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			invalid()
+		}
+		var m fieldScale
+		var err error
+		if m.field, err = strconv.Atoi(parts[0]); err != nil {
+			invalid()
+		}
+		if m.scale, err = strconv.ParseFloat(parts[1], 64); err != nil {
+			invalid()
+		}
+		if len(parts) == 3 {
+			if m.bias, err = strconv.ParseFloat(parts[2], 64); err != nil {
+				invalid()
+			}
+		}
+		if m.field < 0 || m.field >= len(fields) {
+			panic(specError{fmt.Errorf("Scale field %d out of range, record has %d fields", m.field, len(fields))})
+		}
+		if t := fields[m.field]; !t.IsInt() && t != bprint.F32 && t != bprint.F64 && t != bprint.F16 {
+			panic(specError{fmt.Errorf("Scale field %d is %s, should be a number", m.field, t.String())})
+		}
+		scales = append(scales, m)
+	}
+	return
+}
+
+// parseFixedPoint parses a fixed-point list like "0:15,1:16" of field and
+// fraction bits to the scales of those fields.
+func parseFixedPoint(s string, fields []bprint.FieldType) (scales []fieldScale) {
+	for _, v := range strings.Split(s, ",") {
+		invalid := func() {
+			panic(specError{fmt.Errorf("Invalid fixed-point field '%s', should be like 0:15", v)})
+		}
+		parts := strings.Split(v, ":")
+		if len(parts) != 2 {
+			invalid()
+		}
+		field, err := strconv.Atoi(parts[0])
+		if err != nil {
+			invalid()
+		}
+		frac, err := strconv.Atoi(parts[1])
+		if err != nil || frac < 0 || frac > 64 {
+			invalid()
+		}
+		if field < 0 || field >= len(fields) {
+			panic(specError{fmt.Errorf("Fixed-point field %d out of range, record has %d fields", field, len(fields))})
+		}
+		if t := fields[field]; !t.IsInt() {
+			panic(specError{fmt.Errorf("Fixed-point field %d is %s, should be an integer", field, t.String())})
+		}
+		for _, m := range scales {
+			if m.field == field {
+				// It would be scaled twice
+				panic(specError{fmt.Errorf("Fixed-point field %d is given twice", field)})
+			}
+		}
+		scales = append(scales, fieldScale{field: field, scale: math.Ldexp(1, -frac)})
+	}
+	return
+}
+
+// scaleTypes returns the types of the fields after scaling, scaled fields
+// become float64.
+func scaleTypes(scales []fieldScale, fields []bprint.FieldType) []bprint.FieldType {
+	res := append([]bprint.FieldType{}, fields...)
+	for _, m := range scales {
+		res[m.field] = bprint.F64
+	}
+	return res
+}
+
+// applyScales scales the fields in data in place. Integers are converted
+// with their sign before the multiply.
+func applyScales(scales []fieldScale, data []interface{}) {
+	for _, m := range scales {
+		if m.field < len(data) {
+			data[m.field] = toFloat64(data[m.field])*m.scale + m.bias
+		}
+	}
+}
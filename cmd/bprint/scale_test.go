@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/gastaoss/bprint"
+)
+
+func TestFieldScale(t *testing.T) {
+	defer func() { fieldScales = nil }()
+	fields, _, _ := parseBinaryFmt("sSC")
+	fieldScales = parseFieldScales("0:0.5:-40,1:0.01", fields)
+
+	// Field 0 is signed, the unscaled field 2 is untouched
+	if res := dumpString("sSC", "%g %g %d", []byte{0xfe, 0xff, 0x10, 0x27, 7}); res != "-41 100 7\n" {
+		t.Error("scaled fields wrong, got", res)
+	}
+	if types := scaleTypes(fieldScales, fields); types[0] != bprint.F64 || types[2] != fields[2] {
+		t.Error("scaled fields should be float64, got", types)
+	}
+
+	// Checks see the scaled value, which can't be used in expressions
+	diag := new(bytes.Buffer)
+	diagOutput = diag
+	defer func() { rangeChecks, diagOutput = nil, os.Stderr }()
+	rangeChecks = parseRangeChecks("0:-40.5..0", scaleTypes(fieldScales, fields))
+	dumpString("sSC", "%g %g %d", []byte{0xfe, 0xff, 0x10, 0x27, 7})
+	if diag.String() != "Record 1 at offset 0: field 0 value -41 out of range -40.5..0\n" {
+		t.Errorf("range check of scaled field wrong, got %q", diag.String())
+	}
+	func() {
+		defer func() {
+			if err := recover(); err == nil {
+				t.Error("scaled field should be rejected in expression")
+			}
+		}()
+		parseExpr("f0 > 0", scaleTypes(fieldScales, fields))
+	}()
+
+	for _, s := range []string{"0", "x:1", "3:1", "0:z", "0:1:2:3", "0:1:b"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("scale", s, "should be rejected")
+				}
+			}()
+			parseFieldScales(s, fields)
+		}()
+	}
+}
+
+func TestFixedPoint(t *testing.T) {
+	defer func() { fieldScales = nil }()
+	fields, _, _ := parseBinaryFmt("s>sL")
+	fields = bprint.DataFields(fields)
+	fieldScales = parseFixedPoint("0:15,1:15,2:16", fields)
+
+	// Q15 keeps the sign and the byte order, big-endian Q16.16 of 1.5
+	in := []byte{0x00, 0x40, 0xc0, 0x00, 0x00, 0x01, 0x80, 0x00}
+	if res := dumpString("s>sL", "%g %g %g", in); res != "0.5 -0.5 1.5\n" {
+		t.Error("fixed-point fields wrong, got", res)
+	}
+
+	for _, s := range []string{"0", "0:x", "0:-1", "0:65", "5:15", "0:15:1", "0:15,0:8"} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Error("fixed-point", s, "should be rejected")
+				}
+			}()
+			parseFixedPoint(s, fields)
+		}()
+	}
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("fixed-point float field should be rejected")
+		}
+	}()
+	floats, _, _ := parseBinaryFmt("f")
+	parseFixedPoint("0:15", floats)
+}
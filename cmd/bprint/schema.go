@@ -0,0 +1,139 @@
+package main
+
+// A schema file saves the binary and print format along with the options
+// changing how fields are decoded and formatted as JSON, so a spec developed
+// with command line options can be reused with -schema.
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/gastaoss/bprint"
+)
+
+type schemaField struct {
+	Type string `json:"type"`
+	Size int    `json:"size"`
+}
+
+type schema struct {
+	BinaryFmt  string `json:"binary_format"`
+	PrintFmt   string `json:"print_format,omitempty"`
+	ByteOrder  string `json:"byte_order"`
+	TimeFormat string `json:"time_format,omitempty"`
+	// Options are the values of schemaOptions given, by flag name
+	Options   map[string]string `json:"options,omitempty"`
+	Enums     []string          `json:"enums,omitempty"`
+	EnumFiles []string          `json:"enum_files,omitempty"`
+	// Fields is for information only, it's ignored when loaded
+	Fields []schemaField `json:"fields"`
+}
+
+// Flags changing how the fields are decoded or formatted, saved in a schema
+// when they aren't their default.
+var schemaOptions = []string{
+	"u", "i", "str-term", "swap-fields", "bitwidth", "cbitfields", "as-string", "string-trim",
+	"enc", "mask", "q", "m", "array", "fields", "t", "epoch", "unit",
+}
+
+// flagSet reports whether the named flag is given on the command line.
+func flagSet(name string) (set bool) {
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return
+}
+
+// writeSchema saves the current options and the parsed binary format to path.
+func writeSchema(path string, formatField []bprint.FieldType) {
+	sc := schema{
+		BinaryFmt:  opt.binaryFmt,
+		PrintFmt:   opt.printFmt,
+		ByteOrder:  "little",
+		TimeFormat: opt.timeFormat,
+		Enums:      opt.enums,
+		EnumFiles:  opt.enumFiles,
+		Fields:     make([]schemaField, len(formatField)),
+	}
+	if opt.nativeEndian {
+		// The byte order of the machine reading the data, not this one
+		sc.ByteOrder = "native"
+	} else if byteOrder == binary.BigEndian {
+		sc.ByteOrder = "big"
+	}
+	for _, name := range schemaOptions {
+		if f := flag.Lookup(name); f.Value.String() != f.DefValue {
+			if sc.Options == nil {
+				sc.Options = make(map[string]string)
+			}
+			sc.Options[name] = f.Value.String()
+		}
+	}
+	for i, v := range formatField {
+		sc.Fields[i] = schemaField{v.String(), v.Size()}
+	}
+
+	buf, err := json.MarshalIndent(&sc, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	buf = append(buf, '\n')
+	if err := os.WriteFile(path, buf, 0644); err != nil {
+		panic(fmt.Sprintf("While writing schema: %v", err))
+	}
+}
+
+// readSchema loads options from the schema file at path. Options given on
+// the command line override those in the file, which override the formats
+// of -f and of the environment as it's loaded first.
+func readSchema(path string) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		panic(fmt.Sprintf("While reading schema: %v", err))
+	}
+	var sc schema
+	if err := json.Unmarshal(buf, &sc); err != nil {
+		panic(specError{fmt.Errorf("Schema file %s error: %v", path, err)})
+	}
+
+	if opt.binaryFmt == "" {
+		opt.binaryFmt = sc.BinaryFmt
+	}
+	if opt.printFmt == "" {
+		opt.printFmt = sc.PrintFmt
+	}
+	if !opt.littleEndian && !opt.bigEndian && !opt.nativeEndian {
+		switch sc.ByteOrder {
+		case "big":
+			opt.bigEndian = true
+		case "native":
+			opt.nativeEndian = true
+		case "little", "":
+		default:
+			panic(specError{fmt.Errorf("Schema file %s error: unknown byte order %s", path, sc.ByteOrder)})
+		}
+	}
+	if sc.TimeFormat != "" && !flagSet("time-format") {
+		opt.timeFormat = sc.TimeFormat
+	}
+	for name, v := range sc.Options {
+		if f := flag.Lookup(name); f == nil {
+			panic(specError{fmt.Errorf("Schema file %s error: unknown option %s", path, name)})
+		} else if !flagSet(name) {
+			if err := f.Value.Set(v); err != nil {
+				panic(specError{fmt.Errorf("Schema file %s error: option %s: %v", path, name, err)})
+			}
+		}
+	}
+	if !flagSet("enum") {
+		opt.enums = append(opt.enums, sc.Enums...)
+	}
+	if !flagSet("enum-file") {
+		opt.enumFiles = append(opt.enumFiles, sc.EnumFiles...)
+	}
+}
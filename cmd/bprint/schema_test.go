@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSchemaRoundTrip(t *testing.T) {
+	defer func() {
+		opt.binaryFmt, opt.printFmt, opt.bigEndian = "", "", false
+		byteOrder = binary.LittleEndian
+	}()
+
+	in := []byte{0, 1, 0, 0, 0, 2, 0xff}
+	opt.binaryFmt, opt.printFmt, opt.bigEndian = "sL", "%d %x", true
+	selectByteOrder()
+	want := dumpString(opt.binaryFmt, opt.printFmt, in)
+
+	path := filepath.Join(t.TempDir(), "schema.json")
+	formatField, _, _ := parseBinaryFmt(opt.binaryFmt)
+	opt.printFmt = "%d %x"
+	writeSchema(path, formatField)
+
+	opt.binaryFmt, opt.printFmt, opt.bigEndian = "", "", false
+	byteOrder = binary.LittleEndian
+	readSchema(path)
+	selectByteOrder()
+	if opt.binaryFmt != "sL" || opt.printFmt != "%d %x" || byteOrder != binary.BigEndian {
+		t.Error("options loaded from schema wrong", opt.binaryFmt, opt.printFmt, byteOrder)
+	}
+	if res := dumpString(opt.binaryFmt, opt.printFmt, in); res != want {
+		t.Error("output with loaded schema", res, "differs from", want)
+	}
+}
+
+func TestSchemaOptions(t *testing.T) {
+	defer func() {
+		opt.binaryFmt, opt.printFmt, opt.nativeEndian, opt.bigEndian = "", "", false, false
+		opt.scale, opt.fixedPoint, opt.fieldSelect, opt.enums, opt.forceUnsigned = "", "", "", nil, false
+	}()
+	opt.binaryFmt, opt.printFmt, opt.nativeEndian = "sSC", "%d %d %d", true
+	opt.scale, opt.fixedPoint, opt.fieldSelect = "0:0.5", "1:8", "0,1"
+	opt.enums, opt.forceUnsigned = stringList{"2=0:OK,1:WARN"}, true
+	path := filepath.Join(t.TempDir(), "schema.json")
+	formatField, _, _ := parseBinaryFmt(opt.binaryFmt)
+	writeSchema(path, formatField)
+
+	opt.binaryFmt, opt.printFmt, opt.nativeEndian = "", "", false
+	opt.scale, opt.fixedPoint, opt.fieldSelect, opt.enums, opt.forceUnsigned = "", "", "", nil, false
+	// An option given on the command line wins over the schema
+	if err := flag.Set("m", "0:2"); err != nil {
+		t.Fatal(err)
+	}
+	readSchema(path)
+	if opt.binaryFmt != "sSC" || !opt.nativeEndian || opt.bigEndian {
+		t.Error("format and native byte order not loaded from schema", opt.binaryFmt, opt.nativeEndian)
+	}
+	if opt.scale != "0:2" || opt.fixedPoint != "1:8" || opt.fieldSelect != "0,1" || !opt.forceUnsigned {
+		t.Error("decoding options loaded from schema wrong", opt.scale, opt.fixedPoint, opt.fieldSelect, opt.forceUnsigned)
+	}
+	if !reflect.DeepEqual([]string(opt.enums), []string{"2=0:OK,1:WARN"}) {
+		t.Error("enums loaded from schema wrong", opt.enums)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+// With -stats or -T, records are not printed. Instead the count, min, max,
+// sum and mean of each numeric field are accumulated and printed as a table
+// at the end. Integers are summed exactly, whatever their size and sign.
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"text/tabwriter"
+)
+
+type fieldStats struct {
+	cnt           int64
+	min, max, sum *big.Rat
+}
+
+var stats []fieldStats
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int8, int16, int32, int64, uint8, uint16, uint32, uint64, *big.Int:
+		return true
+	}
+	return false
+}
+
+// toBigRat converts an integer or float to a big.Rat, it's nil for other
+// values and for a float NaN or infinity.
+func toBigRat(v interface{}) *big.Rat {
+	switch v := v.(type) {
+	case float32:
+		return new(big.Rat).SetFloat64(float64(v))
+	case float64:
+		return new(big.Rat).SetFloat64(v)
+	}
+	if !isNumber(v) {
+		return nil
+	}
+	return new(big.Rat).SetInt(toBigInt(v))
+}
+
+// accumulateStats adds the integer and float values in data to stats.
+func accumulateStats(data []interface{}) {
+	for len(stats) < len(data) {
+		stats = append(stats, fieldStats{})
+	}
+	for i, v := range data {
+		r := toBigRat(v)
+		if r == nil {
+			continue
+		}
+		st := &stats[i]
+		if st.cnt == 0 {
+			st.min, st.max, st.sum = r, r, new(big.Rat)
+		} else if r.Cmp(st.min) < 0 {
+			st.min = r
+		} else if r.Cmp(st.max) > 0 {
+			st.max = r
+		}
+		st.sum.Add(st.sum, r)
+		st.cnt++
+	}
+}
+
+func ratString(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	f, _ := r.Float64()
+	return fmt.Sprintf("%g", f)
+}
+
+// printStats prints a table of stats for the numeric fields to w.
+func printStats(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "field\tcount\tmin\tmax\tsum\tmean")
+	for i, st := range stats {
+		if st.cnt == 0 {
+			continue
+		}
+		mean := new(big.Rat).Quo(st.sum, new(big.Rat).SetInt64(st.cnt))
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\n", fieldName(i), st.cnt,
+			ratString(st.min), ratString(st.max), ratString(st.sum), ratString(mean))
+	}
+	tw.Flush()
+}
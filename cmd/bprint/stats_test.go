@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	defer func() { opt.stats, stats = false, nil }()
+	opt.stats = true
+
+	in := []byte{
+		1, 0xff, 0xff, 0xff, 0, 0, 0,
+		2, 0x10, 0, 0x20, 0, 0, 0,
+		4, 0xfe, 0xff, 0x40, 0, 0, 0,
+	}
+	res := dumpString("CskC", "%d %d %s %d", in)
+	want := "field  count  min  max  sum  mean\n" +
+		"f0     3      1    4    7    2.3333333333333335\n" +
+		"f1     3      -2   16   13   4.333333333333333\n" +
+		"f3     3      0    0    0    0\n"
+	if res != want {
+		t.Errorf("stats output wrong, got\n%s", res)
+	}
+}
+
+func TestStatsFloatAndUint64(t *testing.T) {
+	defer func() { opt.stats, stats = false, nil }()
+	opt.stats = true
+
+	in := []byte{
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0xc0, 0x3f,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0xc0, 0x7f,
+		0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x20, 0xc0,
+	}
+	// The NaN of the 2nd record is left out of the float stats
+	res := dumpString("Qf", "%d %g", in)
+	want := "field  count  min   max                   sum                   mean\n" +
+		"f0     3      1     18446744073709551615  36893488147419103231  1.2297829382473034e+19\n" +
+		"f1     2      -2.5  1.5                   -1                    -0.5\n"
+	if res != want {
+		t.Errorf("stats output wrong, got\n%s", res)
+	}
+}
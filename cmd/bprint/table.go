@@ -0,0 +1,41 @@
+package main
+
+// With -table, records are buffered and printed at EOF as a table with a
+// header of field names, so columns can be aligned over all records.
+
+import (
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	tableHeader []string
+	tableRows   [][]string
+)
+
+// addTableRow formats the cells of a record and buffers them for printTable.
+func addTableRow(data []interface{}) {
+	names, values := recordCells(data)
+	size := 0
+	for _, v := range values {
+		size += len(v)
+	}
+	// Count the string headers of the cells too
+	retainMem(size+len(values)*16, "-table")
+	if len(names) > len(tableHeader) {
+		tableHeader = names
+	}
+	tableRows = append(tableRows, values)
+}
+
+// printTable prints the buffered records to w with aligned columns.
+func printTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	io.WriteString(tw, strings.Join(tableHeader, "\t")+"\n")
+	for _, row := range tableRows {
+		io.WriteString(tw, strings.Join(row, "\t")+"\n")
+	}
+	tw.Flush()
+	tableHeader, tableRows = nil, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestTable(t *testing.T) {
+	defer func() { opt.table, opt.printRecordCnt = false, false }()
+	opt.table, opt.printRecordCnt = true, true
+
+	res := dumpString("cS", "%d %d", []byte{1, 100, 0, 20, 2, 0})
+	want := "record  f0  f1\n" +
+		"1       1   100\n" +
+		"2       20  2\n"
+	if res != want {
+		t.Error("table output wrong, got\n" + res)
+	}
+}
+
+func TestMaxMem(t *testing.T) {
+	defer func() { opt.table, maxMem, memUsed, tableHeader, tableRows = false, 0, 0, nil, nil }()
+	opt.table, maxMem = true, 64
+
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("-table beyond -max-mem should abort")
+		}
+	}()
+	dumpString("C", "%d", make([]byte, 10))
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		s    string
+		size int64
+	}{
+		{"4096", 4096},
+		{"64K", 64 << 10},
+		{"2m", 2 << 20},
+		{"1G", 1 << 30},
+	}
+	for _, tt := range tests {
+		if size := parseByteSize(tt.s); size != tt.size {
+			t.Error(tt.s, "parsed as", size, "expected", tt.size)
+		}
+	}
+}
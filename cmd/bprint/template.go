@@ -0,0 +1,79 @@
+package main
+
+// With -tmpl or -tmpl-file, each record is printed by executing a Go
+// text/template. The template gets a map of the fields by name like .f0,
+// with .offset and .record for the offset and record count. Functions hex,
+// ascii and time are added to format values. Fields missing in a partial
+// record at EOF print as <no value>.
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+var recordTmpl *template.Template
+
+var tmplFuncs = template.FuncMap{
+	"hex": func(v interface{}) string {
+		return fmt.Sprintf("%x", v)
+	},
+	"ascii": asciiString,
+	"time": func(v interface{}) string {
+		return formatTimestamp(v).(string)
+	},
+}
+
+// asciiString returns the bytes of a string, or the low byte of an integer,
+// with bytes which are not printable ASCII replaced by '.'.
+func asciiString(v interface{}) string {
+	var b []byte
+	if s, ok := v.(string); ok {
+		b = []byte(s)
+	} else {
+		b = []byte{byte(toInt64(v))}
+	}
+	for i, c := range b {
+		if c < ' ' || c > '~' {
+			b[i] = '.'
+		}
+	}
+	return string(b)
+}
+
+// parseTemplate parses an inline -tmpl, a newline is added after each
+// record.
+func parseTemplate(text string) *template.Template {
+	return newTemplate("tmpl", text+"\n")
+}
+
+// readTemplate parses the template in the file at path, printed as is.
+func readTemplate(path string) *template.Template {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		panic(fmt.Sprintf("While reading template: %v", err))
+	}
+	return newTemplate(path, string(buf))
+}
+
+func newTemplate(name, text string) *template.Template {
+	t, err := template.New(name).Funcs(tmplFuncs).Parse(text)
+	if err != nil {
+		panic(specError{fmt.Errorf("Template error: %v", err)})
+	}
+	return t
+}
+
+func printTemplate(data []interface{}) {
+	rec := map[string]interface{}{
+		"offset": offSet,
+		"record": recordCnt,
+	}
+	for i, v := range data {
+		rec[fieldName(i)] = v
+	}
+	if err := recordTmpl.Execute(output, rec); err != nil {
+		panic(fmt.Sprintf("Template error: %v", strings.TrimPrefix(err.Error(), "template: ")))
+	}
+}
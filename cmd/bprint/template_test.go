@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rec.tmpl")
+	text := "record {{.record}} at {{.offset}}\n" +
+		"  id   {{.f0}} ({{hex .f0}})\n" +
+		"  tag  {{ascii .f1}}\n" +
+		"  time {{time .f2}}\n"
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { recordTmpl = nil }()
+	recordTmpl = readTemplate(path)
+
+	in := []byte{0xff, 0, 'a', 1, 0, 0x00, 0xe1, 0xf5, 0x05}
+	res := dumpString("SzL", "%d %s %d", in)
+	want := "record 1 at 0\n" +
+		"  id   255 (ff)\n" +
+		"  tag  a.\n" +
+		"  time 1973-03-03T09:46:40Z\n"
+	if res != want {
+		t.Error("template file output wrong, got\n" + res)
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	defer func() { recordTmpl = nil }()
+	recordTmpl = parseTemplate("{{.f0}}-{{.f1}}")
+
+	if res := dumpString("CC", "%d %d", []byte{1, 2, 3, 4}); res != "1-2\n3-4\n" {
+		t.Error("inline template output wrong, got", res)
+	}
+
+	if res := dumpString("CC", "%d %d", []byte{1, 2, 3}); res != "1-2\n3-<no value>\n" {
+		t.Error("template output of partial record wrong, got", res)
+	}
+
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("template with syntax error should be rejected")
+		}
+	}()
+	parseTemplate("{{.f0")
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package main
+
+import "os"
+
+// terminalWidth returns 0, the terminal size isn't known on this system.
+func terminalWidth(f *os.File) int {
+	return 0
+}
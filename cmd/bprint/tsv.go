@@ -0,0 +1,29 @@
+package main
+
+// With -tsv, records are printed as tab separated values, one line per
+// record. There is no quoting, tabs, newlines and backslashes in values are
+// escaped as \t, \n and \\ instead.
+
+import (
+	"io"
+	"strings"
+)
+
+var tsvEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+func printTSVLine(cells []string) {
+	for i, v := range cells {
+		cells[i] = tsvEscaper.Replace(v)
+	}
+	io.WriteString(output, strings.Join(cells, "\t")+"\n")
+}
+
+// printTSVHeader prints the names of the offset, count and fieldCnt fields.
+func printTSVHeader(fieldCnt int) {
+	printTSVLine(cellNames(fieldCnt))
+}
+
+func printTSV(data []interface{}) {
+	_, values := recordCells(data)
+	printTSVLine(values)
+}
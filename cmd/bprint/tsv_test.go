@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestTSV(t *testing.T) {
+	defer func() { opt.tsv, opt.header, opt.printRecordCnt = false, false, false }()
+	opt.tsv, opt.header, opt.printRecordCnt = true, true, true
+
+	in := []byte{1, 'a', '\t', 'b', 0, 0xff, 'c', '\n', 0}
+	res := dumpString("Cz", "%d %s", in)
+	want := "record\tf0\tf1\n" +
+		"1\t1\ta\\tb\n" +
+		"2\t255\tc\\n\n"
+	if res != want {
+		t.Errorf("TSV output wrong, got %q", res)
+	}
+}
@@ -0,0 +1,524 @@
+package bprint
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+)
+
+// Color is the value of RGB and RGBA fields, printed as a hex color like
+// #ff0000, or #ff000080 with alpha.
+type Color struct {
+	Bytes [4]byte
+	Alpha bool
+}
+
+func (c Color) String() string {
+	if c.Alpha {
+		return fmt.Sprintf("#%02x%02x%02x%02x", c.Bytes[0], c.Bytes[1], c.Bytes[2], c.Bytes[3])
+	}
+	return fmt.Sprintf("#%02x%02x%02x", c.Bytes[0], c.Bytes[1], c.Bytes[2])
+}
+
+// UUID is the value of GUID fields, in RFC 4122 byte order. It's printed in
+// the canonical form like 00112233-4455-6677-8899-aabbccddeeff.
+type UUID [16]byte
+
+func (u UUID) String() string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+// guid converts the 16 bytes of a Microsoft GUID to a UUID, the first three
+// fields are little-endian whatever the byte order of the record.
+func guid(b []byte) (u UUID) {
+	copy(u[:], b)
+	u[0], u[1], u[2], u[3] = b[3], b[2], b[1], b[0]
+	u[4], u[5] = b[5], b[4]
+	u[6], u[7] = b[7], b[6]
+	return
+}
+
+// IOError is an error reading the input of a Decoder. The end of input is
+// not one, it's io.EOF or io.ErrUnexpectedEOF as usual.
+type IOError struct {
+	Err error
+}
+
+func (e *IOError) Error() string {
+	return "read error: " + e.Err.Error()
+}
+
+func (e *IOError) Unwrap() error {
+	return e.Err
+}
+
+// ioErrorReader wraps the read errors of r other than the end of input in
+// IOError.
+type ioErrorReader struct {
+	r io.Reader
+}
+
+func (er ioErrorReader) Read(p []byte) (int, error) {
+	n, err := er.r.Read(p)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		err = &IOError{err}
+	}
+	return n, err
+}
+
+// countReader counts the bytes read from r.
+type countReader struct {
+	r io.Reader
+	n int
+}
+
+func (cr *countReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += n
+	return n, err
+}
+
+// Decoder reads records of fields from r. A Decoder only keeps its own
+// state, different Decoders can be used concurrently.
+type Decoder struct {
+	r      io.Reader
+	fields []FieldType
+	order  binary.ByteOrder
+	buf    [16]byte
+
+	// Counts the bytes read, for the position of ALIGN in the record
+	cr *countReader
+
+	// Records without a z string, LEB128 number, array or ALIGN have a
+	// fixed size, they are read at once into rec.
+	fixed bool
+	rec   []byte
+
+	// Byte terminating STRZ strings, 0 by default
+	StrTerm byte
+
+	// Names of the values used as keys by DecodeMap, values without a name
+	// use their position like f0, f1, ...
+	Names []string
+}
+
+// NewDecoder returns a Decoder reading records of fields from r in the
+// given byte order, until a BIG or LITTLE marker in fields.
+func NewDecoder(r io.Reader, fields []FieldType, order binary.ByteOrder) *Decoder {
+	cr := &countReader{r: ioErrorReader{r}}
+	d := &Decoder{r: cr, cr: cr, fields: fields, order: order, fixed: true}
+	// Up to the furthest byte read, BACK moves back
+	pos, size := 0, 0
+	for _, v := range fields {
+		if v == STRZ || v == ULEB || v == SLEB || v == array || v == ALIGN {
+			d.fixed = false
+		}
+		if v == BACK {
+			pos--
+		} else {
+			pos += v.Size()
+		}
+		if pos > size {
+			size = pos
+		}
+	}
+	if d.fixed {
+		d.rec = make([]byte, size)
+	}
+	return d
+}
+
+// Decode reads one record and returns its values, skipped bytes don't take
+// a place in the values. The error is io.EOF only if no byte was read, and
+// an *IOError if reading the input failed.
+func (d *Decoder) Decode() ([]interface{}, error) {
+	data := make([]interface{}, len(d.fields))
+	n, err := d.DecodeInto(data)
+	return data[:n], err
+}
+
+// DecodeInto reads one record into data, which must have a place for each
+// field, and returns the number of fields read. On a string cut short by
+// the end of input the partial string is kept and counted in n, the same
+// for the elements of an array read before an element cut short.
+func (d *Decoder) DecodeInto(data []interface{}) (n int, err error) {
+	if d.fixed {
+		return d.decodeRecord(data)
+	}
+	// Changed by the byte order markers for the rest of the record
+	order := d.order
+	start := d.cr.n
+	for i, v := range d.fields {
+		size := v.Size()
+		switch v {
+		case I8, I16, I24, I32, I64, U8, U16, U24, U32, U64, F32, F64, F16, I128, U128:
+			if _, err = io.ReadFull(d.r, d.buf[:size]); err != nil {
+				return
+			}
+			data[n] = number(v, order, d.buf[:])
+
+		case RGB, RGBA:
+			c := Color{Alpha: v == RGBA}
+			if _, err = io.ReadFull(d.r, c.Bytes[:size]); err != nil {
+				return
+			}
+			data[n] = c
+
+		case GUID:
+			if _, err = io.ReadFull(d.r, d.buf[:size]); err != nil {
+				return
+			}
+			data[n] = guid(d.buf[:size])
+
+		case SKIP:
+			if _, err = io.ReadFull(d.r, d.buf[:size]); err != nil {
+				return
+			}
+			// Not a field
+			continue
+
+		case STR:
+			for j := i + 1; j < len(d.fields) && d.fields[j] == STRTAIL; j++ {
+				size++
+			}
+			str := make([]byte, size)
+			if _, err = io.ReadFull(d.r, str); err != nil {
+				return
+			}
+			data[n] = string(str)
+
+		case STRTAIL, ALIGNTAIL:
+			// Read with the STR or ALIGN before it
+			continue
+
+		case ALIGN:
+			size = 1
+			for j := i + 1; j < len(d.fields) && d.fields[j] == ALIGNTAIL; j++ {
+				size++
+			}
+			pad := (size - (d.cr.n-start)%size) % size
+			if _, err = io.CopyN(io.Discard, d.r, int64(pad)); err == io.EOF {
+				// Bytes of the record were read before
+				err = io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return
+			}
+			// Not a field
+			continue
+
+		case BIG:
+			order = binary.BigEndian
+			continue
+		case LITTLE:
+			order = binary.LittleEndian
+			continue
+
+		case STRZ:
+			var str string
+			str, err = readStrZ(d.r, d.StrTerm)
+			if err == io.ErrUnexpectedEOF {
+				// Keep the string without terminator
+				data[n] = str
+				n++
+				return
+			}
+			if err != nil {
+				return
+			}
+			data[n] = str
+
+		case ULEB, SLEB:
+			var u uint64
+			if u, err = readLEB128(d.r, d.buf[:], v == SLEB); err != nil {
+				return
+			}
+			if v == SLEB {
+				data[n] = int64(u)
+			} else {
+				data[n] = u
+			}
+
+		case array:
+			var list []interface{}
+			list, err = d.decodeList(d.fields[i+1:], order)
+			if err == io.EOF && len(list) == 0 && n == 0 {
+				// No record left
+				return
+			}
+			data[n] = list
+			n++
+			if err == io.EOF {
+				err = nil
+			}
+			// The rest of the fields are the element
+			return
+
+		default:
+			return n, fmt.Errorf("data field type %v can't be decoded", v)
+		}
+		n++
+	}
+	return
+}
+
+// decodeRecord reads a fixed size record at once, then decodes the fields
+// from its bytes. The error is the same as reading field by field: io.EOF
+// if the input ends between fields, io.ErrUnexpectedEOF inside a field.
+func (d *Decoder) decodeRecord(data []interface{}) (n int, err error) {
+	cnt, err := io.ReadFull(d.r, d.rec)
+	rec := d.rec[:cnt]
+	order := d.order
+	off := 0
+	for i, v := range d.fields {
+		size := v.Size()
+		switch v {
+		case STRTAIL:
+			// Read with the STR before it
+			continue
+		case BACK:
+			if off == 0 {
+				return n, fmt.Errorf("data field error: 'X' moves back before the start of the record")
+			}
+			off--
+			continue
+		case STR:
+			for j := i + 1; j < len(d.fields) && d.fields[j] == STRTAIL; j++ {
+				size++
+			}
+		}
+		if off+size > len(rec) {
+			if err == io.ErrUnexpectedEOF && off == len(rec) {
+				err = io.EOF
+			}
+			return
+		}
+		b := rec[off : off+size]
+		off += size
+		switch v {
+		case I8, I16, I24, I32, I64, U8, U16, U24, U32, U64, F32, F64, F16, I128, U128:
+			data[n] = number(v, order, b)
+
+		case RGB, RGBA:
+			c := Color{Alpha: v == RGBA}
+			copy(c.Bytes[:], b)
+			data[n] = c
+
+		case GUID:
+			data[n] = guid(b)
+
+		case STR:
+			data[n] = string(b)
+
+		case SKIP:
+			// Not a field
+			continue
+
+		case BIG:
+			order = binary.BigEndian
+			continue
+		case LITTLE:
+			order = binary.LittleEndian
+			continue
+
+		default:
+			return n, fmt.Errorf("data field type %v can't be decoded", v)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// decodeList reads elements of fields until the end of input, the error is
+// io.EOF if the input ends after an element.
+func (d *Decoder) decodeList(fields []FieldType, order binary.ByteOrder) (list []interface{}, err error) {
+	elem := NewDecoder(d.r, fields, order)
+	// The reader of d wraps the read errors and counts the bytes already
+	elem.r, elem.cr = d.r, d.cr
+	elem.StrTerm = d.StrTerm
+	list = make([]interface{}, 0)
+	for {
+		var v []interface{}
+		if v, err = elem.Decode(); err != nil {
+			return
+		}
+		if len(v) == 1 {
+			list = append(list, v[0])
+		} else {
+			list = append(list, v)
+		}
+	}
+}
+
+// readLEB128 reads a LEB128 number from r, using b to read a byte at a
+// time. A signed number is sign extended from its last byte. The error is
+// io.EOF if no byte was read, io.ErrUnexpectedEOF if the input ends before
+// a byte without the continuation bit. A number with bits beyond 64 is an
+// error.
+func readLEB128(r io.Reader, b []byte, signed bool) (v uint64, err error) {
+	var shift uint
+	for {
+		if _, err = io.ReadFull(r, b[:1]); err != nil {
+			if err == io.EOF && shift > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+		if shift >= 64 {
+			return 0, fmt.Errorf("LEB128 number longer than 64 bits")
+		}
+		if shift == 63 {
+			// Only bit 63 is left, the other bits of the last byte must
+			// be 0, or copies of bit 63 for a signed number
+			if p := b[0] & 0x7f; (!signed && p > 1) || (signed && p != 0 && p != 0x7f) {
+				return 0, fmt.Errorf("LEB128 number doesn't fit in 64 bits")
+			}
+		}
+		v |= uint64(b[0]&0x7f) << shift
+		shift += 7
+		if b[0]&0x80 == 0 {
+			break
+		}
+	}
+	if signed && shift < 64 && b[0]&0x40 != 0 {
+		v |= ^uint64(0) << shift
+	}
+	return v, nil
+}
+
+// number converts the bytes in b to a value of type t, in the given byte
+// order.
+func number(t FieldType, order binary.ByteOrder, b []byte) interface{} {
+	switch t {
+	case I8:
+		return int8(b[0])
+	case I16:
+		return int16(order.Uint16(b))
+	case I24:
+		// Shift the sign bit to bit 31 and back to sign extend
+		return int32(uint24(order, b)<<8) >> 8
+	case I32:
+		return int32(order.Uint32(b))
+	case I64:
+		return int64(order.Uint64(b))
+
+	case U8:
+		return b[0]
+	case U16:
+		return order.Uint16(b)
+	case U24:
+		return uint24(order, b)
+	case U32:
+		return order.Uint32(b)
+	case U64:
+		return order.Uint64(b)
+
+	case F32:
+		return math.Float32frombits(order.Uint32(b))
+	case F64:
+		return math.Float64frombits(order.Uint64(b))
+	case F16:
+		return float16(order.Uint16(b))
+
+	case I128, U128:
+		return int128(order, b[:16], t == I128)
+	}
+	return nil
+}
+
+// int128 converts the 16 bytes in b to a big.Int, in two's complement if
+// signed.
+func int128(order binary.ByteOrder, b []byte, signed bool) *big.Int {
+	be := make([]byte, len(b))
+	copy(be, b)
+	if order.Uint16([]byte{0, 1}) != 1 {
+		// Little-endian
+		for i, j := 0, len(be)-1; i < j; i, j = i+1, j-1 {
+			be[i], be[j] = be[j], be[i]
+		}
+	}
+	n := new(big.Int).SetBytes(be)
+	if signed && be[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), 128))
+	}
+	return n
+}
+
+// float16 converts IEEE 754 half precision bits to a float32, which holds
+// all its values exactly.
+func float16(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+	switch {
+	case exp == 0x1f:
+		// Infinity or NaN, keeping the NaN payload
+		return math.Float32frombits(sign | 0xff<<23 | frac<<13)
+	case exp == 0 && frac == 0:
+		return math.Float32frombits(sign)
+	case exp == 0:
+		// Subnormal, normalized as float32 has a wider exponent
+		exp = 127 - 15 + 1
+		for frac&0x400 == 0 {
+			frac <<= 1
+			exp--
+		}
+		return math.Float32frombits(sign | exp<<23 | (frac&0x3ff)<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | frac<<13)
+}
+
+// DecodeMap reads one record like Decode, and returns the values keyed by
+// their name.
+func (d *Decoder) DecodeMap() (map[string]interface{}, error) {
+	data, err := d.Decode()
+	m := make(map[string]interface{}, len(data))
+	for i, v := range data {
+		m[d.name(i)] = v
+	}
+	return m, err
+}
+
+// name returns the key of the value at index i.
+func (d *Decoder) name(i int) string {
+	if i < len(d.Names) && d.Names[i] != "" {
+		return d.Names[i]
+	}
+	return fmt.Sprintf("f%d", i)
+}
+
+// uint24 converts the first 3 bytes in b to a uint32.
+func uint24(order binary.ByteOrder, b []byte) uint32 {
+	if order.Uint16([]byte{0, 1}) == 1 {
+		// Big-endian
+		return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+	}
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+// Decode reads one record of fields from r, see Decoder.Decode.
+func Decode(r io.Reader, fields []FieldType, order binary.ByteOrder) ([]interface{}, error) {
+	return NewDecoder(r, fields, order).Decode()
+}
+
+// readStrZ reads a string terminated by term. The terminator is consumed but
+// not included in the string.
+func readStrZ(r io.Reader, term byte) (string, error) {
+	var str []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			if err == io.EOF && len(str) != 0 {
+				// EOF before terminator
+				return string(str), io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		if b[0] == term {
+			return string(str), nil
+		}
+		str = append(str, b[0])
+	}
+}
@@ -0,0 +1,443 @@
+package bprint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	fields, _, _ := ParseSpec("csxLfkz")
+	in := []byte{
+		0xff,
+		0x00, 0x01,
+		0xaa,
+		0x00, 0x00, 0x01, 0x00,
+		0x3f, 0x80, 0x00, 0x00,
+		0xff, 0x00, 0x80,
+		'h', 'i', 0,
+	}
+	data, err := Decode(bytes.NewReader(in), fields, binary.BigEndian)
+	expect := []interface{}{int8(-1), int16(1), uint32(256), float32(1),
+		Color{Bytes: [4]byte{0xff, 0x00, 0x80}}, "hi"}
+	if err != nil || !reflect.DeepEqual(data, expect) {
+		t.Error("record not decoded correctly, got", data, err)
+	}
+	if s := data[4].(Color).String(); s != "#ff0080" {
+		t.Error("color should print as #ff0080, got", s)
+	}
+
+	if _, err := Decode(bytes.NewReader(nil), fields, binary.BigEndian); err != io.EOF {
+		t.Error("empty input should give io.EOF, got", err)
+	}
+	data, err = Decode(bytes.NewReader([]byte{1, 2}), fields, binary.BigEndian)
+	if err != io.ErrUnexpectedEOF || !reflect.DeepEqual(data, []interface{}{int8(1)}) {
+		t.Error("short record should keep the fields read, got", data, err)
+	}
+}
+
+func TestDecode24Bit(t *testing.T) {
+	fields, size, _ := ParseSpec("mMm")
+	if size != 9 {
+		t.Error("24-bit fields should be 3 bytes, record size is", size)
+	}
+	in := []byte{0xff, 0xff, 0xff, 0x01, 0x02, 0x03, 0x00, 0x00, 0x80}
+	data, err := Decode(bytes.NewReader(in), fields, binary.LittleEndian)
+	if err != nil || !reflect.DeepEqual(data, []interface{}{int32(-1), uint32(0x030201), int32(-0x800000)}) {
+		t.Error("little-endian 24-bit fields decoded wrong, got", data, err)
+	}
+	data, _ = Decode(bytes.NewReader(in), fields, binary.BigEndian)
+	if !reflect.DeepEqual(data, []interface{}{int32(-1), uint32(0x010203), int32(0x80)}) {
+		t.Error("big-endian 24-bit fields decoded wrong, got", data)
+	}
+}
+
+func TestDecodeToEnd(t *testing.T) {
+	fields, _, _ := ParseSpec("C S*")
+	dec := NewDecoder(bytes.NewReader([]byte{7, 1, 0, 2, 0, 3}), fields, binary.LittleEndian)
+	data, err := dec.Decode()
+	expect := []interface{}{uint8(7), []interface{}{uint16(1), uint16(2)}}
+	if err != io.ErrUnexpectedEOF || !reflect.DeepEqual(data, expect) {
+		t.Error("elements before a partial one should be kept, got", data, err)
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Error("input should be consumed by the first record, got", err)
+	}
+
+	fields, _, _ = ParseSpec("(C c)*")
+	dec = NewDecoder(bytes.NewReader([]byte{1, 0xff, 2, 0xfe}), fields, binary.LittleEndian)
+	data, err = dec.Decode()
+	expect = []interface{}{[]interface{}{
+		[]interface{}{uint8(1), int8(-1)}, []interface{}{uint8(2), int8(-2)}}}
+	if err != nil || !reflect.DeepEqual(data, expect) {
+		t.Error("group elements not decoded as lists, got", data, err)
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Error("an empty array at EOF should give io.EOF, got", err)
+	}
+}
+
+func TestDecodeFloat16(t *testing.T) {
+	fields, size, _ := ParseSpec("h7")
+	if size != 14 {
+		t.Error("float16 fields should be 2 bytes, record size is", size)
+	}
+	in := []byte{0x3c, 0x00, 0xc0, 0x00, 0x7b, 0xff, 0x00, 0x01, 0x7c, 0x00, 0x80, 0x00, 0x7e, 0x00}
+	data, err := Decode(bytes.NewReader(in), fields, binary.BigEndian)
+	expect := []float32{1, -2, 65504, float32(math.Ldexp(1, -24)), float32(math.Inf(1)), 0}
+	if err != nil || len(data) != 7 {
+		t.Fatal("float16 fields not decoded, got", data, err)
+	}
+	for i, v := range expect {
+		if data[i] != v {
+			t.Error("float16 field", i, "should be", v, "got", data[i])
+		}
+	}
+	if !math.Signbit(float64(data[5].(float32))) || !math.IsNaN(float64(data[6].(float32))) {
+		t.Error("float16 -0 and NaN decoded wrong, got", data[5], data[6])
+	}
+	data, _ = Decode(bytes.NewReader([]byte{0x00, 0x3c}), fields[:1], binary.LittleEndian)
+	if data[0] != float32(1) {
+		t.Error("little-endian float16 decoded wrong, got", data[0])
+	}
+}
+
+func TestDecode128Bit(t *testing.T) {
+	fields, size, _ := ParseSpec("oO<o")
+	if size != 48 {
+		t.Error("128-bit fields should be 16 bytes, record size is", size)
+	}
+	in := bytes.Repeat([]byte{0xff}, 32)
+	// 2^64 + 150 in little-endian
+	in = append(in, 0x96, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0)
+	data, err := Decode(bytes.NewReader(in), fields, binary.BigEndian)
+	maxU128, _ := new(big.Int).SetString("340282366920938463463374607431768211455", 10)
+	third, _ := new(big.Int).SetString("18446744073709551766", 10)
+	if err != nil || len(data) != 3 || data[0].(*big.Int).Int64() != -1 ||
+		data[1].(*big.Int).Cmp(maxU128) != 0 || data[2].(*big.Int).Cmp(third) != 0 {
+		t.Error("128-bit fields not decoded correctly, got", data, err)
+	}
+	in[16] = 0x7f
+	data, _ = Decode(bytes.NewReader(in), fields, binary.BigEndian)
+	if data[1].(*big.Int).Cmp(new(big.Int).Rsh(maxU128, 1)) != 0 {
+		t.Error("unsigned 128-bit field should be 2^127-1, got", data[1])
+	}
+}
+
+func TestDecodeBack(t *testing.T) {
+	fields, size, err := ParseSpec("L X4 cccc")
+	if err != nil || size != 4 {
+		t.Error("record with X should be 4 bytes, got", size, err)
+	}
+	in := []byte{0x01, 0x02, 0x03, 0xff}
+	data, err := Decode(bytes.NewReader(in), fields, binary.LittleEndian)
+	expect := []interface{}{uint32(0xff030201), int8(1), int8(2), int8(3), int8(-1)}
+	if err != nil || !reflect.DeepEqual(data, expect) {
+		t.Error("bytes not decoded again after X, got", data, err)
+	}
+
+	// The record ends at the furthest byte read
+	fields, size, _ = ParseSpec("S X C2 ")
+	r := bytes.NewReader([]byte{1, 2, 3, 4, 5, 6})
+	d := NewDecoder(r, fields, binary.BigEndian)
+	data, err = d.Decode()
+	if err != nil || size != 3 || !reflect.DeepEqual(data, []interface{}{uint16(0x0102), uint8(2), uint8(3)}) {
+		t.Error("record with X decoded wrong, got", data, size, err)
+	}
+	if data, _ = d.Decode(); !reflect.DeepEqual(data, []interface{}{uint16(0x0405), uint8(5), uint8(6)}) {
+		t.Error("next record should start after the furthest byte, got", data)
+	}
+}
+
+func TestDecodeGUID(t *testing.T) {
+	in := []byte{0x33, 0x22, 0x11, 0x00, 0x55, 0x44, 0x77, 0x66, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+	// Fixed size record and field by field, in either byte order
+	for _, spec := range []string{"g", "g z"} {
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			fields, _, _ := ParseSpec(spec)
+			data, err := Decode(bytes.NewReader(append(in, 0)), fields, order)
+			if err != nil || len(data) == 0 {
+				t.Fatal("GUID not decoded,", err)
+			}
+			if s := data[0].(UUID).String(); s != "00112233-4455-6677-8899-aabbccddeeff" {
+				t.Error("GUID of", spec, "decoded wrong, got", s)
+			}
+		}
+	}
+}
+
+func TestDecodeLEB128(t *testing.T) {
+	fields, size, _ := ParseSpec("vVVCv")
+	if size != 1 {
+		t.Error("LEB128 numbers should have no fixed size, record size is", size)
+	}
+	in := []byte{0x96, 0x01, 0x7f, 0x80, 0x7f, 0xaa, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}
+	data, err := Decode(bytes.NewReader(in), fields, binary.LittleEndian)
+	expect := []interface{}{uint64(150), int64(-1), int64(-128), uint8(0xaa), uint64(math.MaxUint64)}
+	if err != nil || !reflect.DeepEqual(data, expect) {
+		t.Error("LEB128 numbers not decoded correctly, got", data, err)
+	}
+
+	data, err = Decode(bytes.NewReader([]byte{0x2a, 0x96}), fields, binary.LittleEndian)
+	if err != io.ErrUnexpectedEOF || !reflect.DeepEqual(data, []interface{}{uint64(42)}) {
+		t.Error("cut LEB128 number should be an unexpected EOF, got", data, err)
+	}
+	long := bytes.Repeat([]byte{0x80}, 11)
+	if _, err = Decode(bytes.NewReader(long), fields, binary.LittleEndian); err == nil || err == io.ErrUnexpectedEOF {
+		t.Error("LEB128 number longer than 64 bits should be an error, got", err)
+	}
+
+	// The 10th byte only holds bit 63
+	max := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	for _, td := range []struct {
+		spec string
+		last byte
+		ok   bool
+	}{
+		{"v", 0x01, true}, {"v", 0x02, false}, {"v", 0x7f, false},
+		{"V", 0x7f, true}, {"V", 0x00, true}, {"V", 0x01, false}, {"V", 0x3f, false},
+	} {
+		fields, _, _ := ParseSpec(td.spec)
+		in := append(append([]byte{}, max...), td.last)
+		if _, err = Decode(bytes.NewReader(in), fields, binary.LittleEndian); (err == nil) != td.ok {
+			t.Errorf("%s with 10th byte %#02x: error %v", td.spec, td.last, err)
+		}
+	}
+}
+
+func TestDecodeAlign(t *testing.T) {
+	fields, _, _ := ParseSpec("z !4 L")
+	in := []byte("hi\x00\x00\x05\x00\x00\x00abcd\x00\x00\x00\x00\x06\x00\x00\x00")
+	dec := NewDecoder(bytes.NewReader(in), fields, binary.LittleEndian)
+	for _, expect := range [][]interface{}{{"hi", uint32(5)}, {"abcd", uint32(6)}} {
+		if data, err := dec.Decode(); err != nil || !reflect.DeepEqual(data, expect) {
+			t.Error("aligned field not decoded correctly, got", data, err)
+		}
+	}
+
+	data, err := Decode(bytes.NewReader([]byte("abcde\x00")), fields, binary.LittleEndian)
+	if err != io.ErrUnexpectedEOF || !reflect.DeepEqual(data, []interface{}{"abcde"}) {
+		t.Error("record cut in the padding should be an unexpected EOF, got", data, err)
+	}
+}
+
+func TestDecodeFixedString(t *testing.T) {
+	fields, size, _ := ParseSpec("a4Ca")
+	if size != 6 || !reflect.DeepEqual(DataFields(fields), []FieldType{STR, U8, STR}) {
+		t.Error("fixed length string spec not parsed correctly, got", fields, size)
+	}
+	data, err := Decode(bytes.NewReader([]byte("ab\x00\x00\x07z")), fields, binary.LittleEndian)
+	if err != nil || !reflect.DeepEqual(data, []interface{}{"ab\x00\x00", uint8(7), "z"}) {
+		t.Error("fixed length strings decoded wrong, got", data, err)
+	}
+	if _, _, err := ParseSpec("a0"); err == nil {
+		t.Error("string of length 0 should be rejected")
+	}
+}
+
+func TestDecodeOrderMarkers(t *testing.T) {
+	fields, size, _ := ParseSpec(">l<ssS")
+	if size != 10 || len(DataFields(fields)) != 4 {
+		t.Error("byte order markers should take no byte and no field, got", fields, size)
+	}
+	in := []byte{0, 0, 1, 2, 1, 0, 2, 0, 3, 0, 0, 0, 1, 2, 1, 0, 2, 0, 3, 0}
+	dec := NewDecoder(bytes.NewReader(in), fields, binary.LittleEndian)
+	for i := 0; i < 2; i++ {
+		// The order is back to the first marker for each record
+		data, err := dec.Decode()
+		if err != nil || !reflect.DeepEqual(data, []interface{}{int32(0x102), int16(1), int16(2), uint16(3)}) {
+			t.Error("mixed byte order record", i, "decoded wrong, got", data, err)
+		}
+	}
+
+	fields, _, _ = ParseSpec("S>S")
+	data, _ := Decode(bytes.NewReader([]byte{1, 0, 0, 1}), fields, binary.BigEndian)
+	if !reflect.DeepEqual(data, []interface{}{uint16(0x100), uint16(1)}) {
+		t.Error("fields before a marker should use the decoder byte order, got", data)
+	}
+	fields, _, _ = ParseSpec("=S")
+	data, _ = Decode(bytes.NewReader([]byte{1, 0}), fields, binary.BigEndian)
+	if want := binary.NativeEndian.Uint16([]byte{1, 0}); !reflect.DeepEqual(data, []interface{}{want}) {
+		t.Error("'=' should switch to the host byte order, got", data)
+	}
+	if _, _, err := ParseSpec("<:le"); err == nil {
+		t.Error("byte order marker with a name should be rejected")
+	}
+}
+
+func TestDecodeRecordSameAsPerField(t *testing.T) {
+	fields, size, _ := ParseSpec("cx>Sa3<mK")
+	in := []byte{1, 2, 3, 4, 'a', 'b', 'c', 5, 6, 7, 8, 9, 10, 11}
+	for cut := 0; cut <= size; cut++ {
+		var res [2][]interface{}
+		var errs [2]error
+		for i, fixed := range []bool{false, true} {
+			dec := NewDecoder(bytes.NewReader(in[:cut]), fields, binary.LittleEndian)
+			dec.fixed = fixed
+			res[i], errs[i] = dec.Decode()
+		}
+		if !reflect.DeepEqual(res[0], res[1]) || errs[0] != errs[1] {
+			t.Error("record of", cut, "bytes decoded", res[1], errs[1], "field by field", res[0], errs[0])
+		}
+	}
+}
+
+func TestDecoderStrTerm(t *testing.T) {
+	dec := NewDecoder(bytes.NewReader([]byte("ab|cd")), []FieldType{STRZ, STRZ}, binary.LittleEndian)
+	dec.StrTerm = '|'
+	data, err := dec.Decode()
+	if err != io.ErrUnexpectedEOF || !reflect.DeepEqual(data, []interface{}{"ab", "cd"}) {
+		t.Error("strings not split at the terminator, got", data, err)
+	}
+}
+
+func TestDecodeMap(t *testing.T) {
+	fields, _, _ := ParseSpec("CxSz")
+	dec := NewDecoder(bytes.NewReader([]byte{7, 0, 2, 1, 'o', 'k', 0}), fields, binary.BigEndian)
+	dec.Names = []string{"kind", "", "label"}
+	m, err := dec.DecodeMap()
+	expect := map[string]interface{}{"kind": uint8(7), "f1": uint16(0x201), "label": "ok"}
+	if err != nil || !reflect.DeepEqual(m, expect) {
+		t.Error("record not decoded to map correctly, got", m, err)
+	}
+	if _, err := dec.DecodeMap(); err != io.EOF {
+		t.Error("map decode at end of input should give io.EOF, got", err)
+	}
+}
+
+func TestDecodeConcurrent(t *testing.T) {
+	fields := []FieldType{U16, I32}
+	in := []byte{1, 0, 2, 0, 0, 0}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				data, err := Decode(bytes.NewReader(in), fields, binary.LittleEndian)
+				if err != nil || data[0] != uint16(1) || data[1] != int32(2) {
+					t.Error("concurrent decode gave", data, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// binaryRead decodes fields with a binary.Read for each field, as done
+// before Decoder, to compare the speed.
+func binaryRead(r io.Reader, fields []FieldType, order binary.ByteOrder, data []interface{}) (err error) {
+	var (
+		i8  int8
+		u16 uint16
+		i32 int32
+		u64 uint64
+		f64 float64
+	)
+	for i, v := range fields {
+		switch v {
+		case I8:
+			err = binary.Read(r, order, &i8)
+			data[i] = i8
+		case U16:
+			err = binary.Read(r, order, &u16)
+			data[i] = u16
+		case I32:
+			err = binary.Read(r, order, &i32)
+			data[i] = i32
+		case U64:
+			err = binary.Read(r, order, &u64)
+			data[i] = u64
+		case F64:
+			err = binary.Read(r, order, &f64)
+			data[i] = f64
+		case RGBA:
+			c := Color{Alpha: true}
+			_, err = io.ReadFull(r, c.Bytes[:])
+			data[i] = c
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func BenchmarkDecodeInto(b *testing.B) {
+	fields, size, _ := ParseSpec("cSlQdK")
+	in := bytes.Repeat([]byte{1, 2, 3, 4, 5, 6, 7, 8}, size*1000/8+1)[:size*1000]
+	data := make([]interface{}, len(fields))
+	b.Run("BinaryRead", func(b *testing.B) {
+		b.SetBytes(int64(len(in)))
+		r := bytes.NewReader(in)
+		for i := 0; i < b.N; i++ {
+			r.Reset(in)
+			for binaryRead(r, fields, binary.LittleEndian, data) == nil {
+			}
+		}
+	})
+	for _, fixed := range []bool{false, true} {
+		name := "PerField"
+		if fixed {
+			name = "Record"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(in)))
+			r := bytes.NewReader(in)
+			dec := NewDecoder(r, fields, binary.LittleEndian)
+			// Force reading field by field to compare
+			dec.fixed = fixed
+			for i := 0; i < b.N; i++ {
+				r.Reset(in)
+				for {
+					if _, err := dec.DecodeInto(data); err != nil {
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+// failingReader returns its error after the bytes in b.
+type failingReader struct {
+	b   []byte
+	err error
+}
+
+func (fr *failingReader) Read(p []byte) (int, error) {
+	if len(fr.b) == 0 {
+		return 0, fr.err
+	}
+	n := copy(p, fr.b)
+	fr.b = fr.b[n:]
+	return n, nil
+}
+
+func TestDecodeIOError(t *testing.T) {
+	cause := errors.New("device gone")
+	for _, spec := range []string{"S L", "S z", "S*"} {
+		fields, _, _ := ParseSpec(spec)
+		_, err := Decode(&failingReader{[]byte{1, 0, 'a'}, cause}, fields, binary.LittleEndian)
+		var ioErr *IOError
+		if !errors.As(err, &ioErr) || !errors.Is(err, cause) {
+			t.Errorf("read failure of %s should be an IOError, got %v", spec, err)
+		}
+		// Wrapped once, also in a list
+		if err != nil && err.Error() != "read error: device gone" {
+			t.Errorf("read failure of %s message wrong, got %q", spec, err)
+		}
+	}
+	// The end of input isn't wrapped
+	fields, _, _ := ParseSpec("S L")
+	if _, err := Decode(&failingReader{[]byte{1, 0, 'a'}, io.EOF}, fields, binary.LittleEndian); err != io.ErrUnexpectedEOF {
+		t.Error("end of input should be io.ErrUnexpectedEOF, got", err)
+	}
+}
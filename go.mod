@@ -0,0 +1,3 @@
+module github.com/gastaoss/bprint
+
+go 1.21
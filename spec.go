@@ -0,0 +1,1032 @@
+// Package bprint decodes binary records described by a format string using
+// the syntax of Ruby's Array.unpack.
+package bprint
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// FieldType is the type of a field in a binary record.
+type FieldType byte
+
+const (
+	I8 FieldType = iota
+	I16
+	I24
+	I32
+	I64
+
+	U8
+	U16
+	U24
+	U32
+	U64
+
+	F32
+	F64
+
+	// IEEE 754 half precision float, decoded to float32
+	F16
+
+	// Signed and unsigned 128-bit integers, decoded to *big.Int
+	I128
+	U128
+
+	// Colors, bytes are in R, G, B (, A) order
+	RGB
+	RGBA
+
+	// Microsoft GUID, the first three fields little-endian and the last two
+	// big-endian. Decoded to UUID.
+	GUID
+
+	// String terminated by Decoder.StrTerm
+	STRZ
+
+	// Unsigned and signed LEB128 variable length integers, as in DWARF and
+	// the protobuf wire format. Decoded to uint64 and int64.
+	ULEB
+	SLEB
+
+	// List of elements made of the fields after it, repeated until the end
+	// of input by '*'. Not a spec letter, it's found with IsArray.
+	array
+
+	// Byte skipped by x, not a field
+	SKIP
+
+	// Moves the read position one byte back like Ruby's X, so the following
+	// fields decode bytes again. Not a field.
+	BACK
+
+	// Pads to the next multiple of N bytes of the record for "!N" after a
+	// variable size field, the rest of the N bytes are ALIGNTAIL. After
+	// fixed size fields the padding is SKIP bytes instead. Not a field.
+	ALIGN
+	ALIGNTAIL
+
+	// Fixed length string decoded with its padding, the first byte of it.
+	// The rest of the bytes are STRTAIL, which is not a field.
+	STR
+	STRTAIL
+
+	// Byte order markers < and >, the following fields of the record are
+	// little or big-endian. Not a field.
+	LITTLE
+	BIG
+)
+
+const noType FieldType = 255
+
+var typeNames = [...]string{
+	I8:  "int8",
+	I16: "int16",
+	I24: "int24",
+	I32: "int32",
+	I64: "int64",
+
+	U8:  "uint8",
+	U16: "uint16",
+	U24: "uint24",
+	U32: "uint32",
+	U64: "uint64",
+
+	F32: "float32",
+	F64: "float64",
+
+	F16: "float16",
+
+	I128: "int128",
+	U128: "uint128",
+
+	RGB:  "rgb",
+	RGBA: "rgba",
+
+	GUID: "guid",
+
+	STRZ: "string",
+
+	ULEB: "uleb128",
+	SLEB: "sleb128",
+
+	array: "array",
+
+	SKIP: "skip",
+	BACK: "back",
+
+	ALIGN:     "align",
+	ALIGNTAIL: "align-tail",
+
+	STR:     "str",
+	STRTAIL: "str-tail",
+
+	LITTLE: "little-endian",
+	BIG:    "big-endian",
+}
+
+var typeSizes = [...]int{
+	I8:  1,
+	I16: 2,
+	I24: 3,
+	I32: 4,
+	I64: 8,
+
+	U8:  1,
+	U16: 2,
+	U24: 3,
+	U32: 4,
+	U64: 8,
+
+	F32: 4,
+	F64: 8,
+
+	F16: 2,
+
+	I128: 16,
+	U128: 16,
+
+	RGB:  3,
+	RGBA: 4,
+
+	GUID: 16,
+
+	// Variable size, the size without data is 0
+	STRZ: 0,
+	ULEB: 0,
+	SLEB: 0,
+
+	array: 0,
+
+	SKIP: 1,
+	// Moves back, the size of an X node is negative
+	BACK: 0,
+
+	// Variable size, the padding depends on the fields before it
+	ALIGN:     0,
+	ALIGNTAIL: 0,
+
+	STR:     1,
+	STRTAIL: 1,
+
+	LITTLE: 0,
+	BIG:    0,
+}
+
+func (t FieldType) String() string {
+	if int(t) < len(typeNames) {
+		return typeNames[t]
+	}
+	return fmt.Sprintf("FieldType(%d)", byte(t))
+}
+
+// Size returns the number of bytes the type takes in a record, 0 for
+// variable size types.
+func (t FieldType) Size() int {
+	if int(t) < len(typeSizes) {
+		return typeSizes[t]
+	}
+	return 0
+}
+
+// IsArray reports whether the type is the list of a field or group repeated
+// to the end with '*', the fields of one element follow it.
+func (t FieldType) IsArray() bool {
+	return t == array
+}
+
+// IsInt reports whether the type is a signed or unsigned integer.
+func (t FieldType) IsInt() bool {
+	return t <= U64
+}
+
+// Unsigned returns the unsigned integer type of the same size for a signed
+// integer type, ULEB for SLEB, other types are returned unchanged.
+func (t FieldType) Unsigned() FieldType {
+	if t >= I8 && t <= I64 {
+		return t + U8 - I8
+	}
+	switch t {
+	case I128:
+		return U128
+	case SLEB:
+		return ULEB
+	}
+	return t
+}
+
+// Signed returns the signed integer type of the same size for an unsigned
+// integer type, SLEB for ULEB, other types are returned unchanged.
+func (t FieldType) Signed() FieldType {
+	if t >= U8 && t <= U64 {
+		return t - (U8 - I8)
+	}
+	switch t {
+	case U128:
+		return I128
+	case ULEB:
+		return SLEB
+	}
+	return t
+}
+
+type typeDesc struct {
+	typeId FieldType
+	size   int
+}
+
+var specCharMap = map[byte]typeDesc{
+	'c': {I8, 1},
+	's': {I16, 2},
+	'm': {I24, 3},
+	'l': {I32, 4},
+	'q': {I64, 8},
+
+	'C': {U8, 1},
+	'S': {U16, 2},
+	'M': {U24, 3},
+	'L': {U32, 4},
+	'Q': {U64, 8},
+
+	'f': {F32, 4},
+	'd': {F64, 8},
+	'h': {F16, 2},
+
+	'o': {I128, 16},
+	'O': {U128, 16},
+
+	'k': {RGB, 3},
+	'K': {RGBA, 4},
+
+	'g': {GUID, 16},
+
+	'z': {STRZ, 0},
+
+	'v': {ULEB, 0},
+	'V': {SLEB, 0},
+
+	'x': {SKIP, 1},
+	'X': {BACK, 0},
+
+	'a': {STR, 1},
+
+	'<': {LITTLE, 0},
+	'>': {BIG, 0},
+	'=': {nativeOrder, 0},
+}
+
+// nativeOrder is the byte order marker of the host, '=' in a spec.
+var nativeOrder = func() FieldType {
+	if binary.NativeEndian.Uint16([]byte{1, 0}) == 1 {
+		return LITTLE
+	}
+	return BIG
+}()
+
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
+
+// ParseSpec parses a binary format like "cS2l" and returns the field types
+// and the record size in bytes. A spec containing a comma outside of bit
+// ranges uses the verbose syntax like "i8,u32*2,f64".
+//
+// An integer field can be split into bit ranges like "C{0-2,3,4-7}", the
+// field is still decoded as one value and SpecBits gives its ranges.
+//
+// "X" moves back a byte, like "L X4 C4" decoding a uint32 and then its
+// bytes, and "@N" moves to byte N of the record, like "@0 L @16 S". The
+// record size is then up to the furthest byte read, and a record with X or
+// "@" can't have variable size fields before them. "!N" pads the record
+// to the next multiple of N bytes, like "z !4 L" for a uint32 aligned after
+// a NUL terminated string.
+//
+// Spaces, tabs and newlines between fields are ignored, and '#' starts a
+// comment up to the end of the line. A repeat count must directly follow
+// its field, so "c 4" is an error rather than "c4". Errors in the spec are
+// a *SpecError.
+func ParseSpec(spec string) (fields []FieldType, recSize int, err error) {
+	fields, _, recSize, err = ParseNamedSpec(spec)
+	return
+}
+
+// ParseNamedSpec is like ParseSpec, and also returns a name for each value
+// decoded, that is for each field in DataFields(fields). A field is named
+// by a suffix like "c:flags", repeated fields like "C4:mac" are named by
+// index like mac[1]. Fields in a group like "L S (C4)3" are named by group,
+// element and position like g0[2].1, the group takes the name if it has
+// one. Other fields have an empty name.
+func ParseNamedSpec(spec string) (fields []FieldType, names []string, recSize int, err error) {
+	tree, err := ParseSpecTree(spec)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return FlattenSpec(tree)
+}
+
+// FlattenSpec returns the fields, names and record size of nodes parsed by
+// ParseSpecTree, like ParseNamedSpec does for the spec.
+func FlattenSpec(nodes []SpecNode) (fields []FieldType, names []string, recSize int, err error) {
+	fields, names = flattenSpec(nodes)
+	for _, v := range fields {
+		if v == BACK {
+			size, err := backedSize(fields)
+			return fields, names, size, err
+		}
+	}
+	return fields, names, SpecSize(nodes), nil
+}
+
+// SpecNode is a field repeated Repeat times, or a group of fields if Group
+// is not nil. Name is empty if not given in the spec. Len is the number of
+// bytes of a STR string, or the boundary of an ALIGN. Bits are the bit
+// ranges an integer field is split into, given like "C{0-2,3,4-7}".
+//
+// Repeat is RepeatToEnd for a field or group followed by '*' like "L*", it's
+// repeated until the end of input and decoded as a single list. Only the
+// last node of a spec can have it.
+type SpecNode struct {
+	Type   FieldType
+	Repeat int
+	Group  []SpecNode
+	Name   string
+	Len    int
+	Bits   []BitRange
+}
+
+// BitRange is the bits Lo to Hi, inclusive, of an integer field. Bit 0 is
+// the least significant bit of the value, after applying the byte order.
+type BitRange struct {
+	Lo, Hi uint
+}
+
+// Extract returns the bits of the range in v, shifted down to bit 0.
+func (r BitRange) Extract(v uint64) uint64 {
+	return v >> r.Lo & (1<<(r.Hi-r.Lo+1) - 1)
+}
+
+// RepeatToEnd is the Repeat of a node followed by '*'.
+const RepeatToEnd = -1
+
+// Size returns the number of bytes of the node with its repeats, 0 for a
+// node repeated to the end. An X node has the negative number of bytes it
+// moves back.
+func (n SpecNode) Size() int {
+	if n.Repeat == RepeatToEnd {
+		return 0
+	}
+	if n.Type == BACK && n.Group == nil {
+		return -n.Repeat
+	}
+	if n.Group != nil {
+		return n.Repeat * SpecSize(n.Group)
+	}
+	if n.Type == STR {
+		return n.Repeat * n.Len
+	}
+	return n.Repeat * n.Type.Size()
+}
+
+// moveTo appends to nodes the skip or X node moving to byte off of the
+// record, for "@off". The nodes must have a fixed size.
+func moveTo(nodes []SpecNode, off int) ([]SpecNode, error) {
+	for _, n := range nodes {
+		if !fixedSize(n) {
+			return nil, errors.New("'@' can't follow a variable size field")
+		}
+	}
+	switch pos := SpecSize(nodes); {
+	case off > pos:
+		nodes = append(nodes, SpecNode{Type: SKIP, Repeat: off - pos})
+	case off < pos:
+		nodes = append(nodes, SpecNode{Type: BACK, Repeat: pos - off})
+	}
+	return nodes, nil
+}
+
+// alignTo appends to nodes the padding to the next multiple of n bytes of
+// the record, for "!n". It's skipped bytes if the nodes have a fixed size,
+// otherwise an ALIGN node padding as needed by each record.
+func alignTo(nodes []SpecNode, n int) []SpecNode {
+	for _, v := range nodes {
+		if !fixedSize(v) {
+			return append(nodes, SpecNode{Type: ALIGN, Repeat: 1, Len: n})
+		}
+	}
+	if pad := (n - SpecSize(nodes)%n) % n; pad > 0 {
+		nodes = append(nodes, SpecNode{Type: SKIP, Repeat: pad})
+	}
+	return nodes
+}
+
+// fixedSize reports whether a node has the same size in every record.
+func fixedSize(n SpecNode) bool {
+	if n.Repeat == RepeatToEnd || n.Type == STRZ || n.Type == ULEB || n.Type == SLEB || n.Type == ALIGN {
+		return false
+	}
+	for _, v := range n.Group {
+		if !fixedSize(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// backedSize returns the size of a record of fields with BACK, that is up
+// to the furthest byte read. It's an error to move back before the start
+// of the record or to have variable size fields.
+func backedSize(fields []FieldType) (size int, err error) {
+	pos := 0
+	for _, v := range fields {
+		switch v {
+		case STRZ, ULEB, SLEB, array, ALIGN:
+			return 0, specErrorf("", -1, "'X' can't be used with the variable size %s", v.String())
+		case BACK:
+			if pos--; pos < 0 {
+				return 0, specErrorf("", -1, "'X' moves back before the start of the record")
+			}
+		default:
+			pos += v.Size()
+		}
+		if pos > size {
+			size = pos
+		}
+	}
+	return size, nil
+}
+
+// SpecSize returns the number of bytes of the nodes.
+func SpecSize(nodes []SpecNode) (size int) {
+	for _, n := range nodes {
+		size += n.Size()
+	}
+	return
+}
+
+// ParseSpecTree parses a binary format like ParseSpec, keeping the repeats
+// and groups as written.
+func ParseSpecTree(spec string) ([]SpecNode, error) {
+	spec = stripComments(spec)
+	var nodes []SpecNode
+	var err error
+	if isVerbose(spec) {
+		nodes, err = parseVerboseSpec(spec)
+	} else {
+		p := &specParser{spec: spec}
+		nodes, err = p.parse(false)
+		if err == nil && p.pos < len(spec) {
+			err = specErrorf(spec, p.pos, "')' without group")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if fieldCount(nodes) > maxCount {
+		return nil, specErrorf(spec, -1, "record has more than %d fields", maxCount)
+	}
+	return nodes, nil
+}
+
+// SpecBits returns the bit ranges of the fields split like "C{0-2,3}", by
+// the index of the field in DataFields of the flattened spec.
+func SpecBits(nodes []SpecNode) map[int][]BitRange {
+	bits := make(map[int][]BitRange)
+	specBits(nodes, 0, bits)
+	return bits
+}
+
+// specBits adds the bit ranges in nodes to bits, the first data field of
+// nodes is at idx. It returns the index after the data fields of nodes.
+func specBits(nodes []SpecNode, idx int, bits map[int][]BitRange) int {
+	for _, n := range nodes {
+		switch {
+		case n.Repeat == RepeatToEnd:
+			// A list of any number of elements, without bit ranges
+			idx++
+		case n.Group != nil:
+			for i := 0; i < n.Repeat; i++ {
+				idx = specBits(n.Group, idx, bits)
+			}
+		case n.Type.IsData():
+			for i := 0; i < n.Repeat; i++ {
+				if n.Bits != nil {
+					bits[idx] = n.Bits
+				}
+				idx++
+			}
+		}
+	}
+	return idx
+}
+
+// hasBits reports whether a node or a field in its group has bit ranges.
+func hasBits(n SpecNode) bool {
+	for _, v := range n.Group {
+		if hasBits(v) {
+			return true
+		}
+	}
+	return n.Bits != nil
+}
+
+// isVerbose reports whether spec uses the verbose syntax, that is has a
+// comma outside of bit ranges.
+func isVerbose(spec string) bool {
+	inBits := false
+	for _, c := range spec {
+		switch c {
+		case '{':
+			inBits = true
+		case '}':
+			inBits = false
+		case ',':
+			if !inBits {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stripComments removes the '#' comments of each line of spec.
+func stripComments(spec string) string {
+	if !strings.Contains(spec, "#") {
+		return spec
+	}
+	lines := strings.Split(spec, "\n")
+	for i, v := range lines {
+		if idx := strings.IndexByte(v, '#'); idx >= 0 {
+			// Blanked, so errors are at the position in the spec as written
+			lines[i] = v[:idx] + strings.Repeat(" ", len(v)-idx)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// flattenSpec expands the repeats and groups in nodes into fields, names has
+// the names of the fields which are not skipped. A node repeated to the end
+// is an array followed by the fields of one element.
+func flattenSpec(nodes []SpecNode) (fields []FieldType, names []string) {
+	fields = make([]FieldType, 0)
+	groupCnt := 0
+	for _, n := range nodes {
+		if n.Repeat == RepeatToEnd {
+			fields = append(fields, array)
+			elem := n
+			elem.Repeat = 1
+			elemFields, _ := flattenSpec([]SpecNode{elem})
+			fields = append(fields, elemFields...)
+			names = append(names, n.Name)
+			if n.Group != nil {
+				groupCnt++
+			}
+			continue
+		}
+		if n.Group == nil {
+			tail := STRTAIL
+			if n.Type == ALIGN {
+				tail = ALIGNTAIL
+			}
+			for i := 0; i < n.Repeat; i++ {
+				fields = append(fields, n.Type)
+				for j := 1; j < n.Len; j++ {
+					fields = append(fields, tail)
+				}
+				if !n.Type.IsData() {
+					continue
+				}
+				if n.Name != "" && n.Repeat > 1 {
+					names = append(names, fmt.Sprintf("%s[%d]", n.Name, i))
+				} else {
+					names = append(names, n.Name)
+				}
+			}
+			continue
+		}
+		groupName := n.Name
+		if groupName == "" {
+			groupName = fmt.Sprintf("g%d", groupCnt)
+		}
+		groupFields, groupNames := flattenSpec(n.Group)
+		for i := 0; i < n.Repeat; i++ {
+			fields = append(fields, groupFields...)
+			for j, name := range groupNames {
+				if name == "" {
+					// Position in the group, a named field keeps its name
+					name = strconv.Itoa(j)
+				}
+				names = append(names, fmt.Sprintf("%s[%d].%s", groupName, i, name))
+			}
+		}
+		groupCnt++
+	}
+	return
+}
+
+// SpecError is a mistake in a binary format.
+type SpecError struct {
+	// Byte index in the spec of the field in error, -1 if the error isn't at
+	// a field, like a record too large
+	Pos int
+	// Byte at Pos, 0 at the end of the spec or without Pos
+	Char byte
+	Msg  string
+}
+
+func (e *SpecError) Error() string {
+	if e.Pos < 0 {
+		return "data field error: " + e.Msg
+	}
+	return fmt.Sprintf("data field error at position %d: %s", e.Pos, e.Msg)
+}
+
+// specErrorf returns a SpecError at byte pos of spec.
+func specErrorf(spec string, pos int, format string, a ...interface{}) error {
+	e := &SpecError{Pos: pos, Msg: fmt.Sprintf(format, a...)}
+	if pos >= 0 && pos < len(spec) {
+		e.Char = spec[pos]
+	}
+	return e
+}
+
+// errorf returns a SpecError at byte pos of the spec being parsed.
+func (p *specParser) errorf(pos int, format string, a ...interface{}) error {
+	return specErrorf(p.spec, pos, format, a...)
+}
+
+// specParser parses a terse binary format, with groups in parentheses.
+type specParser struct {
+	spec string
+	pos  int
+
+	// A node repeated to the end with '*' was parsed, it must be the last
+	toEnd bool
+}
+
+// parse parses the fields up to the end of the spec, or up to the ')'
+// closing the group if inGroup.
+func (p *specParser) parse(inGroup bool) (nodes []SpecNode, err error) {
+	nodes = make([]SpecNode, 0)
+	for p.pos < len(p.spec) {
+		c := p.spec[p.pos]
+		// Errors of the field are reported at its start
+		start := p.pos
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.pos++
+			continue
+		case p.toEnd:
+			return nil, p.errorf(start, "only the last field can be repeated to the end with '*'")
+		case c == ')':
+			if !inGroup {
+				return
+			}
+			p.pos++
+			return nodes, nil
+		case isDigit(c):
+			// Number must follow a previous field
+			return nil, p.errorf(start, "repeat number without previous data field")
+		case c == '@':
+			if inGroup {
+				return nil, p.errorf(start, "'@' can't be used in a group")
+			}
+			p.pos++
+			var off int
+			if off, err = p.repeatNum(); err != nil {
+				return
+			} else if off < 0 {
+				return nil, p.errorf(start, "offset expected after '@'")
+			}
+			if nodes, err = moveTo(nodes, off); err != nil {
+				return nil, p.errorf(start, "%v", err)
+			}
+			continue
+		case c == '!':
+			if inGroup {
+				return nil, p.errorf(start, "'!' can't be used in a group")
+			}
+			p.pos++
+			var n int
+			if n, err = p.repeatNum(); err != nil {
+				return
+			} else if n < 0 {
+				return nil, p.errorf(start, "boundary expected after '!'")
+			} else if n == 0 {
+				return nil, p.errorf(start, "boundary must be positive")
+			}
+			nodes = alignTo(nodes, n)
+			continue
+		}
+
+		var node SpecNode
+		if c == '(' {
+			p.pos++
+			if node.Group, err = p.parse(true); err != nil {
+				return
+			}
+		} else {
+			desc, ok := specCharMap[c]
+			if !ok {
+				return nil, p.errorf(start, "'%c' not supported", c)
+			}
+			p.pos++
+			node.Type = desc.typeId
+			if p.pos < len(p.spec) && p.spec[p.pos] == '{' {
+				if !node.Type.IsInt() {
+					return nil, p.errorf(start, "'%c' is not an integer and can't be split into bits", c)
+				}
+				if node.Bits, err = p.bitRanges(node.Type.Size() * 8); err != nil {
+					return
+				}
+			}
+		}
+		if node.Type == STR && node.Group == nil {
+			// The number is the length of the string
+			node.Repeat = 1
+			if node.Len, err = p.repeatNum(); err != nil {
+				return
+			} else if node.Len == 0 {
+				return nil, p.errorf(start, "string length must be positive")
+			} else if node.Len < 0 {
+				node.Len = 1
+			}
+		} else if node.Repeat, err = p.repeatNum(); err != nil {
+			return
+		} else if node.Repeat == 0 {
+			return nil, p.errorf(start, "repeat number must be positive")
+		} else if node.Repeat < 0 {
+			node.Repeat = 1
+		}
+		if p.pos < len(p.spec) && p.spec[p.pos] == '*' {
+			if node.Group == nil && !node.Type.IsData() {
+				return nil, p.errorf(start, "'%c' is not a field and can't be repeated with '*'", c)
+			}
+			if node.Repeat > 1 {
+				return nil, p.errorf(start, "'*' after a repeat number")
+			}
+			if hasBits(node) {
+				return nil, p.errorf(start, "a field split into bits can't be repeated with '*'")
+			}
+			p.pos++
+			node.Repeat = RepeatToEnd
+			p.toEnd = true
+		}
+		if p.pos < len(p.spec) && p.spec[p.pos] == ':' {
+			p.pos++
+			if node.Name = p.name(); node.Name == "" {
+				return nil, p.errorf(start, "name expected after ':'")
+			}
+			if node.Group == nil && !node.Type.IsData() {
+				return nil, p.errorf(start, "'%c' is not a field and can't have a name", c)
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	if inGroup {
+		return nil, p.errorf(p.pos, "group not closed by ')'")
+	}
+	return
+}
+
+// bitRanges parses a list of bit ranges like "{0-2,3,4-7}" of an integer of
+// width bits.
+func (p *specParser) bitRanges(width int) (ranges []BitRange, err error) {
+	start := p.pos
+	end := strings.IndexByte(p.spec[p.pos:], '}')
+	if end < 0 {
+		return nil, p.errorf(start, "bit ranges not closed by '}'")
+	}
+	list := p.spec[p.pos+1 : p.pos+end]
+	p.pos += end + 1
+	for _, v := range strings.Split(list, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(v), "-", 2)
+		lo, err := strconv.Atoi(bounds[0])
+		hi := lo
+		if err == nil && len(bounds) == 2 {
+			hi, err = strconv.Atoi(bounds[1])
+		}
+		if err != nil || lo < 0 || hi < lo {
+			return nil, p.errorf(start, "invalid bit range '%s', should be like 0-2 or 3", v)
+		}
+		if hi >= width {
+			return nil, p.errorf(start, "bit %d out of range, the field has %d bits", hi, width)
+		}
+		ranges = append(ranges, BitRange{uint(lo), uint(hi)})
+	}
+	return ranges, nil
+}
+
+// name parses a field name made of letters, digits and '_'.
+func (p *specParser) name() string {
+	start := p.pos
+	for p.pos < len(p.spec) {
+		c := p.spec[p.pos]
+		if !isDigit(c) && c != '_' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') {
+			break
+		}
+		p.pos++
+	}
+	return p.spec[start:p.pos]
+}
+
+// repeatNum parses the number following a field or group, decimal or 0x
+// prefixed hex. It's -1 if there's none.
+func (p *specParser) repeatNum() (int, error) {
+	start := p.pos
+	isNum := isDigit
+	if strings.HasPrefix(p.spec[p.pos:], "0x") || strings.HasPrefix(p.spec[p.pos:], "0X") {
+		p.pos += 2
+		isNum = isHexDigit
+	}
+	for p.pos < len(p.spec) && isNum(p.spec[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		return -1, nil
+	}
+	n, err := parseCount(p.spec[start:p.pos])
+	if err != nil {
+		return 0, p.errorf(start, "%v", err)
+	}
+	return n, nil
+}
+
+func isHexDigit(b byte) bool {
+	return isDigit(b) || 'a' <= b && b <= 'f' || 'A' <= b && b <= 'F'
+}
+
+// maxCount bounds the repeat numbers, string lengths and offsets of a spec,
+// and its number of fields, so a typo doesn't make a huge record.
+const maxCount = 1 << 24
+
+// parseCount parses a repeat number, string length or offset, decimal or 0x
+// prefixed hex, up to maxCount.
+func parseCount(s string) (int, error) {
+	digits, base := s, 10
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		digits, base = s[2:], 16
+	}
+	n, err := strconv.ParseUint(digits, base, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) || strings.ContainsAny(digits, "+-_") {
+		return 0, fmt.Errorf("invalid number '%s'", s)
+	}
+	if err != nil || n > maxCount {
+		return 0, fmt.Errorf("number %s is too large, at most %d", s, maxCount)
+	}
+	return int(n), nil
+}
+
+// fieldCount returns the number of fields nodes flatten to, or more than
+// maxCount if it's larger. A repeat to the end counts once.
+func fieldCount(nodes []SpecNode) (cnt int) {
+	for _, n := range nodes {
+		c := n.Len
+		if n.Group != nil {
+			c = fieldCount(n.Group)
+		} else if c == 0 {
+			c = 1
+		}
+		if n.Repeat > 1 {
+			if c > maxCount/n.Repeat {
+				return maxCount + 1
+			}
+			c *= n.Repeat
+		}
+		if cnt += c; cnt > maxCount {
+			return maxCount + 1
+		}
+	}
+	return
+}
+
+// Types of the verbose binary format.
+var verboseTypes = map[string]FieldType{
+	"i8":  I8,
+	"i16": I16,
+	"i24": I24,
+	"i32": I32,
+	"i64": I64,
+
+	"u8":  U8,
+	"u16": U16,
+	"u24": U24,
+	"u32": U32,
+	"u64": U64,
+
+	"f32": F32,
+	"f64": F64,
+	"f16": F16,
+
+	"i128": I128,
+	"u128": U128,
+
+	"rgb":  RGB,
+	"rgba": RGBA,
+
+	"guid": GUID,
+
+	"strz": STRZ,
+
+	"uleb128": ULEB,
+	"sleb128": SLEB,
+
+	"skip": SKIP,
+	"back": BACK,
+
+	"<": LITTLE,
+	">": BIG,
+	"=": nativeOrder,
+}
+
+// parseVerboseSpec parses a binary format of comma separated type names like
+// "i8,u32*2,f64", where *N repeats the type. A * without number repeats the
+// last type to the end.
+func parseVerboseSpec(spec string) (nodes []SpecNode, err error) {
+	// Position of the type in spec
+	pos := 0
+	for _, raw := range strings.Split(spec, ",") {
+		start := pos + len(raw) - len(strings.TrimLeftFunc(raw, unicode.IsSpace))
+		pos += len(raw) + 1
+		v := strings.TrimSpace(raw)
+		if v == "" {
+			// Allow a trailing comma, needed for a single type
+			continue
+		}
+		if len(nodes) > 0 && nodes[len(nodes)-1].Repeat == RepeatToEnd {
+			return nil, specErrorf(spec, start, "only the last field can be repeated to the end with '*'")
+		}
+		if strings.HasPrefix(v, "@") {
+			off, err := parseCount(v[1:])
+			if err != nil {
+				return nil, specErrorf(spec, start, "'%s' has invalid offset: %v", v, err)
+			}
+			if nodes, err = moveTo(nodes, off); err != nil {
+				return nil, specErrorf(spec, start, "%v", err)
+			}
+			continue
+		}
+		if strings.HasPrefix(v, "align:") {
+			n, err := parseCount(v[len("align:"):])
+			if err != nil || n == 0 {
+				return nil, specErrorf(spec, start, "'%s' has invalid boundary", v)
+			}
+			nodes = alignTo(nodes, n)
+			continue
+		}
+		repeat := 1
+		if idx := strings.Index(v, "*"); idx >= 0 && idx == len(v)-1 {
+			repeat = RepeatToEnd
+			v = v[:idx]
+		} else if idx >= 0 {
+			var err error
+			if repeat, err = parseCount(v[idx+1:]); err != nil || repeat == 0 {
+				return nil, specErrorf(spec, start, "'%s' has invalid repeat number", v)
+			}
+			v = v[:idx]
+		}
+		if strings.HasPrefix(v, "str:") {
+			n, err := parseCount(v[len("str:"):])
+			if err != nil || n == 0 {
+				return nil, specErrorf(spec, start, "'%s' has invalid string length", v)
+			}
+			nodes = append(nodes, SpecNode{Type: STR, Repeat: repeat, Len: n})
+			continue
+		}
+		t, ok := verboseTypes[v]
+		if !ok {
+			return nil, specErrorf(spec, start, "'%s' not supported", v)
+		}
+		if repeat == RepeatToEnd && !t.IsData() {
+			return nil, specErrorf(spec, start, "'%s' is not a field and can't be repeated with '*'", v)
+		}
+		nodes = append(nodes, SpecNode{Type: t, Repeat: repeat})
+	}
+	return
+}
+
+// IsData reports whether a value is decoded for the type, it's not for
+// skipped bytes, padding, the STRTAIL bytes and the byte order markers.
+func (t FieldType) IsData() bool {
+	return t != SKIP && t != BACK && t != ALIGN && t != ALIGNTAIL && t != STRTAIL && t != LITTLE && t != BIG
+}
+
+// DataFields returns the types in fields without the skipped bytes, the
+// STRTAIL bytes and the byte order markers, that is the types of the values
+// returned by Decode. The fields of the element of an array are not
+// included.
+func DataFields(fields []FieldType) []FieldType {
+	data := make([]FieldType, 0, len(fields))
+	for _, v := range fields {
+		if v.IsData() {
+			data = append(data, v)
+		}
+		if v == array {
+			break
+		}
+	}
+	return data
+}
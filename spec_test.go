@@ -0,0 +1,239 @@
+package bprint
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSpec(t *testing.T) {
+	testData := []struct {
+		spec   string
+		fields []FieldType
+		size   int
+	}{
+		{"cSd", []FieldType{I8, U16, F64}, 11},
+		{"k2x", []FieldType{RGB, RGB, SKIP}, 7},
+		{"zL", []FieldType{STRZ, U32}, 4},
+		{"i8,u32*2,", []FieldType{I8, U32, U32}, 9},
+		{"S L*", []FieldType{U16, array, U32}, 2},
+		{"u8,u16*", []FieldType{U8, array, U16}, 1},
+		{"(C a2)*", []FieldType{array, U8, STR, STRTAIL}, 0},
+		{"S0x3", []FieldType{U16, U16, U16}, 6},
+		{"a0X2 @0x4 C", []FieldType{STR, STRTAIL, SKIP, SKIP, U8}, 5},
+		{"u8*0x2,str:0x2,", []FieldType{U8, U8, STR, STRTAIL}, 4},
+		{"g C", []FieldType{GUID, U8}, 17},
+		{"guid,u8,", []FieldType{GUID, U8}, 17},
+		{"@0 L @6 S", []FieldType{U32, SKIP, SKIP, U16}, 8},
+		{"L @2 S @8", []FieldType{U32, BACK, BACK, U16, SKIP, SKIP, SKIP, SKIP}, 8},
+		{"u32,@1,u8,", []FieldType{U32, BACK, BACK, BACK, U8}, 4},
+		{"C !4 L", []FieldType{U8, SKIP, SKIP, SKIP, U32}, 8},
+		{"L!4 S!2", []FieldType{U32, U16}, 6},
+		{"z !4 L", []FieldType{STRZ, ALIGN, ALIGNTAIL, ALIGNTAIL, ALIGNTAIL, U32}, 4},
+		{"u8,align:4,u16,", []FieldType{U8, SKIP, SKIP, SKIP, U16}, 6},
+		{"c   # flags\n s  # length, in bytes\r\n\tL2\n", []FieldType{I8, I16, U32, U32}, 11},
+		{"# header\ni8,\nu16*2, # counts\n", []FieldType{I8, U16, U16}, 5},
+	}
+
+	for _, td := range testData {
+		fields, size, err := ParseSpec(td.spec)
+		if err != nil || !reflect.DeepEqual(fields, td.fields) || size != td.size {
+			t.Error("spec", td.spec, "not parsed correctly, got", fields, size, err)
+		}
+	}
+
+	for _, s := range []string{"3c", "ci", "i8,x16", "u8*0,", "L*C", "(L*)", "L2*", "x*", "u8*,u8", "c 4", "c # 4\n2", "X", "L X5", "z X", "L X:a", "C X*", "u8,back*", "@", "(C @2)", "z @4", "u8,@x", "c0", "a0", "(C)0", "c16777217", "c99999999999", "c99999999999999999999999", "C0x", "C0x1000001", "(C4096)4097", "((C256)256)257", "u8*0x,", "u8*+1,", "u8*99999999999,", "str:16777217,", "u8,@-1,", "!", "C !0", "C !x", "(C !4)", "z !4 @8", "u8,align:0,", "u8,align:x,"} {
+		if _, _, err := ParseSpec(s); err == nil {
+			t.Error("spec", s, "should be rejected")
+		}
+	}
+}
+
+func TestSpecCountBound(t *testing.T) {
+	// Up to maxCount fields, checked before the spec is flattened
+	if tree, err := ParseSpecTree("(C4096)4096"); err != nil || fieldCount(tree) != maxCount {
+		t.Error("spec of maxCount fields should be accepted, got", err)
+	}
+	if n, err := parseCount("16777216"); err != nil || n != maxCount {
+		t.Error("count of maxCount should be accepted, got", n, err)
+	}
+	if n, err := parseCount("0x1000000"); err != nil || n != maxCount {
+		t.Error("hex count of maxCount should be accepted, got", n, err)
+	}
+	if fieldCount([]SpecNode{{Type: U8, Repeat: maxCount}, {Type: STR, Repeat: 1, Len: 2}}) <= maxCount {
+		t.Error("fields beyond maxCount should be counted")
+	}
+}
+
+func TestParseSpecSize(t *testing.T) {
+	// Fields count the bytes of strings and skipped bytes too
+	testData := []struct {
+		spec   string
+		fields int
+		size   int
+	}{
+		{"c10", 10, 10},
+		{"s2l3", 5, 16},
+		{"q", 1, 8},
+		{"c4", 4, 4},
+		{"C16S", 17, 18},
+		{"C16 a4 x2 L", 23, 26},
+		{"c2c3", 5, 5},
+		{"(C16)2S", 33, 34},
+		{"c x3 (ss)2 L", 9, 16},
+	}
+
+	for _, td := range testData {
+		fields, size, err := ParseSpec(td.spec)
+		if err != nil || len(fields) != td.fields || size != td.size {
+			t.Error("spec", td.spec, "should have", td.fields, "fields of", td.size, "bytes, got", len(fields), size, err)
+		}
+	}
+}
+
+func TestDataFieldCount(t *testing.T) {
+	// Skips and moves take bytes but no print field, each field of a
+	// repeated group takes one
+	testData := []struct {
+		spec   string
+		fields int
+		size   int
+	}{
+		{"c x3 (ss)2 L", 6, 16},
+		{"x4 @2 C", 1, 4},
+		{"S X2 C2", 3, 2},
+		{"a4 x2 <L", 2, 10},
+		{"C{0-3,4-7} x S", 2, 4},
+	}
+
+	for _, td := range testData {
+		fields, size, err := ParseSpec(td.spec)
+		if err != nil || len(DataFields(fields)) != td.fields || size != td.size {
+			t.Error("spec", td.spec, "should have", td.fields, "data fields of", td.size, "bytes, got",
+				len(DataFields(fields)), size, err)
+		}
+	}
+}
+
+func TestParseNamedSpec(t *testing.T) {
+	fields, names, size, err := ParseNamedSpec("L S (C4)3")
+	if err != nil || len(fields) != 14 || size != 18 {
+		t.Error("group spec not parsed correctly, got", fields, size, err)
+	}
+	want := []string{"", "", "g0[0].0", "g0[0].1", "g0[0].2", "g0[0].3"}
+	if len(names) != 14 || !reflect.DeepEqual(names[:6], want) || names[13] != "g0[2].3" {
+		t.Error("group fields not named by position, got", names)
+	}
+
+	fields, names, size, _ = ParseNamedSpec("(Cx(S)2)2 c")
+	want = []string{"g0[0].0", "g0[0].g0[0].0", "g0[0].g0[1].0", "g0[1].0", "g0[1].g0[0].0", "g0[1].g0[1].0", ""}
+	if size != 13 || len(fields) != 9 || !reflect.DeepEqual(names, want) {
+		t.Error("nested group not parsed correctly, got", fields, names, size)
+	}
+
+	_, names, _, _ = ParseNamedSpec("c:flags C2:mac x2 (S:len C)2:pkt")
+	want = []string{"flags", "mac[0]", "mac[1]", "pkt[0].len", "pkt[0].1", "pkt[1].len", "pkt[1].1"}
+	if !reflect.DeepEqual(names, want) {
+		t.Error("named fields not parsed correctly, got", names)
+	}
+
+	for _, s := range []string{"(C", "C)", "(C)(", "2(C)", "c:", "x:pad", "c:-"} {
+		if _, _, _, err := ParseNamedSpec(s); err == nil {
+			t.Error("spec", s, "should be rejected")
+		}
+	}
+}
+
+func TestParseSpecTree(t *testing.T) {
+	tree, err := ParseSpecTree("C2 (s(L)2)3")
+	want := []SpecNode{
+		{Type: U8, Repeat: 2},
+		{Repeat: 3, Group: []SpecNode{
+			{Type: I16, Repeat: 1},
+			{Repeat: 2, Group: []SpecNode{{Type: U32, Repeat: 1}}},
+		}},
+	}
+	if err != nil || !reflect.DeepEqual(tree, want) {
+		t.Error("spec tree wrong, got", tree, err)
+	}
+	if size := SpecSize(tree); size != 32 {
+		t.Error("spec tree size should be 32, got", size)
+	}
+}
+
+func TestSpecBits(t *testing.T) {
+	tree, err := ParseSpecTree("C{0-2,3,4-7}")
+	want := []BitRange{{0, 2}, {3, 3}, {4, 7}}
+	if err != nil || len(tree) != 1 || !reflect.DeepEqual(tree[0].Bits, want) {
+		t.Error("bit ranges not parsed correctly, got", tree, err)
+	}
+	if v := want[2].Extract(0xa5); v != 0xa {
+		t.Error("bits 4-7 of 0xa5 should be 0xa, got", v)
+	}
+	if v := (BitRange{0, 63}).Extract(1<<64 - 1); v != 1<<64-1 {
+		t.Errorf("bits 0-63 should be the whole value, got %#x", v)
+	}
+
+	tree, _ = ParseSpecTree("S x C{0-3}2:f (L{31} a2)2")
+	bits := SpecBits(tree)
+	if len(bits) != 4 || bits[1] == nil || bits[2] == nil || bits[3] == nil || bits[5] == nil {
+		t.Error("bit ranges of fields wrong, got", bits)
+	}
+
+	for _, s := range []string{"f{0}", "C{8}", "C{3-1}", "C{0", "C{}", "S{0-3}*", "(C{0})*"} {
+		if _, err := ParseSpecTree(s); err == nil {
+			t.Error("spec", s, "should be rejected")
+		}
+	}
+}
+
+func TestDataFields(t *testing.T) {
+	fields := DataFields([]FieldType{SKIP, I8, SKIP, SKIP, U32})
+	if !reflect.DeepEqual(fields, []FieldType{I8, U32}) {
+		t.Error("skipped bytes not removed, got", fields)
+	}
+	fields = DataFields([]FieldType{I8, array, U32})
+	if !reflect.DeepEqual(fields, []FieldType{I8, array}) {
+		t.Error("array element should not be a field, got", fields)
+	}
+	if !fields[1].IsArray() || fields[0].IsArray() {
+		t.Error("only the list of a '*' repeat should be an array")
+	}
+}
+
+func TestSignedUnsigned(t *testing.T) {
+	fields, _, _ := ParseSpec("cSmQfkvV")
+	var signed, unsigned []FieldType
+	for _, v := range fields {
+		signed, unsigned = append(signed, v.Signed()), append(unsigned, v.Unsigned())
+	}
+	if !reflect.DeepEqual(signed, []FieldType{I8, I16, I24, I64, F32, RGB, SLEB, SLEB}) {
+		t.Error("signed types wrong, got", signed)
+	}
+	if !reflect.DeepEqual(unsigned, []FieldType{U8, U16, U24, U64, F32, RGB, ULEB, ULEB}) {
+		t.Error("unsigned types wrong, got", unsigned)
+	}
+}
+
+func TestSpecError(t *testing.T) {
+	for _, c := range []struct {
+		spec string
+		pos  int
+		char byte
+	}{
+		{"cS2l?", 4, '?'},
+		{"C # comment\n  c0", 14, 'c'},
+		{"(C", 2, 0},
+		{"u8, u9,", 4, 'u'},
+		{"L X5", -1, 0},
+	} {
+		_, _, err := ParseSpec(c.spec)
+		e, ok := err.(*SpecError)
+		if !ok || e.Pos != c.pos || e.Char != c.char {
+			t.Errorf("spec %q error should be at %d '%c', got %#v", c.spec, c.pos, c.char, err)
+		}
+	}
+	_, _, err := ParseSpec("cS2lz?")
+	if err == nil || err.Error() != "data field error at position 5: '?' not supported" {
+		t.Error("spec error message wrong, got", err)
+	}
+}